@@ -21,6 +21,9 @@ var (
 	aclCommand            commands.AccessControlCommand
 	analyseCommand        commands.AnalyseCommand
 	bucketValidateCommand commands.BucketValidationCommand
+	reconcileCommand      commands.ReconcileCommand
+	refactorCommand       commands.RefactorCommand
+	promqlCommand         commands.PromQLCommand
 )
 
 func main() {
@@ -35,6 +38,9 @@ func main() {
 	aclCommand.Register(app)
 	analyseCommand.Register(app)
 	bucketValidateCommand.Register(app)
+	reconcileCommand.Register(app)
+	refactorCommand.Register(app)
+	promqlCommand.Register(app)
 
 	app.Command("version", "Get the version of the cortextool CLI").Action(func(_ *kingpin.ParseContext) error {
 		fmt.Print(version.Template)
@@ -2,111 +2,213 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"flag"
 	"fmt"
 	"io"
 	"log"
 	"os"
+	"path"
 	"strings"
+	"time"
 
-	"github.com/cortexproject/cortex/pkg/alertmanager/alertspb"
-	"github.com/gogo/protobuf/proto"
-	"github.com/matttproud/golang_protobuf_extensions/pbutil"
-	"github.com/prometheus/alertmanager/nflog/nflogpb"
-	"github.com/prometheus/alertmanager/silence/silencepb"
+	"github.com/cortexproject/cortex/pkg/storage/bucket"
+	kitlog "github.com/go-kit/log"
+)
+
+// The full state of a tenant is stored in the alertmanager storage bucket
+// under alertmanager/<tenant>/fullstate.
+const (
+	fullStatePrefix = "alertmanager"
+	fullStateName   = "fullstate"
 )
 
 var (
-	out        bytes.Buffer
-	outputFile string
-	intputFile string
+	out              bytes.Buffer
+	outputFile       string
+	intputFile       string
+	bucketConfig     string
+	bucketConfigHelp bool
+	tenantID         string
+	stateOutputFile  string
+	verify           bool
+	filter           stateFilter
 )
 
 func main() {
 	flag.CommandLine.StringVar(&intputFile, "file", "", "path of fullstate file to deserialize")
 	flag.CommandLine.StringVar(&outputFile, "output", "", "file for deserialized output")
+	flag.CommandLine.StringVar(&bucketConfig, "bucket-config", "", "The CLI args to configure the alertmanager storage bucket to read the fullstate from, see -bucket-config-help.")
+	flag.CommandLine.BoolVar(&bucketConfigHelp, "bucket-config-help", false, "Help text explaining how to use the -bucket-config parameter.")
+	flag.CommandLine.StringVar(&tenantID, "tenant", "", "tenant whose fullstate is read from the bucket")
+
+	flag.CommandLine.StringVar(&stateOutputFile, "state-output", "", "file to write the filtered full state to, encoded as the alertmanager stores it")
+	flag.CommandLine.BoolVar(&verify, "verify", false, "check that the re-encoded full state decodes to the same silences and notification log")
+	flag.CommandLine.BoolVar(&filter.dropExpiredSilences, "drop-expired-silences", false, "drop the silences which have ended")
+	flag.CommandLine.Func("drop-silences-matching", "drop the silences with this matcher, such as alertname=\"HighLatency\" (repeatable)", func(s string) error {
+		m, err := parseSilenceMatcher(s)
+		if err != nil {
+			return err
+		}
+		filter.dropSilenceMatchers = append(filter.dropSilenceMatchers, m)
+		return nil
+	})
+	flag.CommandLine.Func("drop-nflog-before", "drop the notification log entries older than this RFC3339 time", func(s string) error {
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return err
+		}
+		filter.dropNotificationsBefore = t
+		return nil
+	})
 
 	flag.Parse()
 
-	if intputFile == "" {
+	if bucketConfigHelp {
+		printBucketConfigHelp()
+		return
+	}
+
+	var in []byte
+	var err error
+	switch {
+	case intputFile != "" && bucketConfig != "":
+		log.Fatalf("Only one of -file and -bucket-config can be specified.")
+	case intputFile != "":
+		in, err = os.ReadFile(intputFile)
+		if err != nil {
+			log.Fatalln("Error reading file:", err)
+		}
+	case bucketConfig != "":
+		if tenantID == "" {
+			log.Fatalf("No tenant specified.")
+		}
+		cfg, err := parseBucketConfig(bucketConfig)
+		if err != nil {
+			log.Fatalln("Error parsing bucket config:", err)
+		}
+		in, err = readBucketFullState(context.Background(), cfg, tenantID)
+		if err != nil {
+			log.Fatalln("Error reading full state from bucket:", err)
+		}
+	default:
 		log.Fatalf("No full state file specified.")
 	}
 
-	decodeFullState(intputFile)
+	parts, err := decodeFullState(in)
+	if err != nil {
+		log.Fatalln("Error decoding full state:", err)
+	}
+
+	if !filter.empty() {
+		filter.now = time.Now()
+		silences, notifications := filterFullState(parts, filter)
+		log.Printf("Dropped %d silences and %d notification log entries.", silences, notifications)
+	}
+
+	if stateOutputFile != "" || verify {
+		encoded, err := encodeFullState(parts)
+		if err != nil {
+			log.Fatalln("Error encoding full state:", err)
+		}
+		if verify {
+			if err := verifyFullState(parts, encoded); err != nil {
+				log.Fatalln("Full state round-trip verification failed:", err)
+			}
+			log.Printf("Full state round-trip verification succeeded.")
+		}
+		if stateOutputFile != "" {
+			if err := os.WriteFile(stateOutputFile, encoded, 0o600); err != nil {
+				log.Fatalln("Error writing full state:", err)
+			}
+		}
+	}
+
+	printFullState(parts)
 
 	outputToFile(outputFile)
 }
 
-func decodeFullState(path string) {
-	in, err := os.ReadFile(path)
+func printBucketConfigHelp() {
+	var cfg bucket.Config
+	fs := flag.NewFlagSet("bucket-config", flag.ContinueOnError)
+	cfg.RegisterFlags(fs)
+
+	fmt.Fprintf(fs.Output(), `
+The following help text describes the arguments
+which may be specified in the string that gets
+passed to "-bucket-config".
+
+Example:
+deserializer -tenant=tenant-1 -bucket-config='-backend=gcs -gcs.bucket-name=example-bucket'
+
+`)
+	fs.Usage()
+}
+
+func parseBucketConfig(args string) (bucket.Config, error) {
+	var cfg bucket.Config
+	fs := flag.NewFlagSet("bucket-config", flag.ContinueOnError)
+	cfg.RegisterFlags(fs)
+	err := fs.Parse(strings.Split(args, " "))
 	if err != nil {
-		log.Fatalln("Error reading file:", err)
+		return cfg, err
 	}
-	fs := alertspb.FullStateDesc{}
-	err = proto.Unmarshal(in, &fs)
+
+	return cfg, cfg.Validate()
+}
+
+// readBucketFullState reads the fullstate object of a tenant from the
+// alertmanager storage bucket.
+func readBucketFullState(ctx context.Context, cfg bucket.Config, tenant string) ([]byte, error) {
+	logger := kitlog.NewLogfmtLogger(kitlog.NewSyncWriter(os.Stderr))
+	bkt, err := bucket.NewClient(ctx, cfg, "deserializer", logger, nil)
 	if err != nil {
-		log.Fatalln("Error unmarshalling full state:", err)
+		return nil, fmt.Errorf("failed to create the bucket client: %w", err)
 	}
+	defer bkt.Close()
 
-	for _, part := range fs.GetState().Parts {
-		out.WriteString("\n----\n")
-		if isNfLog(part.Key) {
-			parseNotifications(part.Data)
-		} else if isSilence(part.Key) {
-			parseSilences(part.Data)
-		} else {
-			out.WriteString(fmt.Sprintf("Unknown part type: %s", part.Key))
-		}
-
+	name := path.Join(fullStatePrefix, tenant, fullStateName)
+	r, err := bkt.Get(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s: %w", name, err)
 	}
+	defer r.Close()
+
+	return io.ReadAll(r)
 }
 
-func parseNotifications(data []byte) {
-	out.WriteString("Alerts:\n")
-	r := bytes.NewReader(data)
-	for {
-		nf := nflogpb.MeshEntry{}
-		n, err := pbutil.ReadDelimited(r, &nf)
-		if err != nil && err != io.EOF {
-			log.Fatalf("unable to read alert notifications, %v", err)
-		}
-		if n == 0 || err == io.EOF {
-			break
-		}
-		result, err := json.Marshal(nf)
-		if err != nil {
-			log.Fatalf("unable to marshal to json, %v", err)
-		}
-		_, err = out.WriteString(string(result) + "\n")
-		if err != nil {
-			log.Fatalf("unable to write output, %v", err)
+// printFullState writes the silences and notification log entries of the
+// full state as JSON lines.
+func printFullState(parts []statePart) {
+	for _, part := range parts {
+		out.WriteString("\n----\n")
+		switch {
+		case isNfLog(part.key):
+			out.WriteString("Alerts:\n")
+			for _, nf := range part.notifications {
+				printJSON(nf)
+			}
+		case isSilence(part.key):
+			out.WriteString("Silences:\n")
+			for _, silence := range part.silences {
+				printJSON(silence)
+			}
+		default:
+			out.WriteString(fmt.Sprintf("Unknown part type: %s", part.key))
 		}
 	}
 }
 
-func parseSilences(data []byte) {
-	out.WriteString("Silences:\n")
-	r := bytes.NewReader(data)
-	for {
-		silence := silencepb.MeshSilence{}
-		n, err := pbutil.ReadDelimited(r, &silence)
-		if err != nil && err != io.EOF {
-			log.Fatalf("unable to read silences, %v", err)
-		}
-		if n == 0 || err == io.EOF {
-			break
-		}
-
-		result, err := json.Marshal(silence)
-		if err != nil {
-			log.Fatalf("unable to marshal to json, %v", err)
-		}
-
-		_, err = out.WriteString(string(result) + "\n")
-		if err != nil {
-			log.Fatalf("unable to write output, %v", err)
-		}
+func printJSON(v interface{}) {
+	result, err := json.Marshal(v)
+	if err != nil {
+		log.Fatalf("unable to marshal to json, %v", err)
+	}
+	_, err = out.WriteString(string(result) + "\n")
+	if err != nil {
+		log.Fatalf("unable to write output, %v", err)
 	}
 }
 
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cortexproject/cortex/pkg/alertmanager/alertspb"
+	"github.com/gogo/protobuf/proto"
+	"github.com/matttproud/golang_protobuf_extensions/pbutil"
+	"github.com/prometheus/alertmanager/cluster/clusterpb"
+	"github.com/prometheus/alertmanager/nflog/nflogpb"
+	"github.com/prometheus/alertmanager/silence/silencepb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadBucketFullState(t *testing.T) {
+	dir := t.TempDir()
+	expiresAt := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	var silences bytes.Buffer
+	_, err := pbutil.WriteDelimited(&silences, &silencepb.MeshSilence{
+		Silence: &silencepb.Silence{
+			Id:        "silence-1",
+			CreatedBy: "oncall",
+			Matchers:  []*silencepb.Matcher{{Name: "alertname", Pattern: "HighLatency"}},
+		},
+		ExpiresAt: expiresAt,
+	})
+	require.NoError(t, err)
+
+	var notifications bytes.Buffer
+	_, err = pbutil.WriteDelimited(&notifications, &nflogpb.MeshEntry{
+		Entry: &nflogpb.Entry{
+			GroupKey: []byte("{}:{alertname=\"HighLatency\"}"),
+			Receiver: &nflogpb.Receiver{GroupName: "pager", Integration: "webhook"},
+		},
+		ExpiresAt: expiresAt,
+	})
+	require.NoError(t, err)
+
+	state, err := proto.Marshal(&alertspb.FullStateDesc{
+		State: &clusterpb.FullState{
+			Parts: []clusterpb.Part{
+				{Key: "sil:user-1", Data: silences.Bytes()},
+				{Key: "nfl:user-1", Data: notifications.Bytes()},
+			},
+		},
+	})
+	require.NoError(t, err)
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "alertmanager", "user-1"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "alertmanager", "user-1", "fullstate"), state, 0o644))
+
+	cfg, err := parseBucketConfig("-backend=filesystem -filesystem.dir=" + dir)
+	require.NoError(t, err)
+
+	in, err := readBucketFullState(context.Background(), cfg, "user-1")
+	require.NoError(t, err)
+	assert.Equal(t, state, in)
+
+	parts, err := decodeFullState(in)
+	require.NoError(t, err)
+
+	out.Reset()
+	printFullState(parts)
+	assert.Contains(t, out.String(), "Silences:\n")
+	assert.Contains(t, out.String(), `"id":"silence-1"`)
+	assert.Contains(t, out.String(), "Alerts:\n")
+	assert.Contains(t, out.String(), `"group_name":"pager"`)
+
+	_, err = readBucketFullState(context.Background(), cfg, "user-2")
+	assert.ErrorContains(t, err, "alertmanager/user-2/fullstate")
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/cortexproject/cortex/pkg/alertmanager/alertspb"
+	"github.com/gogo/protobuf/proto"
+	"github.com/matttproud/golang_protobuf_extensions/pbutil"
+	"github.com/prometheus/alertmanager/cluster/clusterpb"
+	"github.com/prometheus/alertmanager/nflog/nflogpb"
+	"github.com/prometheus/alertmanager/pkg/labels"
+	"github.com/prometheus/alertmanager/silence/silencepb"
+)
+
+// statePart is a decoded part of the full state. Parts which are neither
+// silences nor a notification log are kept as they are.
+type statePart struct {
+	key           string
+	silences      []*silencepb.MeshSilence
+	notifications []*nflogpb.MeshEntry
+	data          []byte
+}
+
+// stateFilter selects the silences and notification log entries to drop from
+// the full state.
+type stateFilter struct {
+	now                     time.Time
+	dropExpiredSilences     bool
+	dropSilenceMatchers     []*silencepb.Matcher
+	dropNotificationsBefore time.Time
+}
+
+func (f stateFilter) empty() bool {
+	return !f.dropExpiredSilences && len(f.dropSilenceMatchers) == 0 && f.dropNotificationsBefore.IsZero()
+}
+
+// parseSilenceMatcher parses a matcher such as alertname="HighLatency" into
+// the matcher of a silence.
+func parseSilenceMatcher(s string) (*silencepb.Matcher, error) {
+	m, err := labels.ParseMatcher(s)
+	if err != nil {
+		return nil, err
+	}
+
+	matcher := &silencepb.Matcher{Name: m.Name, Pattern: m.Value}
+	switch m.Type {
+	case labels.MatchEqual:
+		matcher.Type = silencepb.Matcher_EQUAL
+	case labels.MatchNotEqual:
+		matcher.Type = silencepb.Matcher_NOT_EQUAL
+	case labels.MatchRegexp:
+		matcher.Type = silencepb.Matcher_REGEXP
+	case labels.MatchNotRegexp:
+		matcher.Type = silencepb.Matcher_NOT_REGEXP
+	}
+	return matcher, nil
+}
+
+// decodeFullState decodes the silences and notification log of a full state.
+func decodeFullState(in []byte) ([]statePart, error) {
+	fs := alertspb.FullStateDesc{}
+	if err := proto.Unmarshal(in, &fs); err != nil {
+		return nil, fmt.Errorf("unable to unmarshal full state: %w", err)
+	}
+
+	if fs.GetState() == nil {
+		return nil, nil
+	}
+
+	var parts []statePart
+	for _, p := range fs.GetState().Parts {
+		part := statePart{key: p.Key}
+		var err error
+		switch {
+		case isNfLog(p.Key):
+			part.notifications, err = decodeNotifications(p.Data)
+		case isSilence(p.Key):
+			part.silences, err = decodeSilences(p.Data)
+		default:
+			part.data = p.Data
+		}
+		if err != nil {
+			return nil, fmt.Errorf("unable to decode part %s: %w", p.Key, err)
+		}
+		parts = append(parts, part)
+	}
+	return parts, nil
+}
+
+func decodeNotifications(data []byte) ([]*nflogpb.MeshEntry, error) {
+	var entries []*nflogpb.MeshEntry
+	r := bytes.NewReader(data)
+	for {
+		nf := &nflogpb.MeshEntry{}
+		n, err := pbutil.ReadDelimited(r, nf)
+		if err != nil && err != io.EOF {
+			return nil, fmt.Errorf("unable to read alert notifications, %w", err)
+		}
+		if n == 0 || err == io.EOF {
+			return entries, nil
+		}
+		entries = append(entries, nf)
+	}
+}
+
+func decodeSilences(data []byte) ([]*silencepb.MeshSilence, error) {
+	var silences []*silencepb.MeshSilence
+	r := bytes.NewReader(data)
+	for {
+		silence := &silencepb.MeshSilence{}
+		n, err := pbutil.ReadDelimited(r, silence)
+		if err != nil && err != io.EOF {
+			return nil, fmt.Errorf("unable to read silences, %w", err)
+		}
+		if n == 0 || err == io.EOF {
+			return silences, nil
+		}
+		silences = append(silences, silence)
+	}
+}
+
+// filterFullState drops the silences and notification log entries selected
+// by the filter, and returns the number of each dropped.
+func filterFullState(parts []statePart, f stateFilter) (droppedSilences, droppedNotifications int) {
+	for i := range parts {
+		silences := parts[i].silences[:0]
+		for _, s := range parts[i].silences {
+			if f.dropSilence(s) {
+				droppedSilences++
+				continue
+			}
+			silences = append(silences, s)
+		}
+		parts[i].silences = silences
+
+		notifications := parts[i].notifications[:0]
+		for _, e := range parts[i].notifications {
+			if f.dropNotification(e) {
+				droppedNotifications++
+				continue
+			}
+			notifications = append(notifications, e)
+		}
+		parts[i].notifications = notifications
+	}
+	return droppedSilences, droppedNotifications
+}
+
+func (f stateFilter) dropSilence(s *silencepb.MeshSilence) bool {
+	if s.Silence == nil {
+		return false
+	}
+	if f.dropExpiredSilences && !s.Silence.EndsAt.After(f.now) {
+		return true
+	}
+	for _, m := range s.Silence.Matchers {
+		for _, drop := range f.dropSilenceMatchers {
+			if m.Type == drop.Type && m.Name == drop.Name && m.Pattern == drop.Pattern {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (f stateFilter) dropNotification(e *nflogpb.MeshEntry) bool {
+	if e.Entry == nil || f.dropNotificationsBefore.IsZero() {
+		return false
+	}
+	return e.Entry.Timestamp.Before(f.dropNotificationsBefore)
+}
+
+// encodeFullState encodes the parts as a full state, with the silences and
+// notification log entries delimited the way Alertmanager writes them.
+func encodeFullState(parts []statePart) ([]byte, error) {
+	state := &clusterpb.FullState{}
+	for _, part := range parts {
+		data := part.data
+		if isNfLog(part.key) || isSilence(part.key) {
+			var buf bytes.Buffer
+			for _, e := range part.notifications {
+				if _, err := pbutil.WriteDelimited(&buf, e); err != nil {
+					return nil, fmt.Errorf("unable to write alert notifications, %w", err)
+				}
+			}
+			for _, s := range part.silences {
+				if _, err := pbutil.WriteDelimited(&buf, s); err != nil {
+					return nil, fmt.Errorf("unable to write silences, %w", err)
+				}
+			}
+			data = buf.Bytes()
+		}
+		state.Parts = append(state.Parts, clusterpb.Part{Key: part.key, Data: data})
+	}
+	return proto.Marshal(&alertspb.FullStateDesc{State: state})
+}
+
+// verifyFullState checks that the encoded full state decodes to the parts it
+// was encoded from.
+func verifyFullState(parts []statePart, encoded []byte) error {
+	decoded, err := decodeFullState(encoded)
+	if err != nil {
+		return err
+	}
+	if len(decoded) != len(parts) {
+		return fmt.Errorf("decoded %d parts, expected %d", len(decoded), len(parts))
+	}
+
+	for i, want := range parts {
+		got := decoded[i]
+		if got.key != want.key {
+			return fmt.Errorf("decoded part %s, expected %s", got.key, want.key)
+		}
+		if !bytes.Equal(got.data, want.data) {
+			return fmt.Errorf("part %s differs", want.key)
+		}
+		if len(got.silences) != len(want.silences) {
+			return fmt.Errorf("decoded %d silences in part %s, expected %d", len(got.silences), want.key, len(want.silences))
+		}
+		for j := range want.silences {
+			if !proto.Equal(got.silences[j], want.silences[j]) {
+				return fmt.Errorf("silence %d of part %s differs", j, want.key)
+			}
+		}
+		if len(got.notifications) != len(want.notifications) {
+			return fmt.Errorf("decoded %d notification log entries in part %s, expected %d", len(got.notifications), want.key, len(want.notifications))
+		}
+		for j := range want.notifications {
+			if !proto.Equal(got.notifications[j], want.notifications[j]) {
+				return fmt.Errorf("notification log entry %d of part %s differs", j, want.key)
+			}
+		}
+	}
+	return nil
+}
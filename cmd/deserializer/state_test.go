@@ -0,0 +1,91 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/alertmanager/nflog/nflogpb"
+	"github.com/prometheus/alertmanager/silence/silencepb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilterFullState(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	silence := func(id string, endsAt time.Time, matchers ...*silencepb.Matcher) *silencepb.MeshSilence {
+		return &silencepb.MeshSilence{
+			Silence:   &silencepb.Silence{Id: id, Matchers: matchers, StartsAt: now.Add(-time.Hour), EndsAt: endsAt},
+			ExpiresAt: endsAt.Add(120 * time.Hour),
+		}
+	}
+	notification := func(group string, ts time.Time) *nflogpb.MeshEntry {
+		return &nflogpb.MeshEntry{
+			Entry:     &nflogpb.Entry{GroupKey: []byte(group), Receiver: &nflogpb.Receiver{GroupName: "pager", Integration: "webhook"}, Timestamp: ts},
+			ExpiresAt: ts.Add(120 * time.Hour),
+		}
+	}
+
+	parts := []statePart{
+		{
+			key: "sil:user-1",
+			silences: []*silencepb.MeshSilence{
+				silence("expired", now.Add(-time.Minute), &silencepb.Matcher{Name: "alertname", Pattern: "Disk"}),
+				silence("noisy", now.Add(time.Hour), &silencepb.Matcher{Name: "alertname", Pattern: "HighLatency"}),
+				silence("regex", now.Add(time.Hour), &silencepb.Matcher{Type: silencepb.Matcher_REGEXP, Name: "alertname", Pattern: "HighLatency"}),
+				silence("active", now.Add(time.Hour), &silencepb.Matcher{Name: "alertname", Pattern: "Disk"}),
+			},
+		},
+		{
+			key: "nfl:user-1",
+			notifications: []*nflogpb.MeshEntry{
+				notification("old", now.Add(-48*time.Hour)),
+				notification("recent", now.Add(-time.Hour)),
+			},
+		},
+		{key: "unknown", data: []byte("opaque")},
+	}
+
+	matcher, err := parseSilenceMatcher(`alertname="HighLatency"`)
+	require.NoError(t, err)
+
+	silences, notifications := filterFullState(parts, stateFilter{
+		now:                     now,
+		dropExpiredSilences:     true,
+		dropSilenceMatchers:     []*silencepb.Matcher{matcher},
+		dropNotificationsBefore: now.Add(-24 * time.Hour),
+	})
+	assert.Equal(t, 2, silences)
+	assert.Equal(t, 1, notifications)
+
+	var ids []string
+	for _, s := range parts[0].silences {
+		ids = append(ids, s.Silence.Id)
+	}
+	assert.Equal(t, []string{"regex", "active"}, ids)
+	require.Len(t, parts[1].notifications, 1)
+	assert.Equal(t, "recent", string(parts[1].notifications[0].Entry.GroupKey))
+
+	encoded, err := encodeFullState(parts)
+	require.NoError(t, err)
+	require.NoError(t, verifyFullState(parts, encoded))
+
+	decoded, err := decodeFullState(encoded)
+	require.NoError(t, err)
+	require.Len(t, decoded, 3)
+	assert.Len(t, decoded[0].silences, 2)
+	assert.Len(t, decoded[1].notifications, 1)
+	assert.Equal(t, []byte("opaque"), decoded[2].data)
+
+	// Dropping a silence after encoding must fail the verification.
+	parts[0].silences = parts[0].silences[:1]
+	assert.ErrorContains(t, verifyFullState(parts, encoded), "decoded 2 silences in part sil:user-1, expected 1")
+}
+
+func TestParseSilenceMatcher(t *testing.T) {
+	m, err := parseSilenceMatcher(`team!~"infra|db"`)
+	require.NoError(t, err)
+	assert.Equal(t, &silencepb.Matcher{Type: silencepb.Matcher_NOT_REGEXP, Name: "team", Pattern: "infra|db"}, m)
+
+	_, err = parseSilenceMatcher(`team`)
+	assert.Error(t, err)
+}
@@ -0,0 +1,195 @@
+package alertmanager
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/prometheus/alertmanager/config"
+	yaml "gopkg.in/yaml.v3"
+)
+
+// ConfigFile is the content of a config file, or of a fragment of a config.
+type ConfigFile struct {
+	Name    string
+	Content string
+}
+
+// fragmentKeys are the sections of a config fragments can hold.
+const fragmentKeys = "routes, receivers, inhibit_rules, time_intervals and templates"
+
+// BuildConfig merges fragments of config owned by teams in a base config. The
+// routes of the fragments are appended, in order, to the routes of the root
+// route of the base config, and their receivers, inhibit rules, time intervals
+// and templates to the ones of the base config. Receivers and time intervals
+// must be defined once, and the resulting config must be valid.
+func BuildConfig(base ConfigFile, fragments []ConfigFile) (string, []Problem) {
+	var problems []Problem
+	add := func(file string, line, column int, format string, args ...interface{}) {
+		problems = append(problems, Problem{File: file, Line: line, Column: column, Message: fmt.Sprintf(format, args...)})
+	}
+
+	var root yaml.Node
+	if err := yaml.Unmarshal([]byte(base.Content), &root); err != nil {
+		add(base.Name, errorLine(yamlLineRe, err), 0, "%s", err)
+		return "", problems
+	}
+	if len(root.Content) == 0 || root.Content[0].Kind != yaml.MappingNode {
+		add(base.Name, 0, 0, "the base config must be a mapping")
+		return "", problems
+	}
+	doc := root.Content[0]
+	route := mappingValue(doc, "route")
+	if route == nil || route.Kind != yaml.MappingNode {
+		add(base.Name, 0, 0, "the base config has no route")
+		return "", problems
+	}
+
+	for _, section := range []struct {
+		node *yaml.Node
+		key  string
+	}{{route, "routes"}, {doc, "receivers"}, {doc, "inhibit_rules"}, {doc, "time_intervals"}, {doc, "templates"}} {
+		if seq := mappingValue(section.node, section.key); seq != nil && seq.Kind != yaml.SequenceNode && seq.Tag != "!!null" {
+			add(base.Name, seq.Line, seq.Column, "%s: must be a list", section.key)
+		}
+	}
+	if len(problems) != 0 {
+		return "", problems
+	}
+
+	// The files defining every receiver and time interval, to report
+	// collisions.
+	owners := map[string]map[string]string{"receivers": {}, "time_intervals": {}}
+	for section, names := range owners {
+		if seq := mappingValue(doc, section); seq != nil {
+			for _, item := range seq.Content {
+				if name := mappingValue(item, "name"); name != nil {
+					names[name.Value] = base.Name
+				}
+			}
+		}
+	}
+
+	templates := map[string]struct{}{}
+	if seq := mappingValue(doc, "templates"); seq != nil {
+		for _, item := range seq.Content {
+			templates[item.Value] = struct{}{}
+		}
+	}
+
+	for _, f := range fragments {
+		var fragment yaml.Node
+		if err := yaml.Unmarshal([]byte(f.Content), &fragment); err != nil {
+			add(f.Name, errorLine(yamlLineRe, err), 0, "%s", err)
+			continue
+		}
+		if len(fragment.Content) == 0 {
+			continue
+		}
+		if fragment.Content[0].Kind != yaml.MappingNode {
+			add(f.Name, 0, 0, "the fragment must be a mapping")
+			continue
+		}
+
+		m := fragment.Content[0]
+		for i := 0; i+1 < len(m.Content); i += 2 {
+			key, value := m.Content[i], m.Content[i+1]
+			switch key.Value {
+			case "routes", "receivers", "inhibit_rules", "time_intervals", "templates":
+			default:
+				add(f.Name, key.Line, key.Column, "%s: fragments can only hold %s", key.Value, fragmentKeys)
+				continue
+			}
+			if value.Kind != yaml.SequenceNode {
+				add(f.Name, key.Line, key.Column, "%s: must be a list", key.Value)
+				continue
+			}
+
+			switch key.Value {
+			case "routes":
+				seq := ensureSequence(route, "routes")
+				seq.Content = append(seq.Content, value.Content...)
+
+			case "receivers", "time_intervals":
+				seq := ensureSequence(doc, key.Value)
+				for _, item := range value.Content {
+					name := mappingValue(item, "name")
+					if name == nil {
+						add(f.Name, item.Line, item.Column, "%s: missing name", key.Value)
+						continue
+					}
+					if owner, ok := owners[key.Value][name.Value]; ok {
+						add(f.Name, name.Line, name.Column, "%s: %q is already defined in %s", key.Value, name.Value, owner)
+						continue
+					}
+					owners[key.Value][name.Value] = f.Name
+					seq.Content = append(seq.Content, item)
+				}
+
+			case "inhibit_rules":
+				seq := ensureSequence(doc, key.Value)
+				seq.Content = append(seq.Content, value.Content...)
+
+			case "templates":
+				seq := ensureSequence(doc, key.Value)
+				for _, item := range value.Content {
+					if _, ok := templates[item.Value]; ok {
+						continue
+					}
+					templates[item.Value] = struct{}{}
+					seq.Content = append(seq.Content, item)
+				}
+			}
+		}
+	}
+	if len(problems) != 0 {
+		return "", problems
+	}
+
+	var b bytes.Buffer
+	enc := yaml.NewEncoder(&b)
+	enc.SetIndent(2)
+	if err := enc.Encode(&root); err != nil {
+		add(base.Name, 0, 0, "unable to marshal the config: %s", err)
+		return "", problems
+	}
+	if err := enc.Close(); err != nil {
+		add(base.Name, 0, 0, "unable to marshal the config: %s", err)
+		return "", problems
+	}
+
+	out := b.String()
+	if _, err := config.Load(out); err != nil {
+		add(base.Name, 0, 0, "invalid config after merging the fragments: %s", err)
+		return "", problems
+	}
+	return out, nil
+}
+
+// mappingValue returns the value of a key of a mapping node, or nil.
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// ensureSequence returns the sequence of a key of a mapping node, adding it if
+// it is missing or null. The sequence is in block style, as items are appended
+// to it.
+func ensureSequence(node *yaml.Node, key string) *yaml.Node {
+	if seq := mappingValue(node, key); seq != nil {
+		if seq.Kind != yaml.SequenceNode {
+			*seq = yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		}
+		seq.Style = 0
+		return seq
+	}
+	seq := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+	node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, seq)
+	return seq
+}
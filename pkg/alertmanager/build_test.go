@@ -0,0 +1,76 @@
+package alertmanager
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const buildBase = `route:
+  receiver: default
+  routes: []
+receivers:
+  - name: default
+templates:
+  - base.tmpl
+`
+
+func TestBuildConfig(t *testing.T) {
+	cfg, problems := BuildConfig(ConfigFile{Name: "base.yaml", Content: buildBase}, []ConfigFile{
+		{Name: "db.yaml", Content: `routes:
+  - matchers: [team="db"]
+    receiver: db
+receivers:
+  - name: db
+templates: [db.tmpl, base.tmpl]
+`},
+		{Name: "web.yaml", Content: `routes:
+  - matchers: [team="web"]
+    receiver: web
+receivers:
+  - name: web
+inhibit_rules:
+  - source_matchers: [severity="critical"]
+    target_matchers: [severity="warning"]
+`},
+	})
+	assert.Empty(t, problems)
+	assert.Equal(t, `route:
+  receiver: default
+  routes:
+    - matchers: [team="db"]
+      receiver: db
+    - matchers: [team="web"]
+      receiver: web
+receivers:
+  - name: default
+  - name: db
+  - name: web
+templates:
+  - base.tmpl
+  - db.tmpl
+inhibit_rules:
+  - source_matchers: [severity="critical"]
+    target_matchers: [severity="warning"]
+`, cfg)
+}
+
+func TestBuildConfigProblems(t *testing.T) {
+	_, problems := BuildConfig(ConfigFile{Name: "base.yaml", Content: buildBase}, []ConfigFile{
+		{Name: "db.yaml", Content: "receivers:\n  - name: default\nglobal: {}\n"},
+		{Name: "web.yaml", Content: "routes: {}\n"},
+	})
+	assert.Equal(t, []Problem{
+		{File: "db.yaml", Line: 2, Column: 11, Message: `receivers: "default" is already defined in base.yaml`},
+		{File: "db.yaml", Line: 3, Column: 1, Message: "global: fragments can only hold routes, receivers, inhibit_rules, time_intervals and templates"},
+		{File: "web.yaml", Line: 1, Column: 1, Message: "routes: must be a list"},
+	}, problems)
+
+	// The merged config must be valid.
+	_, problems = BuildConfig(ConfigFile{Name: "base.yaml", Content: buildBase}, []ConfigFile{
+		{Name: "db.yaml", Content: "routes:\n  - receiver: unknown\n"},
+	})
+	assert.Equal(t, []Problem{
+		{File: "base.yaml", Message: `invalid config after merging the fragments: undefined receiver "unknown" used in route`},
+	}, problems)
+}
@@ -0,0 +1,167 @@
+package alertmanager
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/alertmanager/config"
+	yaml "gopkg.in/yaml.v3"
+)
+
+// unsupportedKeys are the settings of Alertmanager rejected by Cortex, besides
+// local files.
+var unsupportedKeys = map[string]struct{}{
+	"proxy_url":              {},
+	"proxy_from_environment": {},
+	"proxy_connect_header":   {},
+	"no_proxy":               {},
+}
+
+// ConvertedConfig is a config and its templates, ready to be uploaded to
+// Cortex.
+type ConvertedConfig struct {
+	Config string
+	// Templates are the template files matched by the templates globs of the
+	// config, keyed by file name.
+	Templates map[string]string
+	// Warnings are the settings Cortex doesn't support, left as they are.
+	Warnings []Problem
+}
+
+// ConvertConfig converts the config of a standalone Alertmanager into a config
+// Cortex accepts. The files referenced by *_file fields, as in password_file,
+// are inlined in the fields without the suffix, and the template files matched
+// by the templates globs are loaded, the globs only keeping their file name.
+// Relative paths are relative to the directory of the config.
+func ConvertConfig(configFile string) (*ConvertedConfig, error) {
+	content, err := os.ReadFile(configFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to read config file")
+	}
+	dir := filepath.Dir(configFile)
+	resolve := func(p string) string {
+		if filepath.IsAbs(p) {
+			return p
+		}
+		return filepath.Join(dir, p)
+	}
+
+	var root yaml.Node
+	if err := yaml.Unmarshal(content, &root); err != nil {
+		return nil, errors.Wrapf(err, "unable to parse config file %s", configFile)
+	}
+	if len(root.Content) == 0 || root.Content[0].Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("config file %s must be a mapping", configFile)
+	}
+
+	converted := &ConvertedConfig{Templates: map[string]string{}}
+	warn := func(node *yaml.Node, format string, args ...interface{}) {
+		converted.Warnings = append(converted.Warnings, Problem{File: configFile, Line: node.Line, Column: node.Column, Message: fmt.Sprintf(format, args...)})
+	}
+
+	var walk func(node *yaml.Node) error
+	walk = func(node *yaml.Node) error {
+		switch node.Kind {
+		case yaml.SequenceNode:
+			for _, child := range node.Content {
+				if err := walk(child); err != nil {
+					return err
+				}
+			}
+
+		case yaml.MappingNode:
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				key, value := node.Content[i], node.Content[i+1]
+
+				if _, ok := unsupportedKeys[key.Value]; ok {
+					warn(key, "%s: not supported by cortex, the config will be rejected", key.Value)
+				}
+
+				if strings.HasSuffix(key.Value, "_file") && value.Kind == yaml.ScalarNode && value.Value != "" {
+					field := strings.TrimSuffix(key.Value, "_file")
+					if mappingValue(node, field) != nil {
+						return fmt.Errorf("%s:%d: both %s and %s are set", configFile, key.Line, field, key.Value)
+					}
+					secret, err := os.ReadFile(resolve(value.Value))
+					if err != nil {
+						return errors.Wrapf(err, "%s:%d: unable to inline %s", configFile, key.Line, key.Value)
+					}
+					key.Value = field
+					*value = yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: strings.TrimSpace(string(secret))}
+					if strings.Contains(value.Value, "\n") {
+						value.Style = yaml.LiteralStyle
+					}
+					continue
+				}
+
+				if err := walk(value); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	doc := root.Content[0]
+	if err := walk(doc); err != nil {
+		return nil, err
+	}
+
+	if templates := mappingValue(doc, "templates"); templates != nil && templates.Kind == yaml.SequenceNode {
+		var globs []string
+		for _, glob := range templates.Content {
+			files, err := filepath.Glob(resolve(glob.Value))
+			if err != nil {
+				return nil, errors.Wrapf(err, "%s:%d: invalid templates glob", configFile, glob.Line)
+			}
+			if len(files) == 0 {
+				warn(glob, "templates: %q matches no file", glob.Value)
+			}
+			for _, f := range files {
+				tmpl, err := os.ReadFile(f)
+				if err != nil {
+					return nil, errors.Wrap(err, "unable to read template file")
+				}
+				name := filepath.Base(f)
+				if existing, ok := converted.Templates[name]; ok && existing != string(tmpl) {
+					return nil, fmt.Errorf("%s:%d: templates: different template files are named %s", configFile, glob.Line, name)
+				}
+				converted.Templates[name] = string(tmpl)
+			}
+			globs = append(globs, filepath.Base(glob.Value))
+		}
+
+		sort.Strings(globs)
+		templates.Content = templates.Content[:0]
+		templates.Style = 0
+		for i, glob := range globs {
+			if i > 0 && glob == globs[i-1] {
+				continue
+			}
+			templates.Content = append(templates.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: glob})
+		}
+	}
+
+	var b bytes.Buffer
+	enc := yaml.NewEncoder(&b)
+	enc.SetIndent(2)
+	if err := enc.Encode(&root); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	converted.Config = b.String()
+	sort.SliceStable(converted.Warnings, func(i, j int) bool {
+		return converted.Warnings[i].Line < converted.Warnings[j].Line
+	})
+
+	if _, err := config.Load(converted.Config); err != nil {
+		return nil, errors.Wrap(err, "invalid converted config")
+	}
+	return converted, nil
+}
@@ -0,0 +1,83 @@
+package alertmanager
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConvertConfig(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		require.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0o700))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600))
+	}
+	write("secrets/smtp", "hunter2\n")
+	write("secrets/token", "token\n")
+	write("templates/slack.tmpl", `{{ define "slack.title" }}title{{ end }}`)
+	write("templates/email.tmpl", `{{ define "email.subject" }}subject{{ end }}`)
+	write("alertmanager.yml", `global:
+  smtp_smarthost: smtp:25
+  smtp_from: am@example.com
+  smtp_auth_password_file: secrets/smtp
+templates: ['templates/*.tmpl', '/etc/alertmanager/*.tmpl']
+route:
+  receiver: webhook
+receivers:
+  - name: webhook
+    webhook_configs:
+      - url: http://example.com
+        http_config:
+          bearer_token_file: `+filepath.Join(dir, "secrets/token")+`
+          proxy_url: http://proxy:3128
+`)
+
+	converted, err := ConvertConfig(filepath.Join(dir, "alertmanager.yml"))
+	require.NoError(t, err)
+
+	assert.Equal(t, `global:
+  smtp_smarthost: smtp:25
+  smtp_from: am@example.com
+  smtp_auth_password: hunter2
+templates:
+  - '*.tmpl'
+route:
+  receiver: webhook
+receivers:
+  - name: webhook
+    webhook_configs:
+      - url: http://example.com
+        http_config:
+          bearer_token: token
+          proxy_url: http://proxy:3128
+`, converted.Config)
+	assert.Equal(t, map[string]string{
+		"slack.tmpl": `{{ define "slack.title" }}title{{ end }}`,
+		"email.tmpl": `{{ define "email.subject" }}subject{{ end }}`,
+	}, converted.Templates)
+
+	var warnings []string
+	for _, w := range converted.Warnings {
+		warnings = append(warnings, w.Message)
+	}
+	assert.Equal(t, []string{
+		`templates: "/etc/alertmanager/*.tmpl" matches no file`,
+		"proxy_url: not supported by cortex, the config will be rejected",
+	}, warnings)
+}
+
+func TestConvertConfigErrors(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "alertmanager.yml")
+
+	require.NoError(t, os.WriteFile(file, []byte("global:\n  smtp_auth_password_file: missing\nroute:\n  receiver: a\nreceivers:\n  - name: a\n"), 0o600))
+	_, err := ConvertConfig(file)
+	assert.ErrorContains(t, err, "unable to inline smtp_auth_password_file")
+
+	require.NoError(t, os.WriteFile(file, []byte("global:\n  smtp_auth_password: a\n  smtp_auth_password_file: b\n"), 0o600))
+	_, err = ConvertConfig(file)
+	assert.ErrorContains(t, err, "both smtp_auth_password and smtp_auth_password_file are set")
+}
@@ -0,0 +1,277 @@
+package alertmanager
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/pmezard/go-difflib/difflib"
+	"github.com/prometheus/alertmanager/config"
+	yaml "gopkg.in/yaml.v3"
+)
+
+// Kinds of config changes.
+const (
+	Added   = "added"
+	Removed = "removed"
+	Changed = "changed"
+)
+
+// Sections of the config which are diffed as a whole.
+var diffedSections = []string{"global", "templates", "inhibit_rules", "mute_time_intervals", "time_intervals"}
+
+// ConfigChange is a difference between two Alertmanager configs and their
+// templates.
+type ConfigChange struct {
+	Kind string `json:"kind"`
+	// Section is the part of the config which changed: route, receiver,
+	// template, or a top-level key of the config.
+	Section string `json:"section"`
+	// Name is the name of the receiver or template.
+	Name string `json:"name,omitempty"`
+	// Diff is the unified diff of the section, with the secrets redacted.
+	Diff string `json:"diff,omitempty"`
+	// SecretsOnly is set if only the redacted secrets of the section changed.
+	SecretsOnly bool `json:"secretsOnly,omitempty"`
+}
+
+func (c ConfigChange) String() string {
+	if c.Name == "" {
+		return fmt.Sprintf("%s %s", c.Section, c.Kind)
+	}
+	return fmt.Sprintf("%s %s %s", c.Section, c.Name, c.Kind)
+}
+
+// parsedConfig is a config both as loaded by Alertmanager, with its secrets
+// redacted when marshalled, and as plain YAML, to compare the secrets too.
+type parsedConfig struct {
+	cfg *config.Config
+	raw map[string]interface{}
+}
+
+func parseConfig(s string) (*parsedConfig, error) {
+	if strings.TrimSpace(s) == "" {
+		return &parsedConfig{cfg: &config.Config{}, raw: map[string]interface{}{}}, nil
+	}
+	cfg, err := config.Load(s)
+	if err != nil {
+		return nil, err
+	}
+	raw := map[string]interface{}{}
+	if err := yaml.Unmarshal([]byte(s), &raw); err != nil {
+		return nil, err
+	}
+	return &parsedConfig{cfg: cfg, raw: raw}, nil
+}
+
+// DiffConfigs returns the semantic differences between the current config and
+// templates and the desired ones: the route tree, the added, removed and
+// changed receivers and templates, and the other top-level sections of the
+// config. Formatting and key order are ignored. An empty config is a missing
+// config.
+func DiffConfigs(current string, currentTemplates map[string]string, desired string, desiredTemplates map[string]string) ([]ConfigChange, error) {
+	from, err := parseConfig(current)
+	if err != nil {
+		return nil, fmt.Errorf("invalid current config: %w", err)
+	}
+	to, err := parseConfig(desired)
+	if err != nil {
+		return nil, fmt.Errorf("invalid desired config: %w", err)
+	}
+
+	var changes []ConfigChange
+
+	if !reflect.DeepEqual(from.raw["route"], to.raw["route"]) {
+		changes = append(changes, ConfigChange{
+			Kind:    changeKind(from.raw["route"] != nil, to.raw["route"] != nil),
+			Section: "route",
+			Diff:    unifiedDiff("route", formatRouteTree(from.raw["route"]), formatRouteTree(to.raw["route"])),
+		})
+	}
+
+	fromReceivers, toReceivers := rawReceivers(from.raw), rawReceivers(to.raw)
+	for _, name := range unionKeys(fromReceivers, toReceivers) {
+		if reflect.DeepEqual(fromReceivers[name], toReceivers[name]) {
+			continue
+		}
+		_, inFrom := fromReceivers[name]
+		_, inTo := toReceivers[name]
+		changes = append(changes, redactedChange(changeKind(inFrom, inTo), "receiver", name, receiver(from.cfg, name), receiver(to.cfg, name)))
+	}
+
+	for _, section := range diffedSections {
+		if reflect.DeepEqual(from.raw[section], to.raw[section]) {
+			continue
+		}
+		changes = append(changes, redactedChange(changeKind(from.raw[section] != nil, to.raw[section] != nil), section, "", configSection(from.cfg, section), configSection(to.cfg, section)))
+	}
+
+	for _, name := range unionKeys(currentTemplates, desiredTemplates) {
+		before, inFrom := currentTemplates[name]
+		after, inTo := desiredTemplates[name]
+		if inFrom && inTo && before == after {
+			continue
+		}
+		changes = append(changes, ConfigChange{
+			Kind:    changeKind(inFrom, inTo),
+			Section: "template",
+			Name:    name,
+			Diff:    unifiedDiff(name, before, after),
+		})
+	}
+
+	return changes, nil
+}
+
+// redactedChange returns the change of a section, diffing its redacted YAML.
+func redactedChange(kind, section, name string, before, after interface{}) ConfigChange {
+	change := ConfigChange{Kind: kind, Section: section, Name: name}
+	label := section
+	if name != "" {
+		label = section + " " + name
+	}
+	change.Diff = unifiedDiff(label, marshalSection(before), marshalSection(after))
+	change.SecretsOnly = change.Diff == ""
+	return change
+}
+
+func changeKind(before, after bool) string {
+	switch {
+	case !before:
+		return Added
+	case !after:
+		return Removed
+	default:
+		return Changed
+	}
+}
+
+// rawReceivers returns the plain receivers of a config by name.
+func rawReceivers(raw map[string]interface{}) map[string]interface{} {
+	receivers := map[string]interface{}{}
+	list, _ := raw["receivers"].([]interface{})
+	for _, r := range list {
+		if m, ok := r.(map[string]interface{}); ok {
+			name, _ := m["name"].(string)
+			receivers[name] = m
+		}
+	}
+	return receivers
+}
+
+func receiver(cfg *config.Config, name string) interface{} {
+	for _, r := range cfg.Receivers {
+		if r.Name == name {
+			return r
+		}
+	}
+	return nil
+}
+
+func configSection(cfg *config.Config, section string) interface{} {
+	switch section {
+	case "global":
+		if cfg.Global != nil {
+			return cfg.Global
+		}
+	case "templates":
+		if len(cfg.Templates) > 0 {
+			return cfg.Templates
+		}
+	case "inhibit_rules":
+		if len(cfg.InhibitRules) > 0 {
+			return cfg.InhibitRules
+		}
+	case "mute_time_intervals":
+		if len(cfg.MuteTimeIntervals) > 0 {
+			return cfg.MuteTimeIntervals
+		}
+	case "time_intervals":
+		if len(cfg.TimeIntervals) > 0 {
+			return cfg.TimeIntervals
+		}
+	}
+	return nil
+}
+
+// marshalSection returns the YAML of a part of a config, with the secrets
+// redacted by their types.
+func marshalSection(v interface{}) string {
+	if v == nil || reflect.ValueOf(v).Kind() == reflect.Ptr && reflect.ValueOf(v).IsNil() {
+		return ""
+	}
+	out, err := yaml.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("<unable to marshal: %s>\n", err)
+	}
+	return string(out)
+}
+
+// formatRouteTree returns a route and its children, one per line, indented by
+// depth, so that diffs show which routes changed.
+func formatRouteTree(route interface{}) string {
+	var b strings.Builder
+	var format func(route map[string]interface{}, depth int)
+	format = func(route map[string]interface{}, depth int) {
+		settings := make(map[string]interface{}, len(route))
+		for k, v := range route {
+			if k != "routes" {
+				settings[k] = v
+			}
+		}
+		out, _ := json.Marshal(settings)
+		fmt.Fprintf(&b, "%s%s\n", strings.Repeat("  ", depth), out)
+
+		children, _ := route["routes"].([]interface{})
+		for _, child := range children {
+			if m, ok := child.(map[string]interface{}); ok {
+				format(m, depth+1)
+			}
+		}
+	}
+	if m, ok := route.(map[string]interface{}); ok {
+		format(m, 0)
+	}
+	return b.String()
+}
+
+func unifiedDiff(name, before, after string) string {
+	diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        splitLines(before),
+		B:        splitLines(after),
+		FromFile: name,
+		ToFile:   name,
+		Context:  3,
+	})
+	if err != nil {
+		return fmt.Sprintf("<unable to diff: %s>\n", err)
+	}
+	return diff
+}
+
+// splitLines splits text in lines, without an empty line for the final new
+// line.
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return difflib.SplitLines(strings.TrimSuffix(s, "\n"))
+}
+
+func unionKeys[V any](a, b map[string]V) []string {
+	set := make(map[string]struct{}, len(a)+len(b))
+	for k := range a {
+		set[k] = struct{}{}
+	}
+	for k := range b {
+		set[k] = struct{}{}
+	}
+	keys := make([]string, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
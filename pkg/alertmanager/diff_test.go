@@ -0,0 +1,98 @@
+package alertmanager
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const diffConfig = `route:
+  receiver: team
+  routes:
+    - matchers: ['severity="critical"']
+      receiver: pager
+receivers:
+  - name: team
+    webhook_configs:
+      - url: http://example.com/a
+  - name: pager
+    pagerduty_configs:
+      - routing_key: secret
+`
+
+func TestDiffConfigs(t *testing.T) {
+	desired := `receivers:
+  - name: pager
+    pagerduty_configs:
+      - routing_key: other-secret
+  - name: team
+    webhook_configs:
+      - url: http://example.com/a
+        max_alerts: 10
+  - name: new
+    webhook_configs:
+      - url: http://example.com/new
+route:
+  receiver: team
+  routes:
+    - matchers: ['severity="critical"']
+      receiver: pager
+      continue: true
+`
+	changes, err := DiffConfigs(diffConfig, map[string]string{"a.tmpl": "a\n", "b.tmpl": "b\n"}, desired, map[string]string{"a.tmpl": "a\n", "c.tmpl": "c\n"})
+	require.NoError(t, err)
+
+	var got []string
+	for _, c := range changes {
+		got = append(got, c.String())
+	}
+	assert.Equal(t, []string{
+		"route changed",
+		"receiver new added",
+		"receiver pager changed",
+		"receiver team changed",
+		"template b.tmpl removed",
+		"template c.tmpl added",
+	}, got)
+
+	assert.Equal(t, `--- route
++++ route
+@@ -1,2 +1,2 @@
+ {"receiver":"team"}
+-  {"matchers":["severity=\"critical\""],"receiver":"pager"}
++  {"continue":true,"matchers":["severity=\"critical\""],"receiver":"pager"}
+`, changes[0].Diff)
+
+	// The secrets are redacted, only their change is reported.
+	assert.NotContains(t, changes[1].Diff, "example.com/new")
+	assert.True(t, changes[2].SecretsOnly)
+	assert.Empty(t, changes[2].Diff)
+	assert.Contains(t, changes[3].Diff, "+      max_alerts: 10")
+}
+
+func TestDiffConfigsUnchanged(t *testing.T) {
+	// Formatting and key order are ignored.
+	desired := `receivers:
+- name: pager
+  pagerduty_configs: [{routing_key: secret}]
+- webhook_configs: [{url: "http://example.com/a"}]
+  name: team
+route: {routes: [{receiver: pager, matchers: ['severity="critical"']}], receiver: team}
+`
+	changes, err := DiffConfigs(diffConfig, map[string]string{"a.tmpl": "a"}, desired, map[string]string{"a.tmpl": "a"})
+	require.NoError(t, err)
+	assert.Empty(t, changes)
+}
+
+func TestDiffConfigsMissing(t *testing.T) {
+	changes, err := DiffConfigs("", nil, diffConfig, nil)
+	require.NoError(t, err)
+	for _, c := range changes {
+		assert.Equal(t, Added, c.Kind)
+	}
+	assert.Len(t, changes, 3)
+
+	_, err = DiffConfigs("route: {receiver: unknown}", nil, diffConfig, nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,121 @@
+package alertmanager
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+
+	"github.com/prometheus/alertmanager/config"
+	commoncfg "github.com/prometheus/common/config"
+	yaml "gopkg.in/yaml.v3"
+)
+
+// redacted replaces the values of secrets.
+const redacted = "<secret>"
+
+var secretTypes = map[reflect.Type]struct{}{
+	reflect.TypeOf(config.Secret("")):    {},
+	reflect.TypeOf(config.SecretURL{}):   {},
+	reflect.TypeOf(commoncfg.Secret("")): {},
+}
+
+// RedactSecrets returns the config with the values of its secret fields, as
+// typed by Alertmanager, replaced by <secret>. The formatting and comments of
+// the config are kept.
+func RedactSecrets(cfg string) (string, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal([]byte(cfg), &root); err != nil {
+		return "", err
+	}
+	if len(root.Content) == 0 {
+		return cfg, nil
+	}
+	redactNode(root.Content[0], reflect.TypeOf(config.Config{}))
+
+	var b bytes.Buffer
+	enc := yaml.NewEncoder(&b)
+	enc.SetIndent(2)
+	if err := enc.Encode(&root); err != nil {
+		return "", err
+	}
+	if err := enc.Close(); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// redactNode redacts the secrets of a node holding a value of the type.
+func redactNode(node *yaml.Node, t reflect.Type) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if _, ok := secretTypes[t]; ok {
+		redactScalars(node)
+		return
+	}
+
+	switch t.Kind() {
+	case reflect.Slice, reflect.Array:
+		if node.Kind == yaml.SequenceNode {
+			for _, child := range node.Content {
+				redactNode(child, t.Elem())
+			}
+		}
+	case reflect.Map:
+		if node.Kind == yaml.MappingNode {
+			for i := 1; i < len(node.Content); i += 2 {
+				redactNode(node.Content[i], t.Elem())
+			}
+		}
+	case reflect.Struct:
+		if node.Kind != yaml.MappingNode {
+			return
+		}
+		fields := yamlFields(t)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if ft, ok := fields[node.Content[i].Value]; ok {
+				redactNode(node.Content[i+1], ft)
+			}
+		}
+	}
+}
+
+// redactScalars redacts the scalars of a node, the secrets being single values
+// or lists and maps of them.
+func redactScalars(node *yaml.Node) {
+	if node.Kind == yaml.ScalarNode {
+		if node.Value != "" {
+			node.Tag, node.Value, node.Style = "!!str", redacted, 0
+		}
+		return
+	}
+	for _, child := range node.Content {
+		redactScalars(child)
+	}
+}
+
+// yamlFields returns the types of the fields of a struct by YAML key,
+// including the fields of inlined structs.
+func yamlFields(t reflect.Type) map[string]reflect.Type {
+	fields := map[string]reflect.Type{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, opts, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+		switch {
+		case name == "-":
+		case name == "" && strings.Contains(opts, "inline"):
+			ft := f.Type
+			for ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				for k, v := range yamlFields(ft) {
+					fields[k] = v
+				}
+			}
+		case name != "":
+			fields[name] = f.Type
+		}
+	}
+	return fields
+}
@@ -0,0 +1,53 @@
+package alertmanager
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedactSecrets(t *testing.T) {
+	cfg := `# Managed by the platform team.
+global:
+  smtp_auth_password: hunter2
+  slack_api_url: https://hooks.slack.com/services/T0/B0/XXXX
+route:
+  receiver: team
+receivers:
+  - name: team
+    webhook_configs:
+      - url: http://example.com/token # the hook
+        http_config:
+          basic_auth: {username: user, password: password}
+          proxy_connect_header: {X-Token: [a, b]}
+    pagerduty_configs:
+      - routing_key: key
+        url: https://events.pagerduty.com/v2/enqueue
+    sns_configs:
+      - sigv4: {access_key: access, secret_key: secret}
+        topic_arn: arn
+`
+	redactedCfg, err := RedactSecrets(cfg)
+	require.NoError(t, err)
+	assert.Equal(t, `# Managed by the platform team.
+global:
+  smtp_auth_password: <secret>
+  slack_api_url: <secret>
+route:
+  receiver: team
+receivers:
+  - name: team
+    webhook_configs:
+      - url: <secret> # the hook
+        http_config:
+          basic_auth: {username: user, password: <secret>}
+          proxy_connect_header: {X-Token: [<secret>, <secret>]}
+    pagerduty_configs:
+      - routing_key: <secret>
+        url: https://events.pagerduty.com/v2/enqueue
+    sns_configs:
+      - sigv4: {access_key: access, secret_key: <secret>}
+        topic_arn: arn
+`, redactedCfg)
+}
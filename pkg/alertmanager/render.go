@@ -0,0 +1,182 @@
+package alertmanager
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/alertmanager/config"
+	"github.com/prometheus/alertmanager/template"
+	"github.com/prometheus/alertmanager/types"
+	"github.com/prometheus/common/model"
+	yaml "gopkg.in/yaml.v3"
+
+	"github.com/cortexproject/cortex-tools/pkg/util"
+)
+
+// RenderedTemplate is the output of a template, or the error executing it.
+type RenderedTemplate struct {
+	// Name is the name of the template, or the path of the receiver field, as
+	// in slack_configs[0].title.
+	Name   string `json:"name"`
+	Output string `json:"output"`
+	Error  string `json:"error,omitempty"`
+}
+
+// LoadSampleAlerts loads alerts from a JSON file, in the format returned by
+// the Alertmanager API.
+func LoadSampleAlerts(filename string) ([]model.Alert, error) {
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to read alerts file")
+	}
+
+	var alerts []model.Alert
+	if err := json.Unmarshal(content, &alerts); err != nil {
+		return nil, errors.Wrapf(err, "unable to parse alerts file %s", filename)
+	}
+	for i, a := range alerts {
+		if len(a.Labels) == 0 {
+			return nil, fmt.Errorf("alert %d of %s has no labels", i+1, filename)
+		}
+	}
+	return alerts, nil
+}
+
+// NewTemplate returns the default Alertmanager templates and the template
+// files, keyed by file name.
+func NewTemplate(templates map[string]string, externalURL *url.URL) (*template.Template, error) {
+	tmpl, err := template.FromGlobs(nil)
+	if err != nil {
+		return nil, err
+	}
+	tmpl.ExternalURL = externalURL
+
+	for _, name := range util.SortedKeys(templates) {
+		if err := tmpl.Parse(strings.NewReader(templates[name])); err != nil {
+			return nil, errors.Wrapf(err, "unable to parse template file %s", name)
+		}
+	}
+	return tmpl, nil
+}
+
+// TemplateData returns the data of a notification of the alerts to a receiver.
+// The alerts without an end are firing.
+func TemplateData(tmpl *template.Template, receiver string, groupLabels model.LabelSet, alerts []model.Alert) *template.Data {
+	now := time.Now()
+	typed := make([]*types.Alert, 0, len(alerts))
+	for _, a := range alerts {
+		a := a
+		if a.StartsAt.IsZero() {
+			a.StartsAt = now
+		}
+		typed = append(typed, &types.Alert{Alert: a, UpdatedAt: now})
+	}
+	return tmpl.Data(receiver, groupLabels, typed...)
+}
+
+// GroupLabels returns the labels an alert is grouped by, by the first route
+// it matches.
+func GroupLabels(route *Route, alert model.Alert) model.LabelSet {
+	matches := route.Match(alert.Labels)
+	if len(matches) == 0 {
+		return model.LabelSet{}
+	}
+	if matches[0].GroupByAll {
+		return alert.Labels.Clone()
+	}
+
+	groupLabels := model.LabelSet{}
+	for _, ln := range matches[0].GroupBy {
+		if v, ok := alert.Labels[model.LabelName(ln)]; ok {
+			groupLabels[model.LabelName(ln)] = v
+		}
+	}
+	return groupLabels
+}
+
+// RenderTemplate executes a named template with the data.
+func RenderTemplate(tmpl *template.Template, name string, data *template.Data) RenderedTemplate {
+	out, err := tmpl.ExecuteTextString(fmt.Sprintf("{{ template %q . }}", name), data)
+	return renderedTemplate(name, out, err)
+}
+
+// RenderReceiver executes the templated fields of a receiver of the config,
+// with the defaults of Alertmanager, in the order of the config.
+func RenderReceiver(tmpl *template.Template, cfg, receiver string, data *template.Data) ([]RenderedTemplate, error) {
+	c, err := config.Load(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	var recv *config.Receiver
+	for i := range c.Receivers {
+		if c.Receivers[i].Name == receiver {
+			recv = &c.Receivers[i]
+		}
+	}
+	if recv == nil {
+		return nil, fmt.Errorf("receiver %q not found", receiver)
+	}
+
+	// The receiver is walked as YAML, as every integration has its own
+	// fields.
+	var node yaml.Node
+	out, err := yaml.Marshal(recv)
+	if err != nil {
+		return nil, err
+	}
+	if err := yaml.Unmarshal(out, &node); err != nil {
+		return nil, err
+	}
+
+	var rendered []RenderedTemplate
+	var walk func(node *yaml.Node, path string)
+	walk = func(node *yaml.Node, path string) {
+		switch node.Kind {
+		case yaml.DocumentNode:
+			for _, child := range node.Content {
+				walk(child, path)
+			}
+		case yaml.SequenceNode:
+			for i, child := range node.Content {
+				walk(child, fmt.Sprintf("%s[%d]", path, i))
+			}
+		case yaml.MappingNode:
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				key := node.Content[i].Value
+				if path != "" {
+					key = path + "." + key
+				}
+				walk(node.Content[i+1], key)
+			}
+		case yaml.ScalarNode:
+			if !strings.Contains(node.Value, "{{") {
+				return
+			}
+			// Email bodies are HTML, and escaped as such.
+			var out string
+			var err error
+			if strings.HasSuffix(path, ".html") {
+				out, err = tmpl.ExecuteHTMLString(node.Value, data)
+			} else {
+				out, err = tmpl.ExecuteTextString(node.Value, data)
+			}
+			rendered = append(rendered, renderedTemplate(path, out, err))
+		}
+	}
+	walk(&node, "")
+	return rendered, nil
+}
+
+func renderedTemplate(name, out string, err error) RenderedTemplate {
+	r := RenderedTemplate{Name: name, Output: out}
+	if err != nil {
+		r.Error = err.Error()
+	}
+	return r
+}
@@ -0,0 +1,78 @@
+package alertmanager
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const renderConfig = `route:
+  receiver: slack
+  group_by: [alertname]
+receivers:
+  - name: slack
+    slack_configs:
+      - api_url: http://example.com
+        channel: '#alerts'
+        title: '{{ template "custom.title" . }}'
+        text: '{{ template "custom.text" . }}'
+`
+
+const renderTemplates = `{{ define "custom.title" }}[{{ .Status | toUpper }}] {{ .GroupLabels.alertname }} ({{ len .Alerts.Firing }}){{ end }}
+{{ define "custom.text" }}{{ range .Alerts }}{{ .Labels.job.name }}{{ end }}{{ end }}
+`
+
+func TestRenderReceiver(t *testing.T) {
+	tmpl, err := NewTemplate(map[string]string{"custom.tmpl": renderTemplates}, &url.URL{Scheme: "http", Host: "alertmanager"})
+	require.NoError(t, err)
+
+	route, err := LoadRoute(renderConfig)
+	require.NoError(t, err)
+
+	alerts := []model.Alert{{Labels: model.LabelSet{"alertname": "HighErrorRate", "job": "api"}}}
+	data := TemplateData(tmpl, "slack", GroupLabels(route, alerts[0]), alerts)
+
+	rendered, err := RenderReceiver(tmpl, renderConfig, "slack", data)
+	require.NoError(t, err)
+
+	byName := map[string]RenderedTemplate{}
+	for _, r := range rendered {
+		byName[r.Name] = r
+	}
+	assert.Equal(t, "[FIRING] HighErrorRate (1)", byName["slack_configs[0].title"].Output)
+	assert.Equal(t, "http://alertmanager/#/alerts?receiver=slack", byName["slack_configs[0].title_link"].Output)
+	assert.Contains(t, byName["slack_configs[0].text"].Error, "can't evaluate field name")
+
+	_, err = RenderReceiver(tmpl, renderConfig, "unknown", data)
+	assert.Error(t, err)
+}
+
+func TestRenderTemplate(t *testing.T) {
+	tmpl, err := NewTemplate(nil, &url.URL{})
+	require.NoError(t, err)
+
+	alerts := []model.Alert{{Labels: model.LabelSet{"alertname": "HighErrorRate"}}}
+	data := TemplateData(tmpl, "slack", model.LabelSet{"alertname": "HighErrorRate"}, alerts)
+
+	assert.Equal(t, RenderedTemplate{Name: "__subject", Output: "[FIRING:1] HighErrorRate "}, RenderTemplate(tmpl, "__subject", data))
+	assert.NotEmpty(t, RenderTemplate(tmpl, "undefined", data).Error)
+}
+
+func TestLoadSampleAlerts(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "alerts.json")
+	require.NoError(t, os.WriteFile(file, []byte(`[{"labels": {"alertname": "a"}, "annotations": {"summary": "s"}}]`), 0o600))
+
+	alerts, err := LoadSampleAlerts(file)
+	require.NoError(t, err)
+	assert.Equal(t, []model.Alert{{Labels: model.LabelSet{"alertname": "a"}, Annotations: model.LabelSet{"summary": "s"}}}, alerts)
+
+	require.NoError(t, os.WriteFile(file, []byte(`[{"annotations": {"summary": "s"}}]`), 0o600))
+	_, err = LoadSampleAlerts(file)
+	assert.Error(t, err)
+}
@@ -0,0 +1,288 @@
+package alertmanager
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/alertmanager/config"
+	"github.com/prometheus/alertmanager/pkg/labels"
+	"github.com/prometheus/common/model"
+	yaml "gopkg.in/yaml.v3"
+)
+
+// RouteMatch is a route matching a label set.
+type RouteMatch struct {
+	// Route is the path of matchers to the route, as in
+	// {}/{severity="critical"}.
+	Route    string   `json:"route"`
+	Receiver string   `json:"receiver"`
+	GroupBy  []string `json:"groupBy"`
+}
+
+// RouteTestFile is a file of routing test cases.
+type RouteTestFile struct {
+	Tests []RouteTestCase `yaml:"tests"`
+}
+
+// RouteTestCase is a label set and the receivers it must be routed to, in
+// order.
+type RouteTestCase struct {
+	Name      string            `yaml:"name,omitempty"`
+	Labels    map[string]string `yaml:"labels"`
+	Receivers []string          `yaml:"receivers"`
+}
+
+// RouteTestFailure is a test case whose label set isn't routed to the expected
+// receivers.
+type RouteTestFailure struct {
+	Name      string            `json:"name,omitempty"`
+	Labels    map[string]string `json:"labels"`
+	Expected  []string          `json:"expected"`
+	Receivers []string          `json:"receivers"`
+}
+
+func (f RouteTestFailure) String() string {
+	name := f.Name
+	if name == "" {
+		name = toLabelSet(f.Labels).String()
+	}
+	return fmt.Sprintf("%s: expected receivers %v, got %v", name, f.Expected, f.Receivers)
+}
+
+// LoadRouteTestFile loads a file of routing test cases.
+func LoadRouteTestFile(filename string) (*RouteTestFile, error) {
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to read route test file")
+	}
+
+	var f RouteTestFile
+	decoder := yaml.NewDecoder(strings.NewReader(string(content)))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&f); err != nil {
+		return nil, errors.Wrapf(err, "unable to parse route test file %s", filename)
+	}
+	for i, tc := range f.Tests {
+		if len(tc.Labels) == 0 {
+			return nil, fmt.Errorf("test %d of %s has no labels", i+1, filename)
+		}
+	}
+	return &f, nil
+}
+
+// Route is a node of the routing tree of an Alertmanager config. It mirrors
+// the route of the Alertmanager dispatch package, which can't be imported as it
+// depends on the Alertmanager cluster package, which doesn't build with the
+// memberlist fork used by Cortex.
+type Route struct {
+	parent *Route
+
+	Receiver            string
+	GroupBy             []string
+	GroupByAll          bool
+	MuteTimeIntervals   []string
+	ActiveTimeIntervals []string
+	Matchers            labels.Matchers
+	Continue            bool
+	Routes              []*Route
+}
+
+// LoadRoute returns the routing tree of an Alertmanager config.
+func LoadRoute(cfg string) (*Route, error) {
+	c, err := config.Load(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if c.Route == nil {
+		return nil, errors.New("the config has no route")
+	}
+	return newRoute(c.Route, nil)
+}
+
+// newRoute returns a route and its children, which inherit the receiver and
+// group by keys of their parent, as Alertmanager does.
+func newRoute(cr *config.Route, parent *Route) (*Route, error) {
+	r := &Route{parent: parent, GroupBy: []string{}}
+	if parent != nil {
+		r.Receiver, r.GroupBy, r.GroupByAll = parent.Receiver, parent.GroupBy, parent.GroupByAll
+	}
+	if cr.Receiver != "" {
+		r.Receiver = cr.Receiver
+	}
+	if cr.GroupBy != nil {
+		r.GroupBy = make([]string, 0, len(cr.GroupBy))
+		for _, ln := range cr.GroupBy {
+			r.GroupBy = append(r.GroupBy, string(ln))
+		}
+		sort.Strings(r.GroupBy)
+		r.GroupByAll = false
+	} else if cr.GroupByAll {
+		r.GroupByAll = true
+	}
+
+	// The deprecated match and match_re are matchers too.
+	for ln, lv := range cr.Match {
+		m, err := labels.NewMatcher(labels.MatchEqual, ln, lv)
+		if err != nil {
+			return nil, err
+		}
+		r.Matchers = append(r.Matchers, m)
+	}
+	for ln, lv := range cr.MatchRE {
+		m, err := labels.NewMatcher(labels.MatchRegexp, ln, lv.String())
+		if err != nil {
+			return nil, err
+		}
+		r.Matchers = append(r.Matchers, m)
+	}
+	r.Matchers = append(r.Matchers, cr.Matchers...)
+	sort.Sort(r.Matchers)
+
+	r.MuteTimeIntervals = cr.MuteTimeIntervals
+	r.ActiveTimeIntervals = cr.ActiveTimeIntervals
+	r.Continue = cr.Continue
+
+	for _, child := range cr.Routes {
+		c, err := newRoute(child, r)
+		if err != nil {
+			return nil, err
+		}
+		r.Routes = append(r.Routes, c)
+	}
+	return r, nil
+}
+
+// Match does a depth-first left-to-right search through the routing tree and
+// returns the matching routes.
+func (r *Route) Match(lset model.LabelSet) []*Route {
+	if !r.Matchers.Matches(lset) {
+		return nil
+	}
+
+	var all []*Route
+	for _, cr := range r.Routes {
+		matches := cr.Match(lset)
+		all = append(all, matches...)
+		if matches != nil && !cr.Continue {
+			break
+		}
+	}
+
+	// If no child matches, the route itself is a match.
+	if len(all) == 0 {
+		all = append(all, r)
+	}
+	return all
+}
+
+// Key returns the path of matchers to the route.
+func (r *Route) Key() string {
+	if r.parent == nil {
+		return r.Matchers.String()
+	}
+	return r.parent.Key() + "/" + r.Matchers.String()
+}
+
+// groupBy returns the group by keys of a route, or ... if the alerts are
+// grouped by all their labels.
+func (r *Route) groupBy() []string {
+	if r.GroupByAll {
+		return []string{"..."}
+	}
+	return r.GroupBy
+}
+
+// MatchRoute returns the routes a label set is routed to, in order.
+func MatchRoute(route *Route, labels map[string]string) []RouteMatch {
+	var matches []RouteMatch
+	for _, r := range route.Match(toLabelSet(labels)) {
+		matches = append(matches, RouteMatch{
+			Route:    r.Key(),
+			Receiver: r.Receiver,
+			GroupBy:  r.groupBy(),
+		})
+	}
+	return matches
+}
+
+// TestRoute runs the routing test cases against a routing tree, and returns
+// the failed ones.
+func TestRoute(route *Route, tests []RouteTestCase) []RouteTestFailure {
+	var failures []RouteTestFailure
+	for _, tc := range tests {
+		receivers := []string{}
+		for _, m := range MatchRoute(route, tc.Labels) {
+			receivers = append(receivers, m.Receiver)
+		}
+		if !equalStrings(receivers, tc.Receivers) {
+			failures = append(failures, RouteTestFailure{
+				Name:      tc.Name,
+				Labels:    tc.Labels,
+				Expected:  tc.Receivers,
+				Receivers: receivers,
+			})
+		}
+	}
+	return failures
+}
+
+// FormatRoute returns the routing tree, one route per line, with the matchers,
+// receiver and group by keys of every route.
+func FormatRoute(route *Route) string {
+	var b strings.Builder
+	var format func(r *Route, prefix string, last bool)
+	format = func(r *Route, prefix string, last bool) {
+		branch, indent := "├── ", "│   "
+		if last {
+			branch, indent = "└── ", "    "
+		}
+
+		name := "default-route"
+		if len(r.Matchers) > 0 {
+			name = r.Matchers.String()
+		}
+		fmt.Fprintf(&b, "%s%s%s  receiver: %s", prefix, branch, name, r.Receiver)
+		if gb := r.groupBy(); len(gb) > 0 {
+			fmt.Fprintf(&b, "  group_by: [%s]", strings.Join(gb, ", "))
+		}
+		if r.Continue {
+			b.WriteString("  continue: true")
+		}
+		if len(r.MuteTimeIntervals) > 0 {
+			fmt.Fprintf(&b, "  mute_time_intervals: [%s]", strings.Join(r.MuteTimeIntervals, ", "))
+		}
+		if len(r.ActiveTimeIntervals) > 0 {
+			fmt.Fprintf(&b, "  active_time_intervals: [%s]", strings.Join(r.ActiveTimeIntervals, ", "))
+		}
+		b.WriteString("\n")
+
+		for i, child := range r.Routes {
+			format(child, prefix+indent, i == len(r.Routes)-1)
+		}
+	}
+	format(route, "", true)
+	return b.String()
+}
+
+func toLabelSet(labels map[string]string) model.LabelSet {
+	ls := make(model.LabelSet, len(labels))
+	for k, v := range labels {
+		ls[model.LabelName(k)] = model.LabelValue(v)
+	}
+	return ls
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,68 @@
+package alertmanager
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const routesConfig = `route:
+  receiver: team
+  group_by: [alertname]
+  routes:
+    - matchers: ['severity="critical"']
+      receiver: pager
+      continue: true
+      group_by: [cluster, alertname]
+    - match_re: {team: "db|storage"}
+      receiver: db
+      routes:
+        - matchers: [env="dev"]
+          receiver: dev
+          group_by: ['...']
+receivers:
+  - name: team
+  - name: pager
+  - name: db
+  - name: dev
+`
+
+func TestMatchRoute(t *testing.T) {
+	route, err := LoadRoute(routesConfig)
+	require.NoError(t, err)
+
+	assert.Equal(t, []RouteMatch{
+		{Route: `{}/{severity="critical"}`, Receiver: "pager", GroupBy: []string{"alertname", "cluster"}},
+		{Route: `{}/{team=~"^(?:db|storage)$"}/{env="dev"}`, Receiver: "dev", GroupBy: []string{"..."}},
+	}, MatchRoute(route, map[string]string{"severity": "critical", "team": "storage", "env": "dev"}))
+
+	assert.Equal(t, []RouteMatch{
+		{Route: `{}`, Receiver: "team", GroupBy: []string{"alertname"}},
+	}, MatchRoute(route, map[string]string{"team": "frontend"}))
+}
+
+func TestTestRoute(t *testing.T) {
+	route, err := LoadRoute(routesConfig)
+	require.NoError(t, err)
+
+	failures := TestRoute(route, []RouteTestCase{
+		{Labels: map[string]string{"team": "db"}, Receivers: []string{"db"}},
+		{Name: "critical", Labels: map[string]string{"severity": "critical", "team": "db"}, Receivers: []string{"pager"}},
+	})
+	assert.Equal(t, []RouteTestFailure{
+		{Name: "critical", Labels: map[string]string{"severity": "critical", "team": "db"}, Expected: []string{"pager"}, Receivers: []string{"pager", "db"}},
+	}, failures)
+	assert.Equal(t, "critical: expected receivers [pager], got [pager db]", failures[0].String())
+}
+
+func TestFormatRoute(t *testing.T) {
+	route, err := LoadRoute(routesConfig)
+	require.NoError(t, err)
+
+	assert.Equal(t, `└── default-route  receiver: team  group_by: [alertname]
+    ├── {severity="critical"}  receiver: pager  group_by: [alertname, cluster]  continue: true
+    └── {team=~"^(?:db|storage)$"}  receiver: db  group_by: [alertname]
+        └── {env="dev"}  receiver: dev  group_by: [...]
+`, FormatRoute(route))
+}
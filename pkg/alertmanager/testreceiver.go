@@ -0,0 +1,144 @@
+package alertmanager
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/alertmanager/pkg/labels"
+	"github.com/prometheus/alertmanager/template"
+	"github.com/prometheus/common/model"
+)
+
+const (
+	// TestAlertName is the name of the alerts sent to test receivers.
+	TestAlertName = "CortextoolReceiverTest"
+	// TestIDLabel is the label holding the unique ID of a test alert.
+	TestIDLabel = "cortextool_test_id"
+)
+
+// TestAlertLabels returns labels routed to the receiver, besides the name and
+// ID of the test alert. The labels are found from the matchers of the routes
+// of the receiver, extra labels taking precedence.
+func TestAlertLabels(route *Route, receiver string, extra model.LabelSet) (model.LabelSet, error) {
+	var candidates []model.LabelSet
+	var walk func(r *Route, ls model.LabelSet)
+	walk = func(r *Route, ls model.LabelSet) {
+		ls = ls.Clone()
+		for _, m := range r.Matchers {
+			// An empty value is a missing label.
+			if v, ok := matchingValue(m); ok && v != "" {
+				ls[model.LabelName(m.Name)] = model.LabelValue(v)
+			}
+		}
+		if r.Receiver == receiver {
+			candidates = append(candidates, ls)
+		}
+		for _, child := range r.Routes {
+			walk(child, ls)
+		}
+	}
+	walk(route, model.LabelSet{})
+
+	for _, ls := range candidates {
+		ls = ls.Merge(extra)
+		ls[model.AlertNameLabel] = TestAlertName
+		ls[TestIDLabel] = "test"
+		for _, m := range route.Match(ls) {
+			if m.Receiver == receiver {
+				delete(ls, TestIDLabel)
+				return ls, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("unable to find labels routed to receiver %q, set them with --label", receiver)
+}
+
+// matchingValue returns a value matched by a matcher, if one is found.
+func matchingValue(m *labels.Matcher) (string, bool) {
+	var candidates []string
+	switch m.Type {
+	case labels.MatchEqual:
+		candidates = []string{m.Value}
+	case labels.MatchRegexp:
+		// The alternatives of a regexp are often literals, as in db|storage.
+		candidates = append([]string{m.Value}, strings.Split(strings.Trim(m.Value, "^$()"), "|")...)
+	case labels.MatchNotEqual, labels.MatchNotRegexp:
+		candidates = []string{"", TestAlertName}
+	}
+	for _, v := range candidates {
+		if m.Matches(v) {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+// WebhookSink is an Alertmanager webhook receiver recording the test alerts it
+// is notified of.
+type WebhookSink struct {
+	mtx      sync.Mutex
+	notified map[string]chan struct{}
+}
+
+// NewWebhookSink returns a webhook sink.
+func NewWebhookSink() *WebhookSink {
+	return &WebhookSink{notified: map[string]chan struct{}{}}
+}
+
+func (s *WebhookSink) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	data := template.Data{}
+	if err := json.NewDecoder(req.Body).Decode(&data); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	for _, alert := range data.Alerts.Firing() {
+		if id, ok := alert.Labels[TestIDLabel]; ok {
+			s.close(id)
+		}
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// Wait waits until the test alert with the ID is notified.
+func (s *WebhookSink) Wait(ctx context.Context, id string) error {
+	select {
+	case <-s.channel(id):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *WebhookSink) channel(id string) chan struct{} {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	ch, ok := s.notified[id]
+	if !ok {
+		ch = make(chan struct{})
+		s.notified[id] = ch
+	}
+	return ch
+}
+
+func (s *WebhookSink) close(id string) {
+	ch := s.channel(id)
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	select {
+	case <-ch:
+	default:
+		close(ch)
+	}
+}
@@ -0,0 +1,75 @@
+package alertmanager
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTestAlertLabels(t *testing.T) {
+	route, err := LoadRoute(`route:
+  receiver: default
+  routes:
+    - matchers: [team=~"db|storage", severity!="info"]
+      receiver: db
+      routes:
+        - matchers: [env="dev"]
+          receiver: dev
+    - matchers: [team="web", env!=""]
+      receiver: web
+receivers:
+  - name: default
+  - name: db
+  - name: dev
+  - name: web
+`)
+	require.NoError(t, err)
+
+	for _, tc := range []struct {
+		receiver string
+		extra    model.LabelSet
+		expected model.LabelSet
+	}{
+		{receiver: "default", expected: model.LabelSet{"alertname": TestAlertName}},
+		{receiver: "db", expected: model.LabelSet{"alertname": TestAlertName, "team": "db"}},
+		{receiver: "db", extra: model.LabelSet{"team": "storage"}, expected: model.LabelSet{"alertname": TestAlertName, "team": "storage"}},
+		{receiver: "dev", expected: model.LabelSet{"alertname": TestAlertName, "team": "db", "env": "dev"}},
+		{receiver: "web", expected: model.LabelSet{"alertname": TestAlertName, "team": "web", "env": TestAlertName}},
+	} {
+		lbls, err := TestAlertLabels(route, tc.receiver, tc.extra)
+		require.NoError(t, err, tc.receiver)
+		assert.Equal(t, tc.expected, lbls, tc.receiver)
+	}
+
+	// The extra labels route the alert elsewhere.
+	_, err = TestAlertLabels(route, "db", model.LabelSet{"severity": "info"})
+	assert.Error(t, err)
+	_, err = TestAlertLabels(route, "unknown", nil)
+	assert.Error(t, err)
+}
+
+func TestWebhookSink(t *testing.T) {
+	sink := NewWebhookSink()
+	srv := httptest.NewServer(sink)
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	assert.Error(t, sink.Wait(ctx, "a"))
+
+	res, err := http.Post(srv.URL, "application/json", bytes.NewBufferString(`{"alerts": [{"status": "firing", "labels": {"cortextool_test_id": "a"}}]}`))
+	require.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	assert.NoError(t, sink.Wait(ctx, "a"))
+}
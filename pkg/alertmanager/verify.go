@@ -0,0 +1,249 @@
+package alertmanager
+
+import (
+	"fmt"
+	"io"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	tmpltext "text/template"
+
+	"github.com/prometheus/alertmanager/asset"
+	"github.com/prometheus/alertmanager/config"
+	"github.com/prometheus/alertmanager/template"
+	yaml "gopkg.in/yaml.v3"
+
+	"github.com/cortexproject/cortex-tools/pkg/util"
+)
+
+var (
+	// yamlLineRe matches the line of YAML and config errors.
+	yamlLineRe = regexp.MustCompile(`line (\d+)`)
+	// templateLineRe matches the line of template parse errors.
+	templateLineRe = regexp.MustCompile(`template: [^:]*:(\d+):`)
+	// templateCallRe matches the templates called by other templates.
+	templateCallRe = regexp.MustCompile(`\{\{-?\s*template\s+"([^"]+)"`)
+	// templateDefineRe matches the templates defined by template files, even
+	// if they don't parse.
+	templateDefineRe = regexp.MustCompile(`\{\{-?\s*(?:define|block)\s+"([^"]+)"`)
+)
+
+// defaultTemplates are the templates of Alertmanager, available to every
+// config.
+var defaultTemplates = []string{"default.tmpl", "email.tmpl"}
+
+// Problem is an error found in an Alertmanager config or template.
+type Problem struct {
+	File string `json:"file"`
+	// Line is the 1-based line of the problem, or 0 if unknown.
+	Line    int    `json:"line,omitempty"`
+	Column  int    `json:"column,omitempty"`
+	Message string `json:"message"`
+}
+
+func (p Problem) String() string {
+	switch {
+	case p.Line > 0 && p.Column > 0:
+		return fmt.Sprintf("%s:%d:%d: %s", p.File, p.Line, p.Column, p.Message)
+	case p.Line > 0:
+		return fmt.Sprintf("%s:%d: %s", p.File, p.Line, p.Message)
+	default:
+		return fmt.Sprintf("%s: %s", p.File, p.Message)
+	}
+}
+
+// VerifyOptions are the features of Alertmanager forbidden by the Cortex
+// cluster, besides local files.
+type VerifyOptions struct {
+	// DisallowedReceivers are the integrations receivers cannot use, as in
+	// email or webhook.
+	DisallowedReceivers []string
+}
+
+// Verify checks an Alertmanager config and its templates, keyed by file name,
+// as Cortex does when they are uploaded. The config is parsed with the
+// Alertmanager config package, and must not reference local files or use
+// disallowed receivers. Every template must parse, the templates globs of the
+// config must match uploaded templates and the templates called by the config
+// and templates must be defined. The problems are sorted by file and line.
+func Verify(configFile, cfg string, templates map[string]string, opts VerifyOptions) []Problem {
+	var problems []Problem
+	add := func(file string, line, column int, format string, args ...interface{}) {
+		problems = append(problems, Problem{File: file, Line: line, Column: column, Message: fmt.Sprintf(format, args...)})
+	}
+
+	var root yaml.Node
+	if err := yaml.Unmarshal([]byte(cfg), &root); err != nil {
+		add(configFile, errorLine(yamlLineRe, err), 0, "%s", err)
+		return problems
+	}
+
+	if _, err := config.Load(cfg); err != nil {
+		add(configFile, errorLine(yamlLineRe, err), 0, "%s", err)
+	}
+
+	disallowed := map[string]struct{}{}
+	for _, r := range opts.DisallowedReceivers {
+		disallowed[strings.TrimSuffix(r, "_configs")+"_configs"] = struct{}{}
+	}
+
+	defined, err := definedTemplates(templates)
+	if err != nil {
+		add(configFile, 0, 0, "unable to load the default templates: %s", err)
+	}
+
+	for _, name := range util.SortedKeys(templates) {
+		if err := validateTemplateFilename(name); err != nil {
+			add(name, 0, 0, "%s", err)
+		}
+
+		t, err := template.New()
+		if err != nil {
+			add(name, 0, 0, "%s", err)
+			continue
+		}
+		if err := t.Parse(strings.NewReader(templates[name])); err != nil {
+			add(name, errorLine(templateLineRe, err), 0, "%s", err)
+		}
+
+		for _, m := range templateCallRe.FindAllStringSubmatchIndex(templates[name], -1) {
+			called := templates[name][m[2]:m[3]]
+			if _, ok := defined[called]; !ok && defined != nil {
+				add(name, strings.Count(templates[name][:m[0]], "\n")+1, 0, "template %q is not defined", called)
+			}
+		}
+	}
+
+	var walk func(node *yaml.Node, keys []string)
+	walk = func(node *yaml.Node, keys []string) {
+		switch node.Kind {
+		case yaml.DocumentNode, yaml.SequenceNode:
+			for _, child := range node.Content {
+				walk(child, keys)
+			}
+
+		case yaml.MappingNode:
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				key, value := node.Content[i], node.Content[i+1]
+
+				if strings.HasSuffix(key.Value, "_file") && value.Value != "" {
+					add(configFile, key.Line, key.Column, "%s: local files are not allowed, the config cannot reference %q", key.Value, value.Value)
+				}
+				if _, ok := disallowed[key.Value]; ok && len(keys) > 0 && keys[0] == "receivers" {
+					add(configFile, key.Line, key.Column, "%s: %s receivers are not allowed", key.Value, strings.TrimSuffix(key.Value, "_configs"))
+				}
+				if key.Value == "templates" && len(keys) == 0 {
+					verifyTemplateGlobs(configFile, value, templates, add)
+				}
+
+				walk(value, append(keys, key.Value))
+			}
+
+		case yaml.ScalarNode:
+			if len(keys) == 0 || keys[0] != "receivers" || !strings.Contains(node.Value, "{{") {
+				return
+			}
+			if _, err := tmpltext.New("").Funcs(tmpltext.FuncMap(template.DefaultFuncs)).Parse(node.Value); err != nil {
+				add(configFile, node.Line, node.Column, "%s: %s", keys[len(keys)-1], err)
+				return
+			}
+			for _, m := range templateCallRe.FindAllStringSubmatch(node.Value, -1) {
+				if _, ok := defined[m[1]]; !ok && defined != nil {
+					add(configFile, node.Line, node.Column, "%s: template %q is not defined", keys[len(keys)-1], m[1])
+				}
+			}
+		}
+	}
+	walk(&root, nil)
+
+	// The problems of the config come first.
+	sort.SliceStable(problems, func(i, j int) bool {
+		a, b := problems[i], problems[j]
+		if (a.File == configFile) != (b.File == configFile) {
+			return a.File == configFile
+		}
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		return a.Line < b.Line
+	})
+	return problems
+}
+
+// verifyTemplateGlobs checks that every glob of the templates of the config
+// matches uploaded templates.
+func verifyTemplateGlobs(configFile string, node *yaml.Node, templates map[string]string, add func(string, int, int, string, ...interface{})) {
+	if node.Kind != yaml.SequenceNode {
+		return
+	}
+	for _, glob := range node.Content {
+		pattern := filepath.Base(glob.Value)
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			add(configFile, glob.Line, glob.Column, "templates: invalid glob %q: %s", glob.Value, err)
+			continue
+		}
+		matched := false
+		for name := range templates {
+			if ok, _ := filepath.Match(pattern, name); ok {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			add(configFile, glob.Line, glob.Column, "templates: %q matches no template file", glob.Value)
+		}
+	}
+}
+
+// definedTemplates returns the names of the templates defined by the default
+// templates and the template files.
+func definedTemplates(templates map[string]string) (map[string]struct{}, error) {
+	defined := map[string]struct{}{}
+	addDefined := func(content string) {
+		for _, m := range templateDefineRe.FindAllStringSubmatch(content, -1) {
+			defined[m[1]] = struct{}{}
+		}
+	}
+
+	for _, file := range defaultTemplates {
+		f, err := asset.Assets.Open(path.Join("/templates", file))
+		if err != nil {
+			return nil, err
+		}
+		content, err := io.ReadAll(f)
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+		addDefined(string(content))
+	}
+	for _, content := range templates {
+		addDefined(content)
+	}
+	return defined, nil
+}
+
+// validateTemplateFilename returns an error if Cortex doesn't accept the name
+// of a template file.
+func validateTemplateFilename(name string) error {
+	if name == "" || name == "." || name == ".." {
+		return fmt.Errorf("invalid template name %q", name)
+	}
+	if strings.ContainsAny(name, `/\`) {
+		return fmt.Errorf("invalid template name %q: the name must not contain path separators", name)
+	}
+	return nil
+}
+
+// errorLine returns the line matched by re in an error, or 0.
+func errorLine(re *regexp.Regexp, err error) int {
+	m := re.FindStringSubmatch(err.Error())
+	if m == nil {
+		return 0
+	}
+	line, _ := strconv.Atoi(m[1])
+	return line
+}
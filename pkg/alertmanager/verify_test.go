@@ -0,0 +1,73 @@
+package alertmanager
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const verifyConfig = `global:
+  smtp_smarthost: smtp:25
+  smtp_from: alertmanager@example.com
+templates:
+  - custom.tmpl
+  - 'missing-*.tmpl'
+  - 'invalid-[.tmpl'
+route:
+  receiver: team
+receivers:
+  - name: team
+    email_configs:
+      - to: team@example.com
+        html: '{{ template "custom.body" . }}'
+        text: '{{ template "undefined" . }}'
+    webhook_configs:
+      - url: http://example.com
+        http_config:
+          bearer_token_file: /etc/token
+`
+
+func TestVerify(t *testing.T) {
+	templates := map[string]string{
+		"custom.tmpl": `{{ define "custom.body" }}{{ template "email.default.html" . }}{{ end }}
+{{ define "custom.title" }}{{ template "missing.title" . }}{{ end }}
+{{ define "broken" }}{{ if }}{{ end }}
+`,
+	}
+
+	problems := Verify("alertmanager.yaml", verifyConfig, templates, VerifyOptions{DisallowedReceivers: []string{"email"}})
+
+	var got []string
+	for _, p := range problems {
+		got = append(got, p.String())
+	}
+	assert.Equal(t, []string{
+		`alertmanager.yaml:6:5: templates: "missing-*.tmpl" matches no template file`,
+		`alertmanager.yaml:7:5: templates: invalid glob "invalid-[.tmpl": syntax error in pattern`,
+		`alertmanager.yaml:12:5: email_configs: email receivers are not allowed`,
+		`alertmanager.yaml:15:15: text: template "undefined" is not defined`,
+		`alertmanager.yaml:19:11: bearer_token_file: local files are not allowed, the config cannot reference "/etc/token"`,
+		`custom.tmpl:2: template "missing.title" is not defined`,
+		`custom.tmpl:3: template: :3: missing value for if`,
+	}, got)
+}
+
+func TestVerifyValid(t *testing.T) {
+	cfg := `route:
+  receiver: team
+receivers:
+  - name: team
+    webhook_configs:
+      - url: http://example.com
+`
+	assert.Empty(t, Verify("alertmanager.yaml", cfg, nil, VerifyOptions{}))
+}
+
+func TestVerifyInvalidConfig(t *testing.T) {
+	problems := Verify("alertmanager.yaml", "route:\n  receiver: team\n  foo: [\n", nil, VerifyOptions{})
+	assert.Len(t, problems, 1)
+	assert.Equal(t, 3, problems[0].Line)
+
+	problems = Verify("alertmanager.yaml", "route:\n  receiver: unknown\nreceivers:\n  - name: team\n", nil, VerifyOptions{})
+	assert.Equal(t, []Problem{{File: "alertmanager.yaml", Message: `undefined receiver "unknown" used in route`}}, problems)
+}
@@ -0,0 +1,274 @@
+package analyse
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql/parser"
+)
+
+// Kinds of query cost factors.
+const (
+	CostSelector = "selector"
+	CostRegex    = "regex"
+	CostRange    = "range"
+	CostSubquery = "subquery"
+	CostJoin     = "join"
+	CostTopK     = "topk"
+)
+
+const (
+	// costRangeUnit is the range duration weighing as much as a selector.
+	costRangeUnit = 5 * time.Minute
+	// defaultSubqueryStep is the step of subqueries without one, the default
+	// evaluation interval of the ruler.
+	defaultSubqueryStep = time.Minute
+
+	regexWeight = 1
+	joinWeight  = 10
+	topKWeight  = 5
+)
+
+// CostFactor is a part of a query contributing to its estimated cost.
+type CostFactor struct {
+	Kind string `json:"kind"`
+	Expr string `json:"expr"`
+	// Weight is the cost of the factor for each series of its selector, or its
+	// cost if it doesn't scale with a selector. It includes the number of
+	// evaluations of the enclosing subqueries.
+	Weight float64 `json:"weight"`
+	// Selector is the selector the cost scales with, if any.
+	Selector string `json:"selector,omitempty"`
+	// Series is the number of series of the selector, if known.
+	Series int `json:"series,omitempty"`
+}
+
+// Cost returns the cost of the factor. Selectors whose series are unknown
+// count as a single series.
+func (f CostFactor) Cost() float64 {
+	if f.Series > 1 {
+		return f.Weight * float64(f.Series)
+	}
+	return f.Weight
+}
+
+// QueryCost is the estimated cost of a query, the sum of the costs of its
+// factors. It is only meant to compare queries with each other.
+type QueryCost struct {
+	Query   string       `json:"query"`
+	Score   float64      `json:"score"`
+	Factors []CostFactor `json:"factors"`
+}
+
+// ExplainQuery estimates the cost of a query from its selectors, regex
+// matchers, range durations, subqueries, joins expanding the cardinality of
+// one side and topk or bottomk over unaggregated selectors.
+func ExplainQuery(expr parser.Expr) *QueryCost {
+	c := &QueryCost{Query: expr.String()}
+	c.walk(expr, 1)
+	c.score()
+	return c
+}
+
+// Selectors returns the sorted selectors the cost of the query scales with.
+func (c *QueryCost) Selectors() []string {
+	set := map[string]struct{}{}
+	for _, f := range c.Factors {
+		if f.Selector != "" {
+			set[f.Selector] = struct{}{}
+		}
+	}
+	selectors := make([]string, 0, len(set))
+	for s := range set {
+		selectors = append(selectors, s)
+	}
+	sort.Strings(selectors)
+	return selectors
+}
+
+// SetSeries sets the number of series of the selectors, and updates the
+// score accordingly.
+func (c *QueryCost) SetSeries(series map[string]int) {
+	for i, f := range c.Factors {
+		if count, ok := series[f.Selector]; ok && f.Selector != "" {
+			c.Factors[i].Series = count
+		}
+	}
+	c.score()
+}
+
+func (c *QueryCost) score() {
+	c.Score = 0
+	for _, f := range c.Factors {
+		c.Score += f.Cost()
+	}
+}
+
+func (c *QueryCost) add(kind string, node fmt.Stringer, weight float64, selector string) {
+	c.Factors = append(c.Factors, CostFactor{
+		Kind:     kind,
+		Expr:     node.String(),
+		Weight:   weight,
+		Selector: selector,
+	})
+}
+
+// walk adds the cost factors of a node and its children, each evaluated
+// multiplier times.
+func (c *QueryCost) walk(node parser.Node, multiplier float64) {
+	switch n := node.(type) {
+	case *parser.VectorSelector:
+		selector := selectorString(n)
+		c.add(CostSelector, n, multiplier, selector)
+		for _, m := range n.LabelMatchers {
+			if m.Type == labels.MatchRegexp || m.Type == labels.MatchNotRegexp {
+				c.add(CostRegex, m, regexWeight*multiplier, "")
+			}
+		}
+
+	case *parser.MatrixSelector:
+		c.walk(n.VectorSelector, multiplier)
+		if vs, ok := n.VectorSelector.(*parser.VectorSelector); ok {
+			c.add(CostRange, n, rangeWeight(n.Range)*multiplier, selectorString(vs))
+		}
+		return
+
+	case *parser.SubqueryExpr:
+		step := n.Step
+		if step == 0 {
+			step = defaultSubqueryStep
+		}
+		steps := float64(n.Range / step)
+		if steps < 1 {
+			steps = 1
+		}
+		c.add(CostSubquery, n, steps*multiplier, "")
+		c.walk(n.Expr, steps*multiplier)
+		return
+
+	case *parser.BinaryExpr:
+		if vm := n.VectorMatching; vm != nil && (vm.Card == parser.CardManyToOne || vm.Card == parser.CardOneToMany) {
+			c.add(CostJoin, n, joinWeight*multiplier, "")
+		}
+
+	case *parser.AggregateExpr:
+		if (n.Op == parser.TOPK || n.Op == parser.BOTTOMK) && len(n.Grouping) == 0 && !n.Without {
+			if vs := unaggregatedSelector(n.Expr); vs != nil {
+				c.add(CostTopK, n, topKWeight*multiplier, selectorString(vs))
+			}
+		}
+	}
+
+	for _, child := range parser.Children(node) {
+		c.walk(child, multiplier)
+	}
+}
+
+// rangeWeight returns the weight of a range, in range units.
+func rangeWeight(r time.Duration) float64 {
+	w := float64(r) / float64(costRangeUnit)
+	if w < 1 {
+		return 1
+	}
+	return w
+}
+
+// selectorString returns a selector without its offset and @ modifiers, as
+// used to count its series.
+func selectorString(vs *parser.VectorSelector) string {
+	return (&parser.VectorSelector{Name: vs.Name, LabelMatchers: vs.LabelMatchers}).String()
+}
+
+// unaggregatedSelector returns the only selector of an expression, if it has
+// exactly one and it isn't aggregated.
+func unaggregatedSelector(expr parser.Expr) *parser.VectorSelector {
+	var found *parser.VectorSelector
+	count := 0
+	aggregated := false
+	parser.Inspect(expr, func(node parser.Node, _ []parser.Node) error {
+		switch n := node.(type) {
+		case *parser.VectorSelector:
+			found = n
+			count++
+		case *parser.AggregateExpr:
+			aggregated = true
+		}
+		return nil
+	})
+	if count != 1 || aggregated {
+		return nil
+	}
+	return found
+}
+
+// FormatAST returns the tree of the nodes of an expression, one node per
+// line, indented by depth.
+func FormatAST(expr parser.Expr) string {
+	var b strings.Builder
+	var format func(node parser.Node, depth int)
+	format = func(node parser.Node, depth int) {
+		fmt.Fprintf(&b, "%s%s\n", strings.Repeat("  ", depth), describeNode(node))
+		for _, child := range parser.Children(node) {
+			format(child, depth+1)
+		}
+	}
+	format(expr, 0)
+	return b.String()
+}
+
+// describeNode returns the type of a node, with its operator, function or
+// modifiers, or its text for leaves.
+func describeNode(node parser.Node) string {
+	switch n := node.(type) {
+	case *parser.AggregateExpr:
+		desc := "AggregateExpr: " + n.Op.String()
+		if n.Without {
+			desc += " without (" + strings.Join(n.Grouping, ", ") + ")"
+		} else if len(n.Grouping) > 0 {
+			desc += " by (" + strings.Join(n.Grouping, ", ") + ")"
+		}
+		return desc
+	case *parser.BinaryExpr:
+		desc := "BinaryExpr: " + n.Op.String()
+		if n.ReturnBool {
+			desc += " bool"
+		}
+		if vm := n.VectorMatching; vm != nil {
+			if vm.On || len(vm.MatchingLabels) > 0 {
+				keyword := "ignoring"
+				if vm.On {
+					keyword = "on"
+				}
+				desc += fmt.Sprintf(" %s (%s)", keyword, strings.Join(vm.MatchingLabels, ", "))
+			}
+			switch vm.Card {
+			case parser.CardManyToOne:
+				desc += " group_left (" + strings.Join(vm.Include, ", ") + ")"
+			case parser.CardOneToMany:
+				desc += " group_right (" + strings.Join(vm.Include, ", ") + ")"
+			}
+		}
+		return desc
+	case *parser.Call:
+		return "Call: " + n.Func.Name
+	case *parser.SubqueryExpr:
+		return "SubqueryExpr: " + strings.TrimPrefix(n.String(), n.Expr.String())
+	case *parser.ParenExpr:
+		return "ParenExpr"
+	case *parser.UnaryExpr:
+		return "UnaryExpr: " + n.Op.String()
+	case *parser.MatrixSelector:
+		return "MatrixSelector: " + n.String()
+	case *parser.VectorSelector:
+		return "VectorSelector: " + n.String()
+	case *parser.NumberLiteral:
+		return "NumberLiteral: " + n.String()
+	case *parser.StringLiteral:
+		return "StringLiteral: " + n.String()
+	default:
+		return fmt.Sprintf("%T: %s", node, node.String())
+	}
+}
@@ -0,0 +1,89 @@
+package analyse
+
+import (
+	"testing"
+
+	"github.com/prometheus/prometheus/promql/parser"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExplainQuery(t *testing.T) {
+	for _, tc := range []struct {
+		query string
+		score float64
+		kinds []string
+	}{
+		{
+			query: `up`,
+			score: 1,
+			kinds: []string{CostSelector},
+		},
+		{
+			query: `sum by (job) (rate(http_requests_total{code=~"5.."}[1h]))`,
+			score: 14,
+			kinds: []string{CostSelector, CostRegex, CostRange},
+		},
+		{
+			query: `max_over_time(up[1h:5m])`,
+			score: 24,
+			kinds: []string{CostSubquery, CostSelector},
+		},
+		{
+			query: `up * on (instance) group_left (version) build_info`,
+			score: 12,
+			kinds: []string{CostJoin, CostSelector, CostSelector},
+		},
+		{
+			query: `topk(5, node_cpu_seconds_total)`,
+			score: 6,
+			kinds: []string{CostTopK, CostSelector},
+		},
+		{
+			// topk over an aggregation isn't over a wide set.
+			query: `topk by (job) (5, sum by (job, instance) (up))`,
+			score: 1,
+			kinds: []string{CostSelector},
+		},
+	} {
+		t.Run(tc.query, func(t *testing.T) {
+			expr, err := parser.ParseExpr(tc.query)
+			require.NoError(t, err)
+
+			cost := ExplainQuery(expr)
+
+			var kinds []string
+			for _, f := range cost.Factors {
+				kinds = append(kinds, f.Kind)
+			}
+			assert.Equal(t, tc.kinds, kinds)
+			assert.Equal(t, tc.score, cost.Score)
+		})
+	}
+}
+
+func TestQueryCostSetSeries(t *testing.T) {
+	expr, err := parser.ParseExpr(`rate(foo{job="a"}[10m] offset 1h) / rate(bar[5m])`)
+	require.NoError(t, err)
+
+	cost := ExplainQuery(expr)
+	assert.Equal(t, []string{`bar`, `foo{job="a"}`}, cost.Selectors())
+	assert.Equal(t, 5.0, cost.Score)
+
+	cost.SetSeries(map[string]int{`foo{job="a"}`: 100})
+	assert.Equal(t, 302.0, cost.Score)
+}
+
+func TestFormatAST(t *testing.T) {
+	expr, err := parser.ParseExpr(`sum by (job) (rate(foo[5m])) > on (job) group_left () 2 * bar`)
+	require.NoError(t, err)
+	assert.Equal(t, `BinaryExpr: > on (job) group_left ()
+  AggregateExpr: sum by (job)
+    Call: rate
+      MatrixSelector: foo[5m]
+        VectorSelector: foo
+  BinaryExpr: *
+    NumberLiteral: 2
+    VectorSelector: bar
+`, FormatAST(expr))
+}
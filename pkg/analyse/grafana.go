@@ -5,6 +5,7 @@ import (
 	"regexp"
 	"sort"
 	"strings"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 
@@ -32,20 +33,8 @@ func ParseMetricsInBoard(mig *MetricsInGrafana, board sdk.Board) {
 	metrics := make(map[string]struct{})
 
 	// Iterate through all the panels and collect metrics
-	for _, panel := range board.Panels {
-		parseErrors = append(parseErrors, metricsFromPanel(*panel, metrics)...)
-		if panel.RowPanel != nil {
-			for _, subPanel := range panel.RowPanel.Panels {
-				parseErrors = append(parseErrors, metricsFromPanel(subPanel, metrics)...)
-			}
-		}
-	}
-
-	// Iterate through all the rows and collect metrics
-	for _, row := range board.Rows {
-		for _, panel := range row.Panels {
-			parseErrors = append(parseErrors, metricsFromPanel(panel, metrics)...)
-		}
+	for _, panel := range PanelsInBoard(board) {
+		parseErrors = append(parseErrors, metricsFromPanel(panel, metrics)...)
 	}
 
 	// Process metrics in templating
@@ -77,6 +66,23 @@ func ParseMetricsInBoard(mig *MetricsInGrafana, board sdk.Board) {
 
 }
 
+// PanelsInBoard returns all the panels of a dashboard, including the panels
+// nested in rows.
+func PanelsInBoard(board sdk.Board) []sdk.Panel {
+	var panels []sdk.Panel
+	for _, panel := range board.Panels {
+		panels = append(panels, *panel)
+		if panel.RowPanel != nil {
+			panels = append(panels, panel.RowPanel.Panels...)
+		}
+	}
+
+	for _, row := range board.Rows {
+		panels = append(panels, row.Panels...)
+	}
+	return panels
+}
+
 func metricsFromTemplating(templating sdk.Templating, metrics map[string]struct{}) []error {
 	parseErrors := []error{}
 	for _, templateVar := range templating.List {
@@ -163,3 +169,34 @@ func parseQuery(query string, metrics map[string]struct{}) error {
 
 	return nil
 }
+
+// grafanaVariables are the Grafana variables replaced by MaskGrafanaVariables.
+var grafanaVariables = []struct {
+	name        string
+	placeholder func(n int) string
+}{
+	{"${__range_s:glob}", numberPlaceholder},
+	{"${__range_s}", numberPlaceholder},
+	{"$__rate_interval", durationPlaceholder},
+	{"$__interval", durationPlaceholder},
+	{"$interval", durationPlaceholder},
+	{"$resolution", durationPlaceholder},
+	{"$__range", durationPlaceholder},
+}
+
+// GrafanaIntervalPlaceholder is the duration of the interval variables masked
+// by MaskGrafanaVariables.
+const GrafanaIntervalPlaceholder = time.Millisecond
+
+func numberPlaceholder(n int) string   { return strings.Repeat("1", n) }
+func durationPlaceholder(n int) string { return strings.Repeat("0", n-3) + "1ms" }
+
+// MaskGrafanaVariables replaces the Grafana variables of a query with
+// placeholders of the same length, so the query can be parsed and the
+// positions of the parsed expression match the original query.
+func MaskGrafanaVariables(query string) string {
+	for _, v := range grafanaVariables {
+		query = strings.ReplaceAll(query, v.name, v.placeholder(len(v.name)))
+	}
+	return query
+}
@@ -0,0 +1,416 @@
+package analyse
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/grafana-tools/sdk"
+	"github.com/pkg/errors"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql/parser"
+	"github.com/prometheus/prometheus/promql/parser/posrange"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/cortexproject/cortex-tools/pkg/rules/rwrulefmt"
+)
+
+// numberPlaceholderRe matches the numbers masked by MaskGrafanaVariables.
+var numberPlaceholderRe = regexp.MustCompile(`\b1{10,}\b`)
+
+// RecordingRuleSuggestion is an aggregation repeated across dashboard and rule
+// queries, which could be precomputed by a recording rule.
+type RecordingRuleSuggestion struct {
+	Record  string   `json:"record"`
+	Expr    string   `json:"expr"`
+	Count   int      `json:"count"`
+	Series  int      `json:"series,omitempty"`
+	Sources []string `json:"sources"`
+
+	// Selectors are the series selectors of the expression, used to estimate
+	// the number of series it touches.
+	Selectors []string `json:"-"`
+
+	sources map[string]struct{}
+}
+
+// Score ranks the suggestions: the series touched by each evaluation of the
+// expression, times the number of queries evaluating it.
+func (s *RecordingRuleSuggestion) Score() int {
+	if s.Series == 0 {
+		return s.Count
+	}
+	return s.Count * s.Series
+}
+
+// RecordingRuleSuggester collects the aggregations used in queries, to suggest
+// recording rules for the most common ones.
+type RecordingRuleSuggester struct {
+	// Interval replaces the Grafana interval variables, as in
+	// rate(m[$__rate_interval]), in the suggested expressions.
+	Interval time.Duration
+
+	shapes   map[string]*RecordingRuleSuggestion
+	recorded map[string]string
+}
+
+// NewRecordingRuleSuggester returns a RecordingRuleSuggester replacing the
+// Grafana interval variables by interval.
+func NewRecordingRuleSuggester(interval time.Duration) *RecordingRuleSuggester {
+	return &RecordingRuleSuggester{
+		Interval: interval,
+		shapes:   map[string]*RecordingRuleSuggestion{},
+		recorded: map[string]string{},
+	}
+}
+
+// aggregationShape is the normalised form of an aggregation.
+type aggregationShape struct {
+	record    string
+	expr      string
+	selectors []string
+	// lifted are the matchers on the aggregation labels, which are applied
+	// to the recorded series rather than recorded.
+	lifted []*labels.Matcher
+}
+
+// AddQuery adds the aggregations of a query to the suggestions.
+func (s *RecordingRuleSuggester) AddQuery(query, source string) error {
+	expr, err := parser.ParseExpr(MaskGrafanaVariables(query))
+	if err != nil {
+		return errors.Wrapf(err, "query=%v", query)
+	}
+
+	parser.Inspect(expr, func(node parser.Node, _ []parser.Node) error {
+		agg, ok := node.(*parser.AggregateExpr)
+		if !ok {
+			return nil
+		}
+		shape, ok := s.shape(agg)
+		if !ok {
+			return nil
+		}
+
+		sug, ok := s.shapes[shape.expr]
+		if !ok {
+			sug = &RecordingRuleSuggestion{
+				Record:    shape.record,
+				Expr:      shape.expr,
+				Selectors: shape.selectors,
+				sources:   map[string]struct{}{},
+			}
+			s.shapes[shape.expr] = sug
+		}
+		sug.Count++
+		sug.sources[source] = struct{}{}
+		return nil
+	})
+	return nil
+}
+
+// AddBoard adds the aggregations of the queries of a Grafana dashboard to the
+// suggestions.
+func (s *RecordingRuleSuggester) AddBoard(board sdk.Board, source string) []error {
+	var parseErrors []error
+	for _, panel := range PanelsInBoard(board) {
+		targets := panel.GetTargets()
+		if targets == nil {
+			continue
+		}
+		for _, target := range *targets {
+			// Prometheus has this set.
+			if target.Expr == "" {
+				continue
+			}
+			if err := s.AddQuery(target.Expr, source); err != nil {
+				parseErrors = append(parseErrors, err)
+			}
+		}
+	}
+	return parseErrors
+}
+
+// AddRuleGroup adds the aggregations of the expressions of a rule group to the
+// suggestions. The aggregations already recorded by its recording rules are
+// not suggested again.
+func (s *RecordingRuleSuggester) AddRuleGroup(group rwrulefmt.RuleGroup, ns string) []error {
+	var parseErrors []error
+	for _, rule := range group.Rules {
+		if rule.Record.Value != "" {
+			expr, err := parser.ParseExpr(rule.Expr.Value)
+			if err != nil {
+				parseErrors = append(parseErrors, errors.Wrapf(err, "query=%v", rule.Expr.Value))
+				continue
+			}
+			if agg, ok := unwrapParens(expr).(*parser.AggregateExpr); ok {
+				if shape, ok := s.shape(agg); ok && len(shape.lifted) == 0 {
+					s.recorded[shape.expr] = rule.Record.Value
+					continue
+				}
+			}
+		}
+
+		name := rule.Record.Value
+		if name == "" {
+			name = rule.Alert.Value
+		}
+		if err := s.AddQuery(rule.Expr.Value, fmt.Sprintf("%s/%s/%s", ns, group.Name, name)); err != nil {
+			parseErrors = append(parseErrors, err)
+		}
+	}
+	return parseErrors
+}
+
+// Suggestions returns the aggregations used by at least minCount queries,
+// which aren't already recorded, ranked by Score.
+func (s *RecordingRuleSuggester) Suggestions(minCount int) []*RecordingRuleSuggestion {
+	var suggestions []*RecordingRuleSuggestion
+	for expr, sug := range s.shapes {
+		if sug.Count < minCount {
+			continue
+		}
+		if record, ok := s.recorded[expr]; ok {
+			log.WithFields(log.Fields{"expr": expr, "record": record}).Debugf("aggregation already recorded")
+			continue
+		}
+
+		sug.Sources = sug.Sources[:0]
+		for source := range sug.sources {
+			sug.Sources = append(sug.Sources, source)
+		}
+		sort.Strings(sug.Sources)
+		suggestions = append(suggestions, sug)
+	}
+
+	SortSuggestions(suggestions)
+
+	// Aggregations only differing by their matchers have the same name.
+	seen := map[string]int{}
+	for _, sug := range suggestions {
+		seen[sug.Record]++
+		if n := seen[sug.Record]; n > 1 {
+			sug.Record = fmt.Sprintf("%s_%d", sug.Record, n)
+		}
+	}
+	return suggestions
+}
+
+// SortSuggestions ranks suggestions by Score.
+func SortSuggestions(suggestions []*RecordingRuleSuggestion) {
+	sort.Slice(suggestions, func(i, j int) bool {
+		a, b := suggestions[i], suggestions[j]
+		if a.Score() != b.Score() {
+			return a.Score() > b.Score()
+		}
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		return a.Expr < b.Expr
+	})
+}
+
+// RewriteQuery replaces the aggregations of a query by the series recorded by
+// the suggestions. It returns the new query and the number of aggregations
+// replaced.
+func (s *RecordingRuleSuggester) RewriteQuery(query string, suggestions []*RecordingRuleSuggestion) (string, int, error) {
+	records := make(map[string]string, len(suggestions))
+	for _, sug := range suggestions {
+		records[sug.Expr] = sug.Record
+	}
+
+	expr, err := parser.ParseExpr(MaskGrafanaVariables(query))
+	if err != nil {
+		return "", 0, err
+	}
+
+	type edit struct {
+		pos posrange.PositionRange
+		to  string
+	}
+	var edits []edit
+	var v parser.Visitor
+	v = visitor(func(node parser.Node) bool {
+		agg, ok := node.(*parser.AggregateExpr)
+		if !ok {
+			return true
+		}
+		shape, ok := s.shape(agg)
+		if !ok {
+			return true
+		}
+		record, ok := records[shape.expr]
+		if !ok {
+			return true
+		}
+
+		to := record
+		if len(shape.lifted) > 0 {
+			matchers := make([]string, 0, len(shape.lifted))
+			for _, m := range shape.lifted {
+				matchers = append(matchers, m.String())
+			}
+			to = fmt.Sprintf("%s{%s}", record, strings.Join(matchers, ", "))
+		}
+		edits = append(edits, edit{pos: agg.PositionRange(), to: to})
+		// The aggregations nested in a replaced one are replaced with it.
+		return false
+	})
+	if err := parser.Walk(v, expr, nil); err != nil {
+		return "", 0, err
+	}
+
+	// Edit from the end of the query so the positions stay valid.
+	sort.Slice(edits, func(i, j int) bool { return edits[i].pos.Start > edits[j].pos.Start })
+	for _, e := range edits {
+		query = query[:e.pos.Start] + e.to + query[e.pos.End:]
+	}
+	return query, len(edits), nil
+}
+
+// shape returns the normalised form of an aggregation of a single series
+// selector, such as sum by (job) (rate(m[5m])), and its recording rule name.
+// Other aggregations, and the ones using Grafana variables which can't be
+// recorded, are not suggested.
+func (s *RecordingRuleSuggester) shape(agg *parser.AggregateExpr) (aggregationShape, bool) {
+	if agg.Param != nil || agg.Without {
+		return aggregationShape{}, false
+	}
+
+	// Work on a copy, as the expression is normalised in place.
+	expr, err := parser.ParseExpr(agg.String())
+	if err != nil {
+		return aggregationShape{}, false
+	}
+	agg = expr.(*parser.AggregateExpr)
+	sort.Strings(agg.Grouping)
+
+	var (
+		operations []string
+		selector   *parser.VectorSelector
+		liftable   = true
+	)
+	if agg.Op != parser.SUM {
+		operations = append(operations, agg.Op.String())
+	}
+
+	node := agg.Expr
+	for selector == nil {
+		switch n := node.(type) {
+		case *parser.ParenExpr:
+			node = n.Expr
+		case *parser.StepInvariantExpr:
+			node = n.Expr
+		case *parser.Call:
+			var arg parser.Expr
+			for _, a := range n.Args {
+				switch a.(type) {
+				case *parser.NumberLiteral, *parser.StringLiteral:
+					continue
+				}
+				if arg != nil {
+					return aggregationShape{}, false
+				}
+				arg = a
+			}
+			if arg == nil {
+				return aggregationShape{}, false
+			}
+
+			op := strings.TrimSuffix(n.Func.Name, "_over_time")
+			if ms, ok := unwrapParens(arg).(*parser.MatrixSelector); ok {
+				if ms.Range == GrafanaIntervalPlaceholder {
+					ms.Range = s.Interval
+				}
+				op += model.Duration(ms.Range).String()
+			}
+			switch n.Func.Name {
+			case "label_replace", "label_join":
+				liftable = false
+			}
+			operations = append(operations, op)
+			node = arg
+		case *parser.MatrixSelector:
+			node = n.VectorSelector
+		case *parser.VectorSelector:
+			selector = n
+		default:
+			return aggregationShape{}, false
+		}
+	}
+	if selector.Name == "" || selector.OriginalOffset == GrafanaIntervalPlaceholder {
+		return aggregationShape{}, false
+	}
+
+	var lifted []*labels.Matcher
+	grouping := map[string]struct{}{}
+	for _, l := range agg.Grouping {
+		grouping[l] = struct{}{}
+	}
+	matchers := selector.LabelMatchers[:0]
+	for _, m := range selector.LabelMatchers {
+		if _, ok := grouping[m.Name]; ok && liftable {
+			lifted = append(lifted, m)
+			continue
+		}
+		matchers = append(matchers, m)
+	}
+	selector.LabelMatchers = matchers
+	sort.Slice(selector.LabelMatchers, func(i, j int) bool {
+		a, b := selector.LabelMatchers[i], selector.LabelMatchers[j]
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		return a.String() < b.String()
+	})
+
+	normalised := agg.String()
+	if strings.Contains(normalised, "$") || numberPlaceholderRe.MatchString(normalised) {
+		return aggregationShape{}, false
+	}
+
+	metric := selector.Name
+	if len(operations) > 0 {
+		switch n := operations[len(operations)-1]; {
+		case strings.HasPrefix(n, "rate"), strings.HasPrefix(n, "irate"), strings.HasPrefix(n, "increase"):
+			metric = strings.TrimSuffix(metric, "_total")
+		}
+	}
+	if len(operations) == 0 {
+		operations = []string{agg.Op.String()}
+	}
+	level := strings.Join(agg.Grouping, "_")
+	if level == "" {
+		level = "global"
+	}
+
+	return aggregationShape{
+		record:    fmt.Sprintf("%s:%s:%s", level, metric, strings.Join(operations, "_")),
+		expr:      normalised,
+		selectors: []string{selector.String()},
+		lifted:    lifted,
+	}, true
+}
+
+func unwrapParens(expr parser.Expr) parser.Expr {
+	for {
+		p, ok := expr.(*parser.ParenExpr)
+		if !ok {
+			return expr
+		}
+		expr = p.Expr
+	}
+}
+
+// visitor walks an expression, and only visits the children of the nodes for
+// which it returns true.
+type visitor func(parser.Node) bool
+
+func (f visitor) Visit(node parser.Node, _ []parser.Node) (parser.Visitor, error) {
+	if node == nil || !f(node) {
+		return nil, nil
+	}
+	return f, nil
+}
@@ -0,0 +1,102 @@
+package analyse
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/prometheus/model/rulefmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	yaml "gopkg.in/yaml.v3"
+
+	"github.com/cortexproject/cortex-tools/pkg/rules/rwrulefmt"
+)
+
+func TestRecordingRuleSuggestions(t *testing.T) {
+	s := NewRecordingRuleSuggester(5 * time.Minute)
+
+	for _, query := range []string{
+		`sum by (job) (rate(http_requests_total{code="500"}[5m]))`,
+		`sum(rate(http_requests_total{code = "500"}[5m])) by (job) / 2`,
+		`sum by (job) (rate(http_requests_total{code="500", job=~"$job"}[$__rate_interval]))`,
+		`avg by (instance) (avg_over_time(node_load1[5m]))`,
+		`avg by (instance) (avg_over_time(node_load1[5m]))`,
+		`sum(up) > 0`,
+		// Not suggested.
+		`sum by (job) (rate(http_requests_total{code="$code"}[5m]))`,
+		`topk(5, sum by (job) (up))`,
+		`sum without (instance) (up)`,
+		`sum(up) / sum(up offset $__range)`,
+		`count(up)`,
+	} {
+		require.NoError(t, s.AddQuery(query, "dashboard.json"))
+	}
+
+	errs := s.AddRuleGroup(rwrulefmt.RuleGroup{RuleGroup: rulefmt.RuleGroup{
+		Name: "group",
+		Rules: []rulefmt.RuleNode{
+			{
+				Record: yaml.Node{Value: "instance:node_load1:avg5m"},
+				Expr:   yaml.Node{Value: "avg by (instance) (avg_over_time(node_load1[5m]))"},
+			},
+			{
+				Alert: yaml.Node{Value: "HighErrorRate"},
+				Expr:  yaml.Node{Value: `sum by (job) (rate(http_requests_total{code="500"}[5m])) > 1`},
+			},
+		},
+	}}, "ns")
+	require.Empty(t, errs)
+
+	suggestions := s.Suggestions(2)
+	require.Len(t, suggestions, 2)
+
+	assert.Equal(t, "job:http_requests:rate5m", suggestions[0].Record)
+	assert.Equal(t, `sum by (job) (rate(http_requests_total{code="500"}[5m]))`, suggestions[0].Expr)
+	assert.Equal(t, 4, suggestions[0].Count)
+	assert.Equal(t, []string{"dashboard.json", "ns/group/HighErrorRate"}, suggestions[0].Sources)
+	assert.Equal(t, []string{`http_requests_total{code="500"}`}, suggestions[0].Selectors)
+
+	assert.Equal(t, "global:up:sum", suggestions[1].Record)
+	assert.Equal(t, 2, suggestions[1].Count)
+
+	// Estimated series rank the suggestions.
+	suggestions[1].Series = 100
+	suggestions[0].Series = 10
+	SortSuggestions(suggestions)
+	assert.Equal(t, "global:up:sum", suggestions[0].Record)
+}
+
+func TestRewriteQuery(t *testing.T) {
+	s := NewRecordingRuleSuggester(5 * time.Minute)
+	suggestions := []*RecordingRuleSuggestion{
+		{Record: "job:http_requests:rate5m", Expr: `sum by (job) (rate(http_requests_total{code="500"}[5m]))`},
+	}
+
+	tt := []struct {
+		query    string
+		expected string
+		replaced int
+	}{
+		{
+			query:    `sum by (job) (rate(http_requests_total{code="500"}[5m])) / sum by (job) (rate(http_requests_total[5m]))`,
+			expected: `job:http_requests:rate5m / sum by (job) (rate(http_requests_total[5m]))`,
+			replaced: 1,
+		},
+		{
+			query:    `max(sum(rate(http_requests_total{job=~"$job",code="500"}[$__rate_interval])) by (job))`,
+			expected: `max(job:http_requests:rate5m{job=~"$job"})`,
+			replaced: 1,
+		},
+		{
+			query:    `sum by (job) (rate(http_requests_total{code="500"}[1m]))`,
+			expected: `sum by (job) (rate(http_requests_total{code="500"}[1m]))`,
+		},
+	}
+
+	for _, tc := range tt {
+		query, n, err := s.RewriteQuery(tc.query, suggestions)
+		require.NoError(t, err)
+		assert.Equal(t, tc.expected, query)
+		assert.Equal(t, tc.replaced, n)
+	}
+}
@@ -2,26 +2,37 @@ package client
 
 import (
 	"context"
+	"encoding/json"
 	"io"
 
 	"github.com/pkg/errors"
+	"github.com/prometheus/common/model"
 	log "github.com/sirupsen/logrus"
 	"gopkg.in/yaml.v3"
 )
 
-const alertmanagerAPIPath = "/api/v1/alerts"
+const (
+	alertmanagerAPIPath    = "/api/v1/alerts"
+	alertmanagerAlertsPath = "/alertmanager/api/v2/alerts"
+)
 
 type configCompat struct {
 	TemplateFiles      map[string]string `yaml:"template_files"`
 	AlertmanagerConfig string            `yaml:"alertmanager_config"`
 }
 
-// CreateAlertmanagerConfig creates a new alertmanager config
-func (r *CortexClient) CreateAlertmanagerConfig(_ context.Context, cfg string, templates map[string]string) error {
-	payload, err := yaml.Marshal(&configCompat{
+// AlertmanagerConfigPayload returns the request body used to upload an
+// alertmanager config and its templates.
+func AlertmanagerConfigPayload(cfg string, templates map[string]string) ([]byte, error) {
+	return yaml.Marshal(&configCompat{
 		TemplateFiles:      templates,
 		AlertmanagerConfig: cfg,
 	})
+}
+
+// CreateAlertmanagerConfig creates a new alertmanager config
+func (r *CortexClient) CreateAlertmanagerConfig(_ context.Context, cfg string, templates map[string]string) error {
+	payload, err := AlertmanagerConfigPayload(cfg, templates)
 	if err != nil {
 		return err
 	}
@@ -74,3 +85,56 @@ func (r *CortexClient) GetAlertmanagerConfig(_ context.Context) (string, map[str
 
 	return compat.AlertmanagerConfig, compat.TemplateFiles, nil
 }
+
+// Alert is an alert in the alertmanager, with the receivers it is routed to.
+type Alert struct {
+	model.Alert
+	Receivers []struct {
+		Name string `json:"name"`
+	} `json:"receivers"`
+	Status struct {
+		State string `json:"state"`
+	} `json:"status"`
+}
+
+// GetAlerts retrieves the alerts currently in the alertmanager of the tenant.
+func (r *CortexClient) GetAlerts(_ context.Context) ([]Alert, error) {
+	res, err := r.doRequest(alertmanagerAlertsPath, "GET", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var alerts []Alert
+	if err := json.Unmarshal(body, &alerts); err != nil {
+		log.WithFields(log.Fields{
+			"body": string(body),
+		}).Debugln("failed to unmarshal alerts from response")
+
+		return nil, errors.Wrap(err, "unable to unmarshal response")
+	}
+
+	return alerts, nil
+}
+
+// PostAlerts sends alerts to the alertmanager of the tenant.
+func (r *CortexClient) PostAlerts(_ context.Context, alerts []model.Alert) error {
+	payload, err := json.Marshal(alerts)
+	if err != nil {
+		return err
+	}
+
+	res, err := r.doRequestWithContentType(alertmanagerAlertsPath, "POST", "application/json", payload)
+	if err != nil {
+		return err
+	}
+
+	res.Body.Close()
+
+	return nil
+}
@@ -17,8 +17,9 @@ import (
 )
 
 const (
-	rulerAPIPath  = "/api/v1/rules"
-	legacyAPIPath = "/api/prom/rules"
+	rulerAPIPath      = "/api/v1/rules"
+	legacyAPIPath     = "/api/prom/rules"
+	prometheusAPIPath = "/prometheus"
 )
 
 var (
@@ -36,6 +37,8 @@ type Config struct {
 	UseLegacyRoutes bool   `yaml:"use_legacy_routes"`
 	AuthToken       string `yaml:"auth_token"`
 	RulerAPIPath    string `yaml:"ruler_api_path"`
+	// PrometheusAPIPath is the prefix under which the Prometheus HTTP API is served.
+	PrometheusAPIPath string `yaml:"prometheus_api_path"`
 }
 
 // CortexClient is used to get and load rules into a cortex ruler
@@ -47,6 +50,8 @@ type CortexClient struct {
 	Client    http.Client
 	apiPath   string
 	authToken string
+
+	prometheusAPIPath string
 }
 
 // New returns a new Client
@@ -90,6 +95,11 @@ func New(cfg Config) (*CortexClient, error) {
 		path = legacyAPIPath
 	}
 
+	promPath := prometheusAPIPath
+	if cfg.PrometheusAPIPath != "" {
+		promPath = cfg.PrometheusAPIPath
+	}
+
 	return &CortexClient{
 		user:      cfg.User,
 		key:       cfg.Key,
@@ -98,6 +108,8 @@ func New(cfg Config) (*CortexClient, error) {
 		Client:    client,
 		apiPath:   path,
 		authToken: cfg.AuthToken,
+
+		prometheusAPIPath: promPath,
 	}, nil
 }
 
@@ -116,33 +128,24 @@ func (r *CortexClient) Query(_ context.Context, query string) (*http.Response, e
 }
 
 func (r *CortexClient) doRequest(path, method string, payload []byte) (*http.Response, error) {
+	return r.doRequestWithContentType(path, method, "", payload)
+}
+
+// doRequestWithContentType sends a request with the content type of its
+// payload, required by the APIs which don't assume it.
+func (r *CortexClient) doRequestWithContentType(path, method, contentType string, payload []byte) (*http.Response, error) {
 	req, err := buildRequest(path, method, *r.endpoint, payload)
 	if err != nil {
 		return nil, err
 	}
-
-	if (r.user != "" || r.key != "") && r.authToken != "" {
-		err := errors.New("atmost one of basic auth or auth token should be configured")
-		log.WithFields(log.Fields{
-			"url":    req.URL.String(),
-			"method": req.Method,
-			"error":  err,
-		}).Errorln("error during request to cortex api")
-		return nil, err
-	}
-
-	if r.user != "" {
-		req.SetBasicAuth(r.user, r.key)
-	} else if r.key != "" {
-		req.SetBasicAuth(r.id, r.key)
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
 	}
 
-	if r.authToken != "" {
-		req.Header.Add("Authorization", "Bearer "+r.authToken)
+	if err := r.authenticate(req); err != nil {
+		return nil, err
 	}
 
-	req.Header.Add("X-Scope-OrgID", r.id)
-
 	log.WithFields(log.Fields{
 		"url":    req.URL.String(),
 		"method": req.Method,
@@ -166,6 +169,32 @@ func (r *CortexClient) doRequest(path, method string, payload []byte) (*http.Res
 	return resp, nil
 }
 
+// authenticate sets the configured credentials and the tenant ID on the request.
+func (r *CortexClient) authenticate(req *http.Request) error {
+	if (r.user != "" || r.key != "") && r.authToken != "" {
+		err := errors.New("atmost one of basic auth or auth token should be configured")
+		log.WithFields(log.Fields{
+			"url":    req.URL.String(),
+			"method": req.Method,
+			"error":  err,
+		}).Errorln("error during request to cortex api")
+		return err
+	}
+
+	if r.user != "" {
+		req.SetBasicAuth(r.user, r.key)
+	} else if r.key != "" {
+		req.SetBasicAuth(r.id, r.key)
+	}
+
+	if r.authToken != "" {
+		req.Header.Add("Authorization", "Bearer "+r.authToken)
+	}
+
+	req.Header.Add("X-Scope-OrgID", r.id)
+	return nil
+}
+
 // checkResponse checks the API response for errors
 func checkResponse(r *http.Response) error {
 	log.WithFields(log.Fields{
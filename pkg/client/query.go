@@ -0,0 +1,92 @@
+package client
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/api"
+	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	config_util "github.com/prometheus/common/config"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/storage/remote"
+)
+
+// remoteReadPath is the path of the remote read API, under the Prometheus
+// HTTP API prefix.
+const remoteReadPath = "/api/v1/read"
+
+// authRoundTripper sets the credentials and tenant ID of a CortexClient on
+// every request before passing it to the next RoundTripper.
+type authRoundTripper struct {
+	client *CortexClient
+	next   http.RoundTripper
+}
+
+func (rt *authRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	if err := rt.client.authenticate(req); err != nil {
+		return nil, err
+	}
+	return rt.next.RoundTrip(req)
+}
+
+// PrometheusAPI returns a client for the Prometheus HTTP API of the tenant,
+// using the same address, credentials and TLS settings as the CortexClient.
+func (r *CortexClient) PrometheusAPI() (v1.API, error) {
+	next := r.Client.Transport
+	if next == nil {
+		next = api.DefaultRoundTripper
+	}
+
+	endpoint := *r.endpoint
+	endpoint.Path = joinPath(endpoint.Path, r.prometheusAPIPath)
+	if endpoint.RawPath != "" {
+		endpoint.RawPath = joinPath(endpoint.RawPath, r.prometheusAPIPath)
+	}
+
+	promClient, err := api.NewClient(api.Config{
+		Address: endpoint.String(),
+		RoundTripper: &authRoundTripper{
+			client: r,
+			next:   next,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return v1.NewAPI(promClient), nil
+}
+
+// RemoteReadClient returns a client for the remote read API of the tenant,
+// using the same address, credentials and TLS settings as the CortexClient.
+func (r *CortexClient) RemoteReadClient(timeout time.Duration) (remote.ReadClient, error) {
+	endpoint := *r.endpoint
+	endpoint.Path = joinPath(joinPath(endpoint.Path, r.prometheusAPIPath), remoteReadPath)
+	if endpoint.RawPath != "" {
+		endpoint.RawPath = joinPath(joinPath(endpoint.RawPath, r.prometheusAPIPath), remoteReadPath)
+	}
+
+	readClient, err := remote.NewReadClient("cortextool", &remote.ClientConfig{
+		URL:     &config_util.URL{URL: &endpoint},
+		Timeout: model.Duration(timeout),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	c, ok := readClient.(*remote.Client)
+	if !ok {
+		return nil, fmt.Errorf("unexpected type %T", readClient)
+	}
+	next := r.Client.Transport
+	if next == nil {
+		next = api.DefaultRoundTripper
+	}
+	c.Client.Transport = &authRoundTripper{
+		client: r,
+		next:   next,
+	}
+	return readClient, nil
+}
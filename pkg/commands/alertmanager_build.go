@@ -0,0 +1,57 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/alecthomas/kingpin.v2"
+
+	"github.com/cortexproject/cortex-tools/pkg/alertmanager"
+)
+
+func (a *AlertmanagerCommand) buildConfig(_ *kingpin.ParseContext) error {
+	base, err := readConfigFile(a.AlertmanagerConfigFile)
+	if err != nil {
+		return err
+	}
+
+	fragments := make([]alertmanager.ConfigFile, 0, len(a.FragmentFiles))
+	for _, f := range a.FragmentFiles {
+		fragment, err := readConfigFile(f)
+		if err != nil {
+			return err
+		}
+		fragments = append(fragments, fragment)
+	}
+
+	cfg, problems := alertmanager.BuildConfig(base, fragments)
+	if len(problems) != 0 {
+		if err := printProblems(problems, a.Format, os.Stderr); err != nil {
+			return err
+		}
+		return fmt.Errorf("%d problems found while building the alertmanager config", len(problems))
+	}
+
+	if a.OutputFile == "" {
+		fmt.Print(cfg)
+		return nil
+	}
+	if err := os.WriteFile(a.OutputFile, []byte(cfg), 0o644); err != nil {
+		return errors.Wrap(err, "unable to write the alertmanager config")
+	}
+	log.WithFields(log.Fields{
+		"file":      a.OutputFile,
+		"fragments": len(fragments),
+	}).Infof("alertmanager config built")
+	return nil
+}
+
+func readConfigFile(filename string) (alertmanager.ConfigFile, error) {
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return alertmanager.ConfigFile{}, errors.Wrap(err, "unable to load config file: "+filename)
+	}
+	return alertmanager.ConfigFile{Name: filename, Content: string(content)}, nil
+}
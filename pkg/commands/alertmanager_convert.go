@@ -0,0 +1,91 @@
+package commands
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/alecthomas/kingpin.v2"
+
+	"github.com/cortexproject/cortex-tools/pkg/alertmanager"
+	"github.com/cortexproject/cortex-tools/pkg/client"
+	"github.com/cortexproject/cortex-tools/pkg/util"
+)
+
+func (a *AlertmanagerCommand) convertConfig(_ *kingpin.ParseContext) error {
+	if (a.OutputDir == "") == (a.OutputFile == "") {
+		return errors.New("exactly one of --output-dir or --output-file is required")
+	}
+
+	converted, err := alertmanager.ConvertConfig(a.AlertmanagerConfigFile)
+	if err != nil {
+		return errors.Wrap(err, "unable to convert the alertmanager config")
+	}
+	for _, w := range converted.Warnings {
+		log.Warnln(w)
+	}
+
+	if a.OutputFile != "" {
+		if err := checkNotInput(a.OutputFile, a.AlertmanagerConfigFile); err != nil {
+			return err
+		}
+		payload, err := client.AlertmanagerConfigPayload(converted.Config, converted.Templates)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(a.OutputFile, payload, 0o600); err != nil {
+			return errors.Wrap(err, "unable to write the converted config")
+		}
+		log.WithFields(log.Fields{
+			"file":      a.OutputFile,
+			"templates": len(converted.Templates),
+		}).Infof("alertmanager config converted")
+		return nil
+	}
+
+	configFile := filepath.Join(a.OutputDir, filepath.Base(a.AlertmanagerConfigFile))
+	if err := checkNotInput(configFile, a.AlertmanagerConfigFile); err != nil {
+		return err
+	}
+
+	// The secrets are inlined, the files are only readable by their owner.
+	if err := os.MkdirAll(a.OutputDir, 0o700); err != nil {
+		return errors.Wrap(err, "unable to create the output directory")
+	}
+	if err := os.WriteFile(configFile, []byte(converted.Config), 0o600); err != nil {
+		return errors.Wrap(err, "unable to write the converted config")
+	}
+	files := []string{configFile}
+	for _, name := range util.SortedKeys(converted.Templates) {
+		f := filepath.Join(a.OutputDir, name)
+		if err := os.WriteFile(f, []byte(converted.Templates[name]), 0o600); err != nil {
+			return errors.Wrap(err, "unable to write template file")
+		}
+		files = append(files, f)
+	}
+
+	log.WithFields(log.Fields{
+		"dir":       a.OutputDir,
+		"templates": len(converted.Templates),
+	}).Infof("alertmanager config converted, load it with: cortextool alertmanager load %s", strings.Join(files, " "))
+	return nil
+}
+
+// checkNotInput returns an error if the output file is the input config, which
+// would be overwritten with its secrets inlined.
+func checkNotInput(output, input string) error {
+	out, err := filepath.Abs(output)
+	if err != nil {
+		return err
+	}
+	in, err := filepath.Abs(input)
+	if err != nil {
+		return err
+	}
+	if out == in {
+		return errors.Errorf("the converted config would overwrite %s, choose another output", input)
+	}
+	return nil
+}
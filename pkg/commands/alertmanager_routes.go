@@ -0,0 +1,142 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/alecthomas/kingpin.v2"
+
+	"github.com/cortexproject/cortex-tools/pkg/alertmanager"
+)
+
+// setupRoutes creates the client if the routing tree is read from cortex
+// rather than a local config.
+func (a *AlertmanagerCommand) setupRoutes(ctx *kingpin.ParseContext) error {
+	if a.AlertmanagerConfigFile != "" {
+		return nil
+	}
+	return a.setup(ctx)
+}
+
+// loadRoute returns the routing tree of the local config, or of the config
+// stored in cortex.
+func (a *AlertmanagerCommand) loadRoute() (*alertmanager.Route, error) {
+	var cfg string
+	if a.AlertmanagerConfigFile != "" {
+		content, err := os.ReadFile(a.AlertmanagerConfigFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to load config file: "+a.AlertmanagerConfigFile)
+		}
+		cfg = string(content)
+	} else {
+		var err error
+		cfg, _, err = a.cli.GetAlertmanagerConfig(context.Background())
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to get the alertmanager config")
+		}
+	}
+
+	return alertmanager.LoadRoute(cfg)
+}
+
+func (a *AlertmanagerCommand) showRoutes(_ *kingpin.ParseContext) error {
+	route, err := a.loadRoute()
+	if err != nil {
+		return err
+	}
+
+	fmt.Print(alertmanager.FormatRoute(route))
+	return nil
+}
+
+func (a *AlertmanagerCommand) testRoutes(_ *kingpin.ParseContext) error {
+	if len(a.RouteLabels) == 0 && a.RouteTestFile == "" {
+		return errors.New("labels or --test-file are required")
+	}
+
+	route, err := a.loadRoute()
+	if err != nil {
+		return err
+	}
+
+	if len(a.RouteLabels) > 0 {
+		lbls, err := parseLabels(a.RouteLabels)
+		if err != nil {
+			return err
+		}
+		if err := printRouteMatches(alertmanager.MatchRoute(route, lbls), a.Format, os.Stdout); err != nil {
+			return err
+		}
+	}
+
+	if a.RouteTestFile == "" {
+		return nil
+	}
+
+	f, err := alertmanager.LoadRouteTestFile(a.RouteTestFile)
+	if err != nil {
+		return err
+	}
+	failures := alertmanager.TestRoute(route, f.Tests)
+	if err := printRouteTestFailures(failures, a.Format, os.Stdout); err != nil {
+		return err
+	}
+
+	if len(failures) != 0 {
+		return fmt.Errorf("%d of %d route tests failed", len(failures), len(f.Tests))
+	}
+	return nil
+}
+
+// parseLabels parses labels given as name=value.
+func parseLabels(args []string) (map[string]string, error) {
+	lbls := make(map[string]string, len(args))
+	for _, arg := range args {
+		name, value, ok := strings.Cut(arg, "=")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid label %q, expected name=value", arg)
+		}
+		lbls[strings.TrimSpace(name)] = strings.Trim(strings.TrimSpace(value), `"`)
+	}
+	return lbls, nil
+}
+
+func printRouteMatches(matches []alertmanager.RouteMatch, format string, w io.Writer) error {
+	if format == "json" {
+		out, err := json.MarshalIndent(matches, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, string(out))
+		return err
+	}
+
+	for _, m := range matches {
+		fmt.Fprintf(w, "%s  group_by: [%s]  route: %s\n", m.Receiver, strings.Join(m.GroupBy, ", "), m.Route)
+	}
+	return nil
+}
+
+func printRouteTestFailures(failures []alertmanager.RouteTestFailure, format string, w io.Writer) error {
+	if format == "json" {
+		if failures == nil {
+			failures = []alertmanager.RouteTestFailure{}
+		}
+		out, err := json.MarshalIndent(failures, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, string(out))
+		return err
+	}
+
+	for _, f := range failures {
+		fmt.Fprintln(w, f)
+	}
+	return nil
+}
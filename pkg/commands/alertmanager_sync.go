@@ -0,0 +1,87 @@
+package commands
+
+import (
+	"context"
+	"os"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/alecthomas/kingpin.v2"
+
+	"github.com/cortexproject/cortex-tools/pkg/alertmanager"
+	"github.com/cortexproject/cortex-tools/pkg/client"
+	"github.com/cortexproject/cortex-tools/pkg/printer"
+)
+
+func (a *AlertmanagerCommand) diffConfig(_ *kingpin.ParseContext) error {
+	_, _, changes, err := a.compareConfig(context.Background())
+	if err != nil {
+		return errors.Wrap(err, "diff operation unsuccessful")
+	}
+
+	printer.New(a.DisableColor).PrintAlertmanagerConfigChanges(changes, a.Verbose)
+	return nil
+}
+
+func (a *AlertmanagerCommand) syncConfig(_ *kingpin.ParseContext) error {
+	ctx := context.Background()
+	cfg, templates, changes, err := a.compareConfig(ctx)
+	if err != nil {
+		return errors.Wrap(err, "sync operation unsuccessful")
+	}
+
+	if len(changes) == 0 {
+		log.Infof("alertmanager config is up to date, skipping upload")
+		return nil
+	}
+
+	if a.DryRun {
+		printer.New(a.DisableColor).PrintAlertmanagerConfigChanges(changes, a.Verbose)
+		return nil
+	}
+
+	if a.LimitsFile != "" {
+		if err := a.checkLimits(cfg, templates); err != nil {
+			return errors.Wrap(err, "sync operation unsuccessful")
+		}
+	}
+
+	for _, change := range changes {
+		log.WithFields(log.Fields{
+			"section": change.Section,
+			"name":    change.Name,
+			"kind":    change.Kind,
+		}).Infof("updating alertmanager config")
+	}
+
+	if err := a.cli.CreateAlertmanagerConfig(ctx, cfg, templates); err != nil {
+		return errors.Wrap(err, "sync operation unsuccessful, unable to upload the alertmanager config")
+	}
+	return nil
+}
+
+// compareConfig returns the local config and templates, and their changes
+// compared to the ones stored in cortex.
+func (a *AlertmanagerCommand) compareConfig(ctx context.Context) (string, map[string]string, []alertmanager.ConfigChange, error) {
+	content, err := os.ReadFile(a.AlertmanagerConfigFile)
+	if err != nil {
+		return "", nil, nil, errors.Wrap(err, "unable to load config file: "+a.AlertmanagerConfigFile)
+	}
+	cfg := string(content)
+
+	templates, err := createTemplates(a.TemplateFiles)
+	if err != nil {
+		return "", nil, nil, err
+	}
+
+	currentCfg, currentTemplates, err := a.cli.GetAlertmanagerConfig(ctx)
+	if err != nil && err != client.ErrResourceNotFound {
+		return "", nil, nil, errors.Wrap(err, "unable to contact cortex api")
+	}
+
+	changes, err := alertmanager.DiffConfigs(currentCfg, currentTemplates, cfg, templates)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	return cfg, templates, changes, nil
+}
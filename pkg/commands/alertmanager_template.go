@@ -0,0 +1,160 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/common/model"
+	"gopkg.in/alecthomas/kingpin.v2"
+
+	"github.com/cortexproject/cortex-tools/pkg/alertmanager"
+)
+
+// setupTemplateRender creates the client if the templates or the alerts are
+// read from cortex.
+func (a *AlertmanagerCommand) setupTemplateRender(ctx *kingpin.ParseContext) error {
+	local := a.AlertmanagerConfigFile != "" || len(a.TemplateFiles) > 0
+	if local && !a.FromCluster {
+		return nil
+	}
+	return a.setup(ctx)
+}
+
+func (a *AlertmanagerCommand) renderTemplate(_ *kingpin.ParseContext) error {
+	if (a.TemplateName == "") == (a.Receiver == "") {
+		return errors.New("exactly one of --template or --receiver is required")
+	}
+	if (a.AlertsFile == "") == !a.FromCluster {
+		return errors.New("exactly one of --alerts-file or --from-cluster is required")
+	}
+
+	externalURL, err := url.Parse(a.ExternalURL)
+	if err != nil {
+		return errors.Wrap(err, "invalid external URL")
+	}
+
+	cfg, templates, err := a.loadTemplates()
+	if err != nil {
+		return err
+	}
+	if a.Receiver != "" && cfg == "" {
+		return errors.New("--receiver requires an alertmanager config")
+	}
+
+	var alerts []model.Alert
+	if a.FromCluster {
+		current, err := a.cli.GetAlerts(context.Background())
+		if err != nil {
+			return errors.Wrap(err, "unable to get the alerts")
+		}
+		for _, alert := range current {
+			alerts = append(alerts, alert.Alert)
+		}
+	} else {
+		alerts, err = alertmanager.LoadSampleAlerts(a.AlertsFile)
+		if err != nil {
+			return err
+		}
+	}
+	if len(alerts) == 0 {
+		return errors.New("no alerts to render the templates with")
+	}
+
+	tmpl, err := alertmanager.NewTemplate(templates, externalURL)
+	if err != nil {
+		return err
+	}
+
+	// The alerts are grouped by the labels of the route of the first one, as
+	// in a notification.
+	groupLabels := model.LabelSet{}
+	if cfg != "" {
+		route, err := alertmanager.LoadRoute(cfg)
+		if err != nil {
+			return err
+		}
+		groupLabels = alertmanager.GroupLabels(route, alerts[0])
+	}
+	data := alertmanager.TemplateData(tmpl, a.Receiver, groupLabels, alerts)
+
+	var rendered []alertmanager.RenderedTemplate
+	if a.TemplateName != "" {
+		rendered = []alertmanager.RenderedTemplate{alertmanager.RenderTemplate(tmpl, a.TemplateName, data)}
+	} else {
+		rendered, err = alertmanager.RenderReceiver(tmpl, cfg, a.Receiver, data)
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := printRenderedTemplates(rendered, a.Format, os.Stdout); err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, r := range rendered {
+		if r.Error != "" {
+			failed++
+		}
+	}
+	if failed != 0 {
+		return fmt.Errorf("%d templates failed to render", failed)
+	}
+	return nil
+}
+
+// loadTemplates returns the local config and templates, or the ones stored in
+// cortex if none are given.
+func (a *AlertmanagerCommand) loadTemplates() (string, map[string]string, error) {
+	if a.AlertmanagerConfigFile == "" && len(a.TemplateFiles) == 0 {
+		cfg, templates, err := a.cli.GetAlertmanagerConfig(context.Background())
+		if err != nil {
+			return "", nil, errors.Wrap(err, "unable to get the alertmanager config")
+		}
+		return cfg, templates, nil
+	}
+
+	var cfg string
+	if a.AlertmanagerConfigFile != "" {
+		content, err := os.ReadFile(a.AlertmanagerConfigFile)
+		if err != nil {
+			return "", nil, errors.Wrap(err, "unable to load config file: "+a.AlertmanagerConfigFile)
+		}
+		cfg = string(content)
+	}
+
+	templates, err := createTemplates(a.TemplateFiles)
+	if err != nil {
+		return "", nil, err
+	}
+	return cfg, templates, nil
+}
+
+func printRenderedTemplates(rendered []alertmanager.RenderedTemplate, format string, w io.Writer) error {
+	if format == "json" {
+		if rendered == nil {
+			rendered = []alertmanager.RenderedTemplate{}
+		}
+		out, err := json.MarshalIndent(rendered, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, string(out))
+		return err
+	}
+
+	for _, r := range rendered {
+		fmt.Fprintf(w, "==> %s\n", r.Name)
+		if r.Error != "" {
+			fmt.Fprintf(w, "error: %s\n\n", r.Error)
+			continue
+		}
+		fmt.Fprintf(w, "%s\n\n", r.Output)
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/common/model"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/alecthomas/kingpin.v2"
+
+	"github.com/cortexproject/cortex-tools/pkg/alertmanager"
+)
+
+// testReceiverPollInterval is how often the alerts are listed while waiting
+// for the test alert to be routed.
+const testReceiverPollInterval = 2 * time.Second
+
+func (a *AlertmanagerCommand) testReceiver(_ *kingpin.ParseContext) error {
+	cfg, _, err := a.cli.GetAlertmanagerConfig(context.Background())
+	if err != nil {
+		return errors.Wrap(err, "unable to get the alertmanager config")
+	}
+	route, err := alertmanager.LoadRoute(cfg)
+	if err != nil {
+		return err
+	}
+
+	extra, err := parseLabels(a.TestLabels)
+	if err != nil {
+		return err
+	}
+	lbls, err := alertmanager.TestAlertLabels(route, a.Receiver, toLabelSet(extra))
+	if err != nil {
+		return err
+	}
+	id := strconv.FormatInt(time.Now().UnixNano(), 36)
+	lbls[alertmanager.TestIDLabel] = model.LabelValue(id)
+
+	var sink *alertmanager.WebhookSink
+	if a.WebhookListenAddress != "" {
+		sink = alertmanager.NewWebhookSink()
+		l, err := net.Listen("tcp", a.WebhookListenAddress)
+		if err != nil {
+			return errors.Wrap(err, "unable to listen for webhook notifications")
+		}
+		srv := &http.Server{Handler: sink, ReadHeaderTimeout: 10 * time.Second}
+		go srv.Serve(l) //nolint:errcheck
+		defer srv.Close()
+		log.WithField("address", l.Addr().String()).Infof("listening for webhook notifications")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), a.TestTimeout)
+	defer cancel()
+
+	start := time.Now()
+	alert := model.Alert{
+		Labels: lbls,
+		Annotations: model.LabelSet{
+			"summary":     model.LabelValue(fmt.Sprintf("Test notification sent by cortextool to receiver %s", a.Receiver)),
+			"description": "This alert tests the delivery of notifications, and can be ignored.",
+		},
+		StartsAt: start,
+		EndsAt:   start.Add(a.TestTimeout),
+	}
+	if err := a.cli.PostAlerts(ctx, []model.Alert{alert}); err != nil {
+		return errors.Wrap(err, "unable to send the test alert")
+	}
+	log.WithField("labels", lbls.String()).Infof("test alert sent")
+
+	if a.ResolveTestAlert {
+		defer func() {
+			alert.EndsAt = time.Now()
+			if err := a.cli.PostAlerts(context.Background(), []model.Alert{alert}); err != nil {
+				log.WithError(err).Warnln("unable to resolve the test alert")
+			}
+		}()
+	}
+
+	receivers, err := a.waitTestAlertRouted(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !slices.Contains(receivers, a.Receiver) {
+		return fmt.Errorf("the test alert was routed to %v, not to receiver %s", receivers, a.Receiver)
+	}
+	log.WithFields(log.Fields{
+		"receivers": receivers,
+		"duration":  time.Since(start).Round(time.Millisecond),
+	}).Infof("test alert routed to the receiver")
+
+	if sink == nil {
+		log.Infof("cortex doesn't report the delivery of notifications, check that the receiver notified the test alert, or use --webhook-listen-address to verify the delivery of webhook receivers")
+		return nil
+	}
+
+	if err := sink.Wait(ctx, id); err != nil {
+		return fmt.Errorf("no notification of the test alert received within %s, the notification can be delayed by the group_wait of the route", a.TestTimeout)
+	}
+	log.WithField("duration", time.Since(start).Round(time.Millisecond)).Infof("notification of the test alert received")
+	return nil
+}
+
+// waitTestAlertRouted waits until the alertmanager has the test alert, and
+// returns the receivers it is routed to.
+func (a *AlertmanagerCommand) waitTestAlertRouted(ctx context.Context, id string) ([]string, error) {
+	ticker := time.NewTicker(testReceiverPollInterval)
+	defer ticker.Stop()
+
+	for {
+		alerts, err := a.cli.GetAlerts(ctx)
+		if err != nil {
+			log.WithError(err).Warnln("unable to list the alerts")
+		}
+		for _, alert := range alerts {
+			if string(alert.Labels[alertmanager.TestIDLabel]) != id {
+				continue
+			}
+			receivers := make([]string, 0, len(alert.Receivers))
+			for _, r := range alert.Receivers {
+				receivers = append(receivers, r.Name)
+			}
+			return receivers, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("the test alert wasn't found in the alertmanager within %s", a.TestTimeout)
+		case <-ticker.C:
+		}
+	}
+}
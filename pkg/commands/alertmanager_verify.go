@@ -0,0 +1,62 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/alecthomas/kingpin.v2"
+
+	"github.com/cortexproject/cortex-tools/pkg/alertmanager"
+)
+
+func (a *AlertmanagerCommand) verifyConfig(_ *kingpin.ParseContext) error {
+	content, err := os.ReadFile(a.AlertmanagerConfigFile)
+	if err != nil {
+		return errors.Wrap(err, "unable to load config file: "+a.AlertmanagerConfigFile)
+	}
+
+	templates, err := createTemplates(a.TemplateFiles)
+	if err != nil {
+		return err
+	}
+
+	var opts alertmanager.VerifyOptions
+	for _, r := range strings.Split(a.DisallowedReceivers, ",") {
+		if r = strings.TrimSpace(r); r != "" {
+			opts.DisallowedReceivers = append(opts.DisallowedReceivers, r)
+		}
+	}
+
+	problems := alertmanager.Verify(a.AlertmanagerConfigFile, string(content), templates, opts)
+	if err := printProblems(problems, a.Format, os.Stdout); err != nil {
+		return err
+	}
+
+	if len(problems) != 0 {
+		return fmt.Errorf("%d problems found in the alertmanager config and templates", len(problems))
+	}
+	return nil
+}
+
+func printProblems(problems []alertmanager.Problem, format string, w io.Writer) error {
+	if format == "json" {
+		if problems == nil {
+			problems = []alertmanager.Problem{}
+		}
+		out, err := json.MarshalIndent(problems, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, string(out))
+		return err
+	}
+
+	for _, p := range problems {
+		fmt.Fprintln(w, p)
+	}
+	return nil
+}
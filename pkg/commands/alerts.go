@@ -24,8 +24,11 @@ import (
 	log "github.com/sirupsen/logrus"
 	"gopkg.in/alecthomas/kingpin.v2"
 
+	"github.com/cortexproject/cortex-tools/pkg/alertmanager"
 	"github.com/cortexproject/cortex-tools/pkg/client"
+	"github.com/cortexproject/cortex-tools/pkg/limits"
 	"github.com/cortexproject/cortex-tools/pkg/printer"
+	"github.com/cortexproject/cortex-tools/pkg/util"
 )
 
 var (
@@ -44,6 +47,26 @@ type AlertmanagerCommand struct {
 	AlertmanagerConfigFile string
 	TemplateFiles          []string
 	DisableColor           bool
+	LimitsFile             string
+	DisallowedReceivers    string
+	Format                 string
+	DryRun                 bool
+	Verbose                bool
+	RouteLabels            []string
+	RouteTestFile          string
+	TemplateName           string
+	Receiver               string
+	AlertsFile             string
+	FromCluster            bool
+	ExternalURL            string
+	FragmentFiles          []string
+	OutputFile             string
+	OutputDir              string
+	ShowSecrets            bool
+	TestLabels             []string
+	TestTimeout            time.Duration
+	WebhookListenAddress   string
+	ResolveTestAlert       bool
 
 	cli *client.CortexClient
 }
@@ -63,9 +86,9 @@ type AlertCommand struct {
 
 // Register rule related commands and flags with the kingpin application
 func (a *AlertmanagerCommand) Register(app *kingpin.Application) {
-	alertCmd := app.Command("alertmanager", "View & edit alertmanager configs stored in cortex.").PreAction(a.setup)
-	alertCmd.Flag("address", "Address of the cortex cluster, alternatively set CORTEX_ADDRESS.").Envar("CORTEX_ADDRESS").Required().StringVar(&a.ClientConfig.Address)
-	alertCmd.Flag("id", "Cortex tenant id, alternatively set CORTEX_TENANT_ID.").Envar("CORTEX_TENANT_ID").Required().StringVar(&a.ClientConfig.ID)
+	alertCmd := app.Command("alertmanager", "View & edit alertmanager configs stored in cortex.")
+	alertCmd.Flag("address", "Address of the cortex cluster, alternatively set CORTEX_ADDRESS. Required by the commands contacting cortex.").Envar("CORTEX_ADDRESS").StringVar(&a.ClientConfig.Address)
+	alertCmd.Flag("id", "Cortex tenant id, alternatively set CORTEX_TENANT_ID. Required by the commands contacting cortex.").Envar("CORTEX_TENANT_ID").StringVar(&a.ClientConfig.ID)
 	alertCmd.Flag("authToken", "Authentication token for bearer token or JWT auth, alternatively set CORTEX_AUTH_TOKEN.").Default("").Envar("CORTEX_AUTH_TOKEN").StringVar(&a.ClientConfig.AuthToken)
 	alertCmd.Flag("user", "API user to use when contacting cortex, alternatively set CORTEX_API_USER. If empty, CORTEX_TENANT_ID will be used instead.").Default("").Envar("CORTEX_API_USER").StringVar(&a.ClientConfig.User)
 	alertCmd.Flag("key", "API key to use when contacting cortex, alternatively set CORTEX_API_KEY.").Default("").Envar("CORTEX_API_KEY").StringVar(&a.ClientConfig.Key)
@@ -74,17 +97,85 @@ func (a *AlertmanagerCommand) Register(app *kingpin.Application) {
 	alertCmd.Flag("tls-key-path", "TLS client certificate private key to authenticate with cortex API as part of mTLS, alternatively set CORTEX_TLS_KEY_PATH.").Default("").Envar("CORTEX_TLS_KEY_PATH").StringVar(&a.ClientConfig.TLS.KeyPath)
 
 	// Get Alertmanager Configs Command
-	getAlertsCmd := alertCmd.Command("get", "Get the alertmanager config currently in the cortex alertmanager.").Action(a.getConfig)
+	getAlertsCmd := alertCmd.Command("get", "Get the alertmanager config currently in the cortex alertmanager.").PreAction(a.setup).Action(a.getConfig)
 	getAlertsCmd.Flag("disable-color", "disable colored output").BoolVar(&a.DisableColor)
+	getAlertsCmd.Flag("show-secrets", "Print the secrets of the config, such as passwords, API keys and webhook URLs, instead of <secret>.").BoolVar(&a.ShowSecrets)
+	getAlertsCmd.Flag("format", "Output format: <text|yaml|json>. The yaml and json formats are the ones of the cortex alertmanager config API.").Default("text").EnumVar(&a.Format, "text", "yaml", "json")
+	getAlertsCmd.Flag("output-dir", "Directory to write the config to, as alertmanager.yaml, and every template to its own file, as loaded by the load command.").StringVar(&a.OutputDir)
 
-	alertCmd.Command("delete", "Delete the alertmanager config currently in the cortex alertmanager.").Action(a.deleteConfig)
+	alertCmd.Command("delete", "Delete the alertmanager config currently in the cortex alertmanager.").PreAction(a.setup).Action(a.deleteConfig)
 
-	loadalertCmd := alertCmd.Command("load", "load a set of rules to a designated cortex endpoint").Action(a.loadConfig)
+	loadalertCmd := alertCmd.Command("load", "load a set of rules to a designated cortex endpoint").PreAction(a.setup).Action(a.loadConfig)
 	loadalertCmd.Arg("config", "alertmanager configuration to load").Required().StringVar(&a.AlertmanagerConfigFile)
 	loadalertCmd.Arg("template-files", "The template files to load").ExistingFilesVar(&a.TemplateFiles)
+	loadalertCmd.Flag("limits-file", "File with the per-tenant limits in the Cortex runtime config format. The config is not loaded if it exceeds the limits of the tenant.").ExistingFileVar(&a.LimitsFile)
+
+	diffCmd := alertCmd.Command("diff", "Show the changes between the alertmanager config and templates stored in cortex and the local ones. Secrets are redacted.").PreAction(a.setup).Action(a.diffConfig)
+	diffCmd.Arg("config", "alertmanager configuration to compare").Required().ExistingFileVar(&a.AlertmanagerConfigFile)
+	diffCmd.Arg("template-files", "The template files to compare").ExistingFilesVar(&a.TemplateFiles)
+	diffCmd.Flag("disable-color", "disable colored output").BoolVar(&a.DisableColor)
+	diffCmd.Flag("verbose", "show the diff of every changed receiver, template and section").BoolVar(&a.Verbose)
+
+	syncCmd := alertCmd.Command("sync", "Upload the alertmanager config and templates only if they differ from the ones stored in cortex.").PreAction(a.setup).Action(a.syncConfig)
+	syncCmd.Arg("config", "alertmanager configuration to sync").Required().ExistingFileVar(&a.AlertmanagerConfigFile)
+	syncCmd.Arg("template-files", "The template files to sync").ExistingFilesVar(&a.TemplateFiles)
+	syncCmd.Flag("limits-file", "File with the per-tenant limits in the Cortex runtime config format. The sync is aborted if the config exceeds the limits of the tenant.").ExistingFileVar(&a.LimitsFile)
+	syncCmd.Flag("dry-run", "Print the changes which would be uploaded without uploading them.").Short('n').BoolVar(&a.DryRun)
+	syncCmd.Flag("disable-color", "disable colored output").BoolVar(&a.DisableColor)
+	syncCmd.Flag("verbose", "show the diff of every changed receiver, template and section").BoolVar(&a.Verbose)
+
+	routesCmd := alertCmd.Command("routes", "Show and test the routing tree of an alertmanager config.")
+	showRoutesCmd := routesCmd.Command("show", "Print the routing tree of a local alertmanager config, or of the one stored in cortex.").PreAction(a.setupRoutes).Action(a.showRoutes)
+	showRoutesCmd.Flag("config-file", "Local alertmanager configuration. If empty, the config stored in cortex is used.").ExistingFileVar(&a.AlertmanagerConfigFile)
+
+	testRoutesCmd := routesCmd.Command("test", "Print the receivers and group by keys a label set is routed to, or check the receivers of the test cases of a file.").PreAction(a.setupRoutes).Action(a.testRoutes)
+	testRoutesCmd.Arg("labels", "Labels of the alert, as in severity=critical.").StringsVar(&a.RouteLabels)
+	testRoutesCmd.Flag("config-file", "Local alertmanager configuration. If empty, the config stored in cortex is used.").ExistingFileVar(&a.AlertmanagerConfigFile)
+	testRoutesCmd.Flag("test-file", "YAML file of label sets and the receivers they must be routed to. The command fails if any test case fails.").ExistingFileVar(&a.RouteTestFile)
+	testRoutesCmd.Flag("format", "Output format: <json|text>").Default("text").EnumVar(&a.Format, "json", "text")
+
+	templateCmd := alertCmd.Command("template", "Preview the notification templates of an alertmanager config.")
+	renderTemplateCmd := templateCmd.Command("render", "Render a named template, or the templated fields of a receiver, with sample or firing alerts.").PreAction(a.setupTemplateRender).Action(a.renderTemplate)
+	renderTemplateCmd.Arg("template-files", "The template files to render. If empty, the templates stored in cortex are used, with their config.").ExistingFilesVar(&a.TemplateFiles)
+	renderTemplateCmd.Flag("config-file", "Local alertmanager configuration, required to render a receiver with local template files.").ExistingFileVar(&a.AlertmanagerConfigFile)
+	renderTemplateCmd.Flag("template", "Name of the template to render, as in slack.default.title.").StringVar(&a.TemplateName)
+	renderTemplateCmd.Flag("receiver", "Name of the receiver whose templated fields, such as title and text, are rendered.").StringVar(&a.Receiver)
+	renderTemplateCmd.Flag("alerts-file", "JSON file of sample alerts, in the format of the alertmanager API.").ExistingFileVar(&a.AlertsFile)
+	renderTemplateCmd.Flag("from-cluster", "Render the templates with the alerts currently in the cortex alertmanager of the tenant.").BoolVar(&a.FromCluster)
+	renderTemplateCmd.Flag("external-url", "External URL of the alertmanager, exposed to the templates as .ExternalURL.").Default("http://localhost:9093").StringVar(&a.ExternalURL)
+	renderTemplateCmd.Flag("format", "Output format: <json|text>").Default("text").EnumVar(&a.Format, "json", "text")
+
+	buildCmd := alertCmd.Command("build", "Build an alertmanager config by merging per-team fragments holding routes, receivers, inhibit rules, time intervals and templates into a base config.").Action(a.buildConfig)
+	buildCmd.Arg("config", "Base alertmanager configuration").Required().ExistingFileVar(&a.AlertmanagerConfigFile)
+	buildCmd.Arg("fragment-files", "The fragment files to merge, in order. Their routes are appended to the routes of the root route.").ExistingFilesVar(&a.FragmentFiles)
+	buildCmd.Flag("output-file", "File to write the config to. If empty, the config is printed.").StringVar(&a.OutputFile)
+	buildCmd.Flag("format", "Output format of the problems: <json|text>").Default("text").EnumVar(&a.Format, "json", "text")
+
+	convertCmd := alertCmd.Command("convert", "Convert the config of a standalone alertmanager into a config cortex accepts, inlining the files it references and loading its templates.").Action(a.convertConfig)
+	convertCmd.Arg("config", "alertmanager configuration to convert").Required().ExistingFileVar(&a.AlertmanagerConfigFile)
+	convertCmd.Flag("output-dir", "Directory to write the converted config and its template files to, ready for alertmanager load.").StringVar(&a.OutputDir)
+	convertCmd.Flag("output-file", "File to write the converted config and its template files to, in the format of the cortex alertmanager config API.").StringVar(&a.OutputFile)
+
+	testReceiverCmd := alertCmd.Command("test-receiver", "Send a test alert routed to a receiver of the alertmanager config stored in cortex, and wait until it is routed, or notified to the local webhook sink.").PreAction(a.setup).Action(a.testReceiver)
+	testReceiverCmd.Arg("receiver", "Name of the receiver to test").Required().StringVar(&a.Receiver)
+	testReceiverCmd.Flag("label", "Label of the test alert, as in team=db. Labels routing the alert to the receiver are found from the routes if not set. Flag can be reused to set multiple labels.").StringsVar(&a.TestLabels)
+	testReceiverCmd.Flag("timeout", "How long to wait for the test alert to be routed and notified.").Default("2m").DurationVar(&a.TestTimeout)
+	testReceiverCmd.Flag("webhook-listen-address", "Address to listen on for webhook notifications, as in :9095. The webhook receiver must send its notifications to it, and the command waits until the test alert is notified.").StringVar(&a.WebhookListenAddress)
+	testReceiverCmd.Flag("resolve", "Resolve the test alert once the test is done.").Default("true").BoolVar(&a.ResolveTestAlert)
+
+	verifyCmd := alertCmd.Command("verify", "Verify an alertmanager config and its templates offline, as cortex would when loading them.").Action(a.verifyConfig)
+	verifyCmd.Arg("config", "alertmanager configuration to verify").Required().ExistingFileVar(&a.AlertmanagerConfigFile)
+	verifyCmd.Arg("template-files", "The template files to verify").ExistingFilesVar(&a.TemplateFiles)
+	verifyCmd.Flag("disallowed-receivers", "Comma separated list of the integrations receivers cannot use, as in email,webhook.").StringVar(&a.DisallowedReceivers)
+	verifyCmd.Flag("format", "Output format: <json|text>").Default("text").EnumVar(&a.Format, "json", "text")
 }
 
+// setup creates the client of the commands contacting cortex.
 func (a *AlertmanagerCommand) setup(_ *kingpin.ParseContext) error {
+	if a.ClientConfig.Address == "" || a.ClientConfig.ID == "" {
+		return errors.New("--address and --id are required to contact cortex")
+	}
+
 	cli, err := client.New(a.ClientConfig)
 	if err != nil {
 		return err
@@ -104,11 +195,76 @@ func (a *AlertmanagerCommand) getConfig(_ *kingpin.ParseContext) error {
 		return err
 	}
 
+	if !a.ShowSecrets {
+		cfg, err = alertmanager.RedactSecrets(cfg)
+		if err != nil {
+			return errors.Wrap(err, "unable to redact the secrets of the alertmanager config, use --show-secrets to print it as is")
+		}
+	}
+
+	if a.OutputDir != "" {
+		return a.writeConfig(cfg, templates)
+	}
+
+	switch a.Format {
+	case "yaml":
+		payload, err := client.AlertmanagerConfigPayload(cfg, templates)
+		if err != nil {
+			return err
+		}
+		fmt.Print(string(payload))
+		return nil
+	case "json":
+		// <secret> is kept readable.
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.SetEscapeHTML(false)
+		return enc.Encode(struct {
+			TemplateFiles      map[string]string `json:"template_files"`
+			AlertmanagerConfig string            `json:"alertmanager_config"`
+		}{templates, cfg})
+	}
+
 	p := printer.New(a.DisableColor)
 
 	return p.PrintAlertmanagerConfig(cfg, templates)
 }
 
+// writeConfig writes the config to alertmanager.yaml and every template to its
+// own file in the output directory, as loaded by the load command.
+func (a *AlertmanagerCommand) writeConfig(cfg string, templates map[string]string) error {
+	if !a.ShowSecrets {
+		log.Warnln("the secrets of the alertmanager config are redacted, use --show-secrets to write a config which can be loaded back")
+	}
+
+	// The files are only readable by their owner if they hold secrets.
+	mode := os.FileMode(0o644)
+	if a.ShowSecrets {
+		mode = 0o600
+	}
+
+	if err := os.MkdirAll(a.OutputDir, 0o755); err != nil {
+		return errors.Wrap(err, "unable to create the output directory")
+	}
+	if err := os.WriteFile(filepath.Join(a.OutputDir, "alertmanager.yaml"), []byte(cfg), mode); err != nil {
+		return errors.Wrap(err, "unable to write the alertmanager config")
+	}
+	for _, name := range util.SortedKeys(templates) {
+		if name != filepath.Base(name) || name == "alertmanager.yaml" {
+			return fmt.Errorf("unable to write template file %q, invalid name", name)
+		}
+		if err := os.WriteFile(filepath.Join(a.OutputDir, name), []byte(templates[name]), mode); err != nil {
+			return errors.Wrap(err, "unable to write template file")
+		}
+	}
+
+	log.WithFields(log.Fields{
+		"dir":       a.OutputDir,
+		"templates": len(templates),
+	}).Infof("alertmanager config written")
+	return nil
+}
+
 func (a *AlertmanagerCommand) loadConfig(_ *kingpin.ParseContext) error {
 	content, err := os.ReadFile(a.AlertmanagerConfigFile)
 	if err != nil {
@@ -126,9 +282,37 @@ func (a *AlertmanagerCommand) loadConfig(_ *kingpin.ParseContext) error {
 		return err
 	}
 
+	if a.LimitsFile != "" {
+		if err := a.checkLimits(cfg, templates); err != nil {
+			return err
+		}
+	}
+
 	return a.cli.CreateAlertmanagerConfig(context.Background(), cfg, templates)
 }
 
+// checkLimits returns an error if the config or its templates exceed the
+// limits of the tenant.
+func (a *AlertmanagerCommand) checkLimits(cfg string, templates map[string]string) error {
+	tenantLimits, err := limits.LoadTenantLimits(a.LimitsFile, a.ClientConfig.ID)
+	if err != nil {
+		return errors.Wrap(err, "unable to load limits file")
+	}
+
+	errs, err := tenantLimits.ValidateAlertmanagerConfig(cfg, templates)
+	if err != nil {
+		return err
+	}
+	for _, err := range errs {
+		log.WithError(err).Errorf("limit exceeded")
+	}
+
+	if len(errs) != 0 {
+		return fmt.Errorf("%d limits exceeded", len(errs))
+	}
+	return nil
+}
+
 func createTemplates(templateFiles []string) (map[string]string, error) {
 	templates := make(map[string]string)
 	for _, f := range templateFiles {
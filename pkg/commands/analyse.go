@@ -91,4 +91,52 @@ func (cmd *AnalyseCommand) Register(app *kingpin.Application) {
 	ruleFileAnalyseCmd.Flag("output", "The path for the output file").
 		Default("metrics-in-ruler.json").
 		StringVar(&rfCmd.outputFile)
+
+	srCmd := &SuggestRecordingRulesCommand{}
+	suggestRecordingRulesCmd := analyseCmd.Command("suggest-recording-rules", "Suggest recording rules for the aggregations repeated across dashboard and rule queries.").Action(srCmd.run)
+	suggestRecordingRulesCmd.Flag("rule-files", "Comma separated list of rule files to analyse.").StringVar(&srCmd.ruleFiles)
+	suggestRecordingRulesCmd.Flag(
+		"rule-dirs",
+		"Comma separated list of paths to directories containing rules yaml files. Each file in a directory with a .yml or .yaml suffix will be analysed.",
+	).StringVar(&srCmd.ruleFilesPath)
+	suggestRecordingRulesCmd.Flag("dashboard-files", "Comma separated list of Grafana dashboard files to analyse.").StringVar(&srCmd.dashboardFiles)
+	suggestRecordingRulesCmd.Flag(
+		"dashboard-dirs",
+		"Comma separated list of paths to directories containing Grafana dashboards. Each file in a directory with a .json suffix will be analysed.",
+	).StringVar(&srCmd.dashboardPath)
+	suggestRecordingRulesCmd.Flag("address", "Address of the Prometheus/Cortex instance used to estimate the series touched by each aggregation, alternatively set $CORTEX_ADDRESS. Suggestions are only ranked by frequency when not set.").
+		Envar("CORTEX_ADDRESS").
+		Default("").
+		StringVar(&srCmd.address)
+	suggestRecordingRulesCmd.Flag("id", "Username to use when contacting Prometheus/Cortex, alternatively set $CORTEX_TENANT_ID.").
+		Envar("CORTEX_TENANT_ID").
+		Default("").
+		StringVar(&srCmd.username)
+	suggestRecordingRulesCmd.Flag("key", "Password to use when contacting Prometheus/Cortex, alternatively set $CORTEX_API_KEY.").
+		Envar("CORTEX_API_KEY").
+		Default("").
+		StringVar(&srCmd.password)
+	suggestRecordingRulesCmd.Flag("read-timeout", "timeout for read requests").
+		Default("30s").
+		DurationVar(&srCmd.readTimeout)
+	suggestRecordingRulesCmd.Flag("interval", "Range replacing the Grafana interval variables, such as $__rate_interval, in the suggested expressions.").
+		Default("5m").
+		DurationVar(&srCmd.interval)
+	suggestRecordingRulesCmd.Flag("min-count", "Minimum number of queries using an aggregation for it to be suggested.").
+		Default("2").
+		IntVar(&srCmd.minCount)
+	suggestRecordingRulesCmd.Flag("top", "Maximum number of recording rules to suggest, 0 for no limit.").
+		Default("0").
+		IntVar(&srCmd.top)
+	suggestRecordingRulesCmd.Flag("namespace", "Namespace of the suggested recording rules file.").
+		Default("").
+		StringVar(&srCmd.namespace)
+	suggestRecordingRulesCmd.Flag("group-name", "Name of the rule group of the suggested recording rules.").
+		Default("suggested_recording_rules").
+		StringVar(&srCmd.groupName)
+	suggestRecordingRulesCmd.Flag("output", "The path for the suggested recording rules file").
+		Default("suggested-recording-rules.yaml").
+		StringVar(&srCmd.outputFile)
+	suggestRecordingRulesCmd.Flag("rewrite-dashboards", "Replace the suggested aggregations in the dashboard files by the series recorded by the suggested rules.").
+		BoolVar(&srCmd.rewriteDashboards)
 }
@@ -0,0 +1,256 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/grafana-tools/sdk"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/api"
+	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/config"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/rulefmt"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/alecthomas/kingpin.v2"
+	yamlv3 "gopkg.in/yaml.v3"
+
+	"github.com/cortexproject/cortex-tools/pkg/analyse"
+	"github.com/cortexproject/cortex-tools/pkg/httpmiddleware"
+	"github.com/cortexproject/cortex-tools/pkg/refactor"
+	"github.com/cortexproject/cortex-tools/pkg/rules"
+	"github.com/cortexproject/cortex-tools/pkg/rules/rwrulefmt"
+	"github.com/cortexproject/cortex-tools/pkg/util"
+)
+
+type SuggestRecordingRulesCommand struct {
+	address     string
+	username    string
+	password    string
+	readTimeout time.Duration
+
+	ruleFiles      string
+	ruleFilesPath  string
+	dashboardFiles string
+	dashboardPath  string
+
+	interval          time.Duration
+	minCount          int
+	top               int
+	namespace         string
+	groupName         string
+	outputFile        string
+	rewriteDashboards bool
+}
+
+func (cmd *SuggestRecordingRulesCommand) run(_ *kingpin.ParseContext) error {
+	ruleFiles, err := listFiles(cmd.ruleFiles, cmd.ruleFilesPath, findRuleFiles)
+	if err != nil {
+		return err
+	}
+	dashboardFiles, err := listFiles(cmd.dashboardFiles, cmd.dashboardPath, findDashboardFiles)
+	if err != nil {
+		return err
+	}
+	if len(ruleFiles) == 0 && len(dashboardFiles) == 0 {
+		return errors.New("no rule files or dashboards to analyse")
+	}
+
+	suggester := analyse.NewRecordingRuleSuggester(cmd.interval)
+
+	if len(ruleFiles) > 0 {
+		nss, err := rules.ParseFiles(ruleFiles)
+		if err != nil {
+			return errors.Wrap(err, "analyse operation unsuccessful, unable to parse rules files")
+		}
+		for _, name := range util.SortedKeys(nss) {
+			for _, group := range nss[name].Groups {
+				for _, err := range suggester.AddRuleGroup(group, name) {
+					log.WithError(err).WithField("group", group.Name).Warnln("unable to parse rule expression")
+				}
+			}
+		}
+	}
+
+	for _, file := range dashboardFiles {
+		board, err := loadBoard(file)
+		if err != nil {
+			return err
+		}
+		for _, err := range suggester.AddBoard(board, file) {
+			log.WithError(err).WithField("file", file).Debugln("unable to parse dashboard query")
+		}
+	}
+
+	suggestions := suggester.Suggestions(cmd.minCount)
+
+	if cmd.address != "" {
+		if err := cmd.estimateSeries(suggestions); err != nil {
+			return err
+		}
+		analyse.SortSuggestions(suggestions)
+	}
+
+	if cmd.top > 0 && len(suggestions) > cmd.top {
+		suggestions = suggestions[:cmd.top]
+	}
+
+	for _, sug := range suggestions {
+		log.WithFields(log.Fields{
+			"record":  sug.Record,
+			"expr":    sug.Expr,
+			"count":   sug.Count,
+			"series":  sug.Series,
+			"sources": len(sug.Sources),
+		}).Infof("suggested recording rule")
+	}
+	log.Infof("%d recording rules suggested", len(suggestions))
+
+	if err := cmd.writeRules(suggestions); err != nil {
+		return err
+	}
+
+	if cmd.rewriteDashboards {
+		return rewriteDashboards(suggester, suggestions, dashboardFiles)
+	}
+	return nil
+}
+
+// estimateSeries sets the number of series touched by each evaluation of the
+// suggested expressions.
+func (cmd *SuggestRecordingRulesCommand) estimateSeries(suggestions []*analyse.RecordingRuleSuggestion) error {
+	rt := api.DefaultRoundTripper
+	if cmd.username != "" {
+		rt = config.NewBasicAuthRoundTripper(cmd.username, config.Secret(cmd.password), "", "", api.DefaultRoundTripper)
+	}
+	promClient, err := api.NewClient(api.Config{
+		Address: cmd.address,
+		RoundTripper: &httpmiddleware.TenantIDRoundTripper{
+			TenantName: cmd.username,
+			Next:       rt,
+		},
+	})
+	if err != nil {
+		return err
+	}
+	v1api := v1.NewAPI(promClient)
+
+	series := map[string]int{}
+	for _, sug := range suggestions {
+		sug.Series = 0
+		for _, selector := range sug.Selectors {
+			count, ok := series[selector]
+			if !ok {
+				count, err = countSeries(v1api, selector, cmd.readTimeout)
+				if err != nil {
+					return err
+				}
+				series[selector] = count
+			}
+			sug.Series += count
+		}
+	}
+	return nil
+}
+
+func countSeries(v1api v1.API, selector string, timeout time.Duration) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	query := "count(" + selector + ")"
+	result, _, err := v1api.Query(ctx, query, time.Now())
+	if err != nil {
+		return 0, errors.Wrap(err, "error querying "+query)
+	}
+
+	vec := result.(model.Vector)
+	if len(vec) == 0 {
+		return 0, nil
+	}
+	return int(vec[0].Value), nil
+}
+
+func (cmd *SuggestRecordingRulesCommand) writeRules(suggestions []*analyse.RecordingRuleSuggestion) error {
+	group := rwrulefmt.RuleGroup{RuleGroup: rulefmt.RuleGroup{Name: cmd.groupName}}
+	for _, sug := range suggestions {
+		group.Rules = append(group.Rules, rulefmt.RuleNode{
+			Record: yamlv3.Node{Kind: yamlv3.ScalarNode, Value: sug.Record},
+			Expr:   yamlv3.Node{Kind: yamlv3.ScalarNode, Value: sug.Expr},
+		})
+	}
+
+	ns := rules.RuleNamespace{
+		Namespace: cmd.namespace,
+		Groups:    []rwrulefmt.RuleGroup{group},
+	}
+	if errs := ns.Validate(); len(errs) > 0 {
+		return errors.Wrap(errs[0], "invalid suggested recording rules")
+	}
+
+	out, err := yamlv3.Marshal(ns)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(cmd.outputFile, out, os.FileMode(int(0666)))
+}
+
+// rewriteDashboards replaces the suggested aggregations in the dashboard
+// queries by the series recorded by the suggested rules.
+func rewriteDashboards(suggester *analyse.RecordingRuleSuggester, suggestions []*analyse.RecordingRuleSuggestion, files []string) error {
+	for _, file := range files {
+		board, err := loadBoard(file)
+		if err != nil {
+			return err
+		}
+
+		queries := map[string]string{}
+		for _, panel := range analyse.PanelsInBoard(board) {
+			targets := panel.GetTargets()
+			if targets == nil {
+				continue
+			}
+			for _, target := range *targets {
+				if target.Expr == "" {
+					continue
+				}
+				query, n, err := suggester.RewriteQuery(target.Expr, suggestions)
+				if err != nil || n == 0 {
+					continue
+				}
+				queries[target.Expr] = query
+			}
+		}
+		if len(queries) == 0 {
+			continue
+		}
+
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		content, err = refactor.ReplaceDashboardQueries(content, queries)
+		if err != nil {
+			return errors.Wrap(err, file)
+		}
+		if err := os.WriteFile(file, content, 0644); err != nil {
+			return err
+		}
+		log.WithFields(log.Fields{"file": file, "queries": len(queries)}).Infof("rewrote dashboard")
+	}
+	return nil
+}
+
+func loadBoard(file string) (sdk.Board, error) {
+	var board sdk.Board
+	buf, err := loadFile(file)
+	if err != nil {
+		return board, err
+	}
+	if err := json.Unmarshal(buf, &board); err != nil {
+		return board, fmt.Errorf("%s for %s", err, file)
+	}
+	return board, nil
+}
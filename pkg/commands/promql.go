@@ -0,0 +1,111 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/prometheus/promql/parser"
+	"gopkg.in/alecthomas/kingpin.v2"
+
+	"github.com/cortexproject/cortex-tools/pkg/analyse"
+	"github.com/cortexproject/cortex-tools/pkg/client"
+)
+
+// PromQLCommand inspects PromQL expressions.
+type PromQLCommand struct {
+	ClientConfig client.Config
+	ReadTimeout  time.Duration
+
+	Query  string
+	Budget float64
+	Format string
+}
+
+// Register promql related commands and flags with the kingpin application
+func (p *PromQLCommand) Register(app *kingpin.Application) {
+	promqlCmd := app.Command("promql", "Inspect PromQL expressions.")
+
+	explainCmd := promqlCmd.Command("explain", "Print the syntax tree of an expression and its estimated cost.").Action(p.explain)
+	explainCmd.Arg("query", "The PromQL expression to explain.").Required().StringVar(&p.Query)
+	registerClientFlags(explainCmd, &p.ClientConfig, false)
+	explainCmd.Flag("authToken", "Authentication token for bearer token or JWT auth, alternatively set CORTEX_AUTH_TOKEN.").Default("").Envar("CORTEX_AUTH_TOKEN").StringVar(&p.ClientConfig.AuthToken)
+	explainCmd.Flag("user", "API user to use when contacting cortex, alternatively set CORTEX_API_USER. If empty, CORTEX_TENANT_ID will be used instead.").Default("").Envar("CORTEX_API_USER").StringVar(&p.ClientConfig.User)
+	explainCmd.Flag("key", "API key to use when contacting cortex, alternatively set CORTEX_API_KEY.").Default("").Envar("CORTEX_API_KEY").StringVar(&p.ClientConfig.Key)
+	explainCmd.Flag("read-timeout", "Timeout of the queries counting the series of each selector.").Default("30s").DurationVar(&p.ReadTimeout)
+	explainCmd.Flag("budget", "Fail if the estimated cost of the expression is greater than this budget. 0 disables the budget.").Default("0").Float64Var(&p.Budget)
+	explainCmd.Flag("format", "Output format: <json|text>").Default("text").EnumVar(&p.Format, "json", "text")
+}
+
+func (p *PromQLCommand) explain(_ *kingpin.ParseContext) error {
+	expr, err := parser.ParseExpr(p.Query)
+	if err != nil {
+		return errors.Wrap(err, "unable to parse expression")
+	}
+	cost := analyse.ExplainQuery(expr)
+
+	if p.ClientConfig.Address != "" {
+		cli, err := client.New(p.ClientConfig)
+		if err != nil {
+			return err
+		}
+		promAPI, err := cli.PrometheusAPI()
+		if err != nil {
+			return errors.Wrap(err, "unable to create query client")
+		}
+		if err := countSelectorSeries(promAPI, cost, p.ReadTimeout); err != nil {
+			return err
+		}
+	}
+
+	if err := printQueryCost(expr, cost, p.Format, os.Stdout); err != nil {
+		return err
+	}
+
+	if p.Budget > 0 && cost.Score > p.Budget {
+		return fmt.Errorf("estimated cost %.1f is over the budget of %.1f", cost.Score, p.Budget)
+	}
+	return nil
+}
+
+// countSelectorSeries sets the number of series of the selectors of a query.
+func countSelectorSeries(promAPI v1.API, cost *analyse.QueryCost, timeout time.Duration) error {
+	series := map[string]int{}
+	for _, selector := range cost.Selectors() {
+		count, err := countSeries(promAPI, selector, timeout)
+		if err != nil {
+			return err
+		}
+		series[selector] = count
+	}
+	cost.SetSeries(series)
+	return nil
+}
+
+func printQueryCost(expr parser.Expr, cost *analyse.QueryCost, format string, w io.Writer) error {
+	if format == "json" {
+		out, err := json.MarshalIndent(cost, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, string(out))
+		return err
+	}
+
+	fmt.Fprint(w, analyse.FormatAST(expr))
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "cost factors:")
+	for _, f := range cost.Factors {
+		fmt.Fprintf(w, "\t%s: %s, weight: %g", f.Kind, f.Expr, f.Weight)
+		if f.Series > 0 {
+			fmt.Fprintf(w, ", series: %d", f.Series)
+		}
+		fmt.Fprintf(w, ", cost: %g\n", f.Cost())
+	}
+	fmt.Fprintf(w, "estimated cost: %g\n", cost.Score)
+	return nil
+}
@@ -0,0 +1,717 @@
+package commands
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"maps"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/pkg/errors"
+	"github.com/prometheus/alertmanager/config"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/alecthomas/kingpin.v2"
+	yamlv3 "gopkg.in/yaml.v3"
+
+	"github.com/cortexproject/cortex-tools/pkg/client"
+	"github.com/cortexproject/cortex-tools/pkg/rules"
+	"github.com/cortexproject/cortex-tools/pkg/rules/rwrulefmt"
+	"github.com/cortexproject/cortex-tools/pkg/util"
+)
+
+const (
+	reconcileKindRules        = "rules"
+	reconcileKindAlertmanager = "alertmanager"
+
+	// reconcileDebounce is how long to wait for file changes to settle before
+	// reconciling, as editors and git checkouts touch files several times.
+	reconcileDebounce = time.Second
+)
+
+var (
+	reconcileTimestamp = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "cortex",
+		Name:      "last_reconcile_timestamp_seconds",
+		Help:      "The timestamp of the last reconciliation of each namespace.",
+	}, []string{"kind", "namespace"})
+	reconcileSuccessTimestamp = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "cortex",
+		Name:      "last_reconcile_success_timestamp_seconds",
+		Help:      "The timestamp of the last successful reconciliation of each namespace.",
+	}, []string{"kind", "namespace"})
+	reconcileDriftTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "cortex",
+		Name:      "reconcile_drift_detected_total",
+		Help:      "The number of times the config in cortex was found to differ from the local files, without the local files having changed.",
+	}, []string{"kind", "namespace"})
+	reconcileErrorsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "cortex",
+		Name:      "reconcile_errors_total",
+		Help:      "The number of failed reconciliations of each namespace.",
+	}, []string{"kind", "namespace"})
+	reconcileChangesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "cortex",
+		Name:      "reconcile_changes_total",
+		Help:      "The number of rule groups or configs created, updated or deleted in each namespace.",
+	}, []string{"kind", "namespace", "operation"})
+)
+
+// reconcileClient is the subset of the cortex client used to reconcile rules
+// and alertmanager configs.
+type reconcileClient interface {
+	ListRules(ctx context.Context, namespace string) (map[string][]rwrulefmt.RuleGroup, error)
+	CreateRuleGroup(ctx context.Context, namespace string, rg rwrulefmt.RuleGroup) error
+	DeleteRuleGroup(ctx context.Context, namespace, groupName string) error
+	GetAlertmanagerConfig(ctx context.Context) (string, map[string]string, error)
+	CreateAlertmanagerConfig(ctx context.Context, cfg string, templates map[string]string) error
+}
+
+// ReconcileCommand keeps the rules and alertmanager config of a tenant in sync
+// with local files, as a long-running process.
+type ReconcileCommand struct {
+	ClientConfig client.Config
+
+	WatchDirs              []string
+	AlertmanagerConfigFile string
+	TemplateFiles          []string
+	Namespaces             string
+	IgnoredNamespaces      string
+	OwnerID                string
+	Interval               time.Duration
+	MinBackoff             time.Duration
+	MaxBackoff             time.Duration
+	ListenAddress          string
+
+	reconciler *reconciler
+	ready      atomic.Bool
+}
+
+// Register reconcile related commands and flags with the kingpin application
+func (c *ReconcileCommand) Register(app *kingpin.Application) {
+	cmd := app.Command("reconcile", "Continuously sync rule files and an alertmanager config to cortex, watching them for changes and periodically correcting drift.").Action(c.run)
+	registerClientFlags(cmd, &c.ClientConfig, true)
+	cmd.Flag("authToken", "Authentication token for bearer token or JWT auth, alternatively set CORTEX_AUTH_TOKEN.").Default("").Envar("CORTEX_AUTH_TOKEN").StringVar(&c.ClientConfig.AuthToken)
+	cmd.Flag("user", "API user to use when contacting cortex, alternatively set CORTEX_API_USER. If empty, CORTEX_TENANT_ID will be used instead.").Default("").Envar("CORTEX_API_USER").StringVar(&c.ClientConfig.User)
+	cmd.Flag("key", "API key to use when contacting cortex, alternatively set CORTEX_API_KEY.").Default("").Envar("CORTEX_API_KEY").StringVar(&c.ClientConfig.Key)
+
+	cmd.Flag("watch", "Directory containing rules yaml files to sync. Each file in the directory with a .yml or .yaml suffix will be parsed. Flag can be reused to watch multiple directories.").ExistingDirsVar(&c.WatchDirs)
+	cmd.Flag("alertmanager-config", "Alertmanager config file to sync.").ExistingFileVar(&c.AlertmanagerConfigFile)
+	cmd.Flag("template-files", "Alertmanager template file to sync with the alertmanager config. Flag can be reused to load multiple files.").ExistingFilesVar(&c.TemplateFiles)
+	cmd.Flag("namespaces", "comma-separated list of namespaces to sync. Cannot be used together with --ignored-namespaces.").StringVar(&c.Namespaces)
+	cmd.Flag("ignored-namespaces", "comma-separated list of namespaces to ignore during a sync. Cannot be used together with --namespaces.").StringVar(&c.IgnoredNamespaces)
+	cmd.Flag("owner-id", "ID of this reconciler, recorded in a marker rule group of each synced namespace. Only namespaces owned by it, or without an owner, are changed, and only the ones it owns are deleted.").StringVar(&c.OwnerID)
+	cmd.Flag("interval", "Interval at which everything is synced, regardless of file changes, to correct drift.").Default("5m").DurationVar(&c.Interval)
+	cmd.Flag("min-backoff", "Initial delay before retrying a namespace that failed to sync.").Default("10s").DurationVar(&c.MinBackoff)
+	cmd.Flag("max-backoff", "Maximum delay before retrying a namespace that failed to sync.").Default("10m").DurationVar(&c.MaxBackoff)
+	cmd.Flag("listen-address", "Address to serve metrics, /healthz and /ready on.").Default(":8080").StringVar(&c.ListenAddress)
+}
+
+func (c *ReconcileCommand) run(_ *kingpin.ParseContext) error {
+	if len(c.WatchDirs) == 0 && c.AlertmanagerConfigFile == "" {
+		return errors.New("at least one of --watch or --alertmanager-config is required")
+	}
+	if c.Namespaces != "" && c.IgnoredNamespaces != "" {
+		return errors.New("--namespaces and --ignored-namespaces cannot be set at the same time")
+	}
+	if len(c.TemplateFiles) > 0 && c.AlertmanagerConfigFile == "" {
+		return errors.New("--template-files requires --alertmanager-config")
+	}
+
+	cli, err := client.New(c.ClientConfig)
+	if err != nil {
+		return err
+	}
+
+	c.reconciler = newReconciler(cli, c.MinBackoff, c.MaxBackoff)
+	c.reconciler.ruleDirs = c.WatchDirs
+	c.reconciler.alertmanagerConfigFile = c.AlertmanagerConfigFile
+	c.reconciler.templateFiles = c.TemplateFiles
+	c.reconciler.namespaces = namespaceSet(c.Namespaces)
+	c.reconciler.ignoredNamespaces = namespaceSet(c.IgnoredNamespaces)
+	c.reconciler.ownerID = c.OwnerID
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return errors.Wrap(err, "unable to create file watcher")
+	}
+	defer watcher.Close()
+
+	for _, dir := range c.watchedDirs() {
+		if err := watchRecursive(watcher, dir); err != nil {
+			return errors.Wrapf(err, "unable to watch %s", dir)
+		}
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	srv := c.server()
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.WithError(err).Fatalln("metrics listener failed")
+		}
+	}()
+	defer srv.Close()
+
+	c.reconcile(ctx)
+
+	ticker := time.NewTicker(c.Interval)
+	defer ticker.Stop()
+
+	debounce := time.NewTimer(reconcileDebounce)
+	debounce.Stop()
+	defer debounce.Stop()
+
+	retry := time.NewTimer(0)
+	c.scheduleRetry(retry)
+	defer retry.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Infoln("stopping reconciliation")
+			return nil
+
+		case ev, ok := <-watcher.Events:
+			if !ok {
+				return errors.New("file watcher stopped")
+			}
+			log.WithFields(log.Fields{
+				"file": ev.Name,
+				"op":   ev.Op.String(),
+			}).Debugln("file changed")
+
+			if ev.Has(fsnotify.Create) {
+				if info, err := os.Stat(ev.Name); err == nil && info.IsDir() {
+					if err := watchRecursive(watcher, ev.Name); err != nil {
+						log.WithError(err).WithField("dir", ev.Name).Errorln("unable to watch directory")
+					}
+				}
+			}
+			debounce.Reset(reconcileDebounce)
+
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return errors.New("file watcher stopped")
+			}
+			log.WithError(err).Errorln("file watcher error")
+
+		case <-debounce.C:
+			c.reconcile(ctx)
+			c.scheduleRetry(retry)
+
+		case <-ticker.C:
+			c.reconcile(ctx)
+			c.scheduleRetry(retry)
+
+		case <-retry.C:
+			c.reconcile(ctx)
+			c.scheduleRetry(retry)
+		}
+	}
+}
+
+func (c *ReconcileCommand) reconcile(ctx context.Context) {
+	if err := c.reconciler.reconcile(ctx, time.Now()); err != nil {
+		log.WithError(err).Errorln("reconciliation unsuccessful")
+		return
+	}
+	c.ready.Store(true)
+}
+
+// scheduleRetry sets the timer to fire when the next failing namespace can be
+// retried, if any.
+func (c *ReconcileCommand) scheduleRetry(t *time.Timer) {
+	if !t.Stop() {
+		select {
+		case <-t.C:
+		default:
+		}
+	}
+	if at, ok := c.reconciler.backoff.next(); ok {
+		t.Reset(time.Until(at))
+	}
+}
+
+// watchedDirs returns the directories to watch for changes. The parent
+// directories of files are watched, as editors often replace files rather
+// than writing to them.
+func (c *ReconcileCommand) watchedDirs() []string {
+	dirs := map[string]struct{}{}
+	for _, dir := range c.WatchDirs {
+		dirs[dir] = struct{}{}
+	}
+	for _, f := range append([]string{c.AlertmanagerConfigFile}, c.TemplateFiles...) {
+		if f != "" {
+			dirs[filepath.Dir(f)] = struct{}{}
+		}
+	}
+	return util.SortedKeys(dirs)
+}
+
+func (c *ReconcileCommand) server() *http.Server {
+	// Use a different registerer than default so we don't get all the Cortex metrics, but include Go runtime metrics.
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(
+		collectors.NewGoCollector(),
+		ruleLoadTimestamp,
+		ruleLoadSuccessTimestamp,
+		reconcileTimestamp,
+		reconcileSuccessTimestamp,
+		reconcileDriftTotal,
+		reconcileErrorsTotal,
+		reconcileChangesTotal,
+	)
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(reg, promhttp.HandlerOpts{}))
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	mux.HandleFunc("/ready", func(w http.ResponseWriter, _ *http.Request) {
+		if !c.ready.Load() {
+			http.Error(w, "initial reconciliation not complete", http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+
+	return &http.Server{
+		Addr:              c.ListenAddress,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+}
+
+func watchRecursive(watcher *fsnotify.Watcher, dir string) error {
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return nil
+		}
+		log.WithField("dir", path).Debugln("watching directory")
+		return watcher.Add(path)
+	})
+}
+
+func namespaceSet(list string) map[string]struct{} {
+	if list == "" {
+		return nil
+	}
+	set := map[string]struct{}{}
+	for _, ns := range strings.Split(list, ",") {
+		if ns != "" {
+			set[ns] = struct{}{}
+		}
+	}
+	return set
+}
+
+// reconciler syncs each namespace independently, so a namespace failing to
+// sync neither blocks nor delays the others.
+type reconciler struct {
+	cli reconcileClient
+
+	ruleDirs               []string
+	alertmanagerConfigFile string
+	templateFiles          []string
+	namespaces             map[string]struct{}
+	ignoredNamespaces      map[string]struct{}
+	ownerID                string
+
+	backoff *namespaceBackoff
+	// synced holds the fingerprint of the local content last synced for each
+	// namespace, to tell drift apart from local changes.
+	synced map[string]string
+}
+
+func newReconciler(cli reconcileClient, minBackoff, maxBackoff time.Duration) *reconciler {
+	return &reconciler{
+		cli:     cli,
+		backoff: newNamespaceBackoff(minBackoff, maxBackoff),
+		synced:  map[string]string{},
+	}
+}
+
+// reconcile syncs every namespace that is not backing off. An error is
+// returned if the local files or the current state cannot be read, in which
+// case nothing is synced.
+func (r *reconciler) reconcile(ctx context.Context, now time.Time) error {
+	var errs []string
+
+	if len(r.ruleDirs) > 0 {
+		ruleLoadTimestamp.SetToCurrentTime()
+		// Failures affecting every namespace are retried with a backoff too,
+		// rather than waiting for the next interval. A change to the local
+		// files is retried right away, as it may fix the failure.
+		files, fingerprint, err := r.ruleFiles()
+		switch {
+		case err != nil:
+			reconcileErrorsTotal.WithLabelValues(reconcileKindRules, "").Inc()
+			errs = append(errs, err.Error())
+		case !r.backoff.ready(reconcileKindRules, fingerprint, now):
+			log.Debugln("rules sync is backing off after a failure")
+		default:
+			if err := r.reconcileRules(ctx, files, now); err != nil {
+				r.backoff.failure(reconcileKindRules, fingerprint, now)
+				reconcileErrorsTotal.WithLabelValues(reconcileKindRules, "").Inc()
+				errs = append(errs, err.Error())
+			} else {
+				r.backoff.success(reconcileKindRules)
+			}
+		}
+	}
+
+	if r.alertmanagerConfigFile != "" {
+		if err := r.reconcileAlertmanager(ctx, now); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// ruleFiles returns the rule files of the watched directories, and a
+// fingerprint of their content.
+func (r *reconciler) ruleFiles() ([]string, string, error) {
+	var files []string
+	for _, dir := range r.ruleDirs {
+		found, err := findRuleFiles(dir)
+		if err != nil {
+			return nil, "", err
+		}
+		files = append(files, found...)
+	}
+
+	h := sha256.New()
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return nil, "", err
+		}
+		fmt.Fprintf(h, "\x00%s\x00%s", file, content)
+	}
+	return files, hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func (r *reconciler) reconcileRules(ctx context.Context, files []string, now time.Time) error {
+	// Deleting namespaces based on a partial view of the local files would
+	// be destructive, so a single invalid file stops the whole sync.
+	local, err := rules.ParseFiles(files)
+	if err != nil {
+		return errors.Wrap(err, "unable to parse rules files")
+	}
+
+	current, err := r.cli.ListRules(ctx, "")
+	if err != nil && !errors.Is(err, client.ErrResourceNotFound) {
+		return errors.Wrap(err, "unable to contact cortex api")
+	}
+
+	namespaces := map[string]struct{}{}
+	for ns := range local {
+		namespaces[ns] = struct{}{}
+	}
+	for ns := range current {
+		namespaces[ns] = struct{}{}
+	}
+
+	failed := 0
+	for _, ns := range util.SortedKeys(namespaces) {
+		if !r.shouldSync(ns) {
+			continue
+		}
+
+		localNs, exists := local[ns]
+		if err := rules.CheckOwnership(r.ownerID, current[ns], !exists); err != nil {
+			log.WithFields(log.Fields{
+				"namespace": ns,
+				"owner_id":  r.ownerID,
+			}).WithError(err).Warnln("skipping namespace not owned by this reconciler")
+			continue
+		}
+
+		fingerprint := ""
+		if exists {
+			if r.ownerID != "" {
+				localNs.SetOwner(r.ownerID)
+			}
+			fingerprint, err = rulesFingerprint(localNs.Groups)
+			if err != nil {
+				return err
+			}
+		}
+
+		key := reconcileKindRules + "/" + ns
+		if !r.backoff.ready(key, fingerprint, now) {
+			continue
+		}
+
+		change := rules.CompareNamespaces(rules.RuleNamespace{Namespace: ns, Groups: current[ns]}, localNs)
+		change.Namespace = ns
+
+		if err := r.syncNamespace(ctx, key, fingerprint, change, now); err != nil {
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		log.WithField("namespaces", failed).Warnln("some namespaces failed to sync and will be retried")
+	} else {
+		ruleLoadSuccessTimestamp.SetToCurrentTime()
+	}
+	return nil
+}
+
+func (r *reconciler) syncNamespace(ctx context.Context, key, fingerprint string, change rules.NamespaceChange, now time.Time) error {
+	ns := change.Namespace
+	reconcileTimestamp.WithLabelValues(reconcileKindRules, ns).SetToCurrentTime()
+
+	created, updated, deleted := rules.SummarizeChanges([]rules.NamespaceChange{change})
+	if created+updated+deleted > 0 {
+		if synced, ok := r.synced[key]; ok && synced == fingerprint {
+			log.WithField("namespace", ns).Warnln("drift detected, rules in cortex differ from the local files")
+			reconcileDriftTotal.WithLabelValues(reconcileKindRules, ns).Inc()
+		}
+	}
+
+	err := r.applyChange(ctx, change)
+	if err != nil {
+		delay := r.backoff.failure(key, fingerprint, now)
+		log.WithError(err).WithFields(log.Fields{
+			"namespace": ns,
+			"retry_in":  delay,
+		}).Errorln("unable to sync namespace")
+		reconcileErrorsTotal.WithLabelValues(reconcileKindRules, ns).Inc()
+		return err
+	}
+
+	r.backoff.success(key)
+	if fingerprint == "" {
+		delete(r.synced, key)
+	} else {
+		r.synced[key] = fingerprint
+	}
+	reconcileSuccessTimestamp.WithLabelValues(reconcileKindRules, ns).SetToCurrentTime()
+	return nil
+}
+
+// applyChange executes the changes to a single namespace. Unlike sync, the
+// changes are counted as they are made, so that partially applied changes
+// are reported.
+func (r *reconciler) applyChange(ctx context.Context, change rules.NamespaceChange) error {
+	ns := change.Namespace
+	for _, g := range change.GroupsCreated {
+		log.WithFields(log.Fields{
+			"group":     g.Name,
+			"namespace": ns,
+		}).Infof("creating group")
+		if err := r.cli.CreateRuleGroup(ctx, ns, g); err != nil {
+			return err
+		}
+		reconcileChangesTotal.WithLabelValues(reconcileKindRules, ns, "created").Inc()
+	}
+
+	for _, g := range change.GroupsUpdated {
+		log.WithFields(log.Fields{
+			"group":     g.New.Name,
+			"namespace": ns,
+		}).Infof("updating group")
+		if err := r.cli.CreateRuleGroup(ctx, ns, g.New); err != nil {
+			return err
+		}
+		reconcileChangesTotal.WithLabelValues(reconcileKindRules, ns, "updated").Inc()
+	}
+
+	for _, g := range change.GroupsDeleted {
+		log.WithFields(log.Fields{
+			"group":     g.Name,
+			"namespace": ns,
+		}).Infof("deleting group")
+		if err := r.cli.DeleteRuleGroup(ctx, ns, g.Name); err != nil && !errors.Is(err, client.ErrResourceNotFound) {
+			return err
+		}
+		reconcileChangesTotal.WithLabelValues(reconcileKindRules, ns, "deleted").Inc()
+	}
+
+	return nil
+}
+
+func (r *reconciler) reconcileAlertmanager(ctx context.Context, now time.Time) error {
+	const key = reconcileKindAlertmanager
+
+	content, err := os.ReadFile(r.alertmanagerConfigFile)
+	if err != nil {
+		reconcileErrorsTotal.WithLabelValues(reconcileKindAlertmanager, "").Inc()
+		return errors.Wrap(err, "unable to load config file: "+r.alertmanagerConfigFile)
+	}
+	cfg := string(content)
+
+	templates, err := createTemplates(r.templateFiles)
+	if err != nil {
+		reconcileErrorsTotal.WithLabelValues(reconcileKindAlertmanager, "").Inc()
+		return err
+	}
+
+	fingerprint := alertmanagerFingerprint(cfg, templates)
+	if !r.backoff.ready(key, fingerprint, now) {
+		return nil
+	}
+	reconcileTimestamp.WithLabelValues(reconcileKindAlertmanager, "").SetToCurrentTime()
+
+	err = r.syncAlertmanager(ctx, key, fingerprint, cfg, templates)
+	if err != nil {
+		delay := r.backoff.failure(key, fingerprint, now)
+		log.WithError(err).WithField("retry_in", delay).Errorln("unable to sync alertmanager config")
+		reconcileErrorsTotal.WithLabelValues(reconcileKindAlertmanager, "").Inc()
+		return nil
+	}
+
+	r.backoff.success(key)
+	r.synced[key] = fingerprint
+	reconcileSuccessTimestamp.WithLabelValues(reconcileKindAlertmanager, "").SetToCurrentTime()
+	return nil
+}
+
+func (r *reconciler) syncAlertmanager(ctx context.Context, key, fingerprint, cfg string, templates map[string]string) error {
+	if _, err := config.Load(cfg); err != nil {
+		return err
+	}
+
+	currentCfg, currentTemplates, err := r.cli.GetAlertmanagerConfig(ctx)
+	if err != nil && !errors.Is(err, client.ErrResourceNotFound) {
+		return err
+	}
+	if err == nil && currentCfg == cfg && maps.Equal(currentTemplates, templates) {
+		return nil
+	}
+
+	if synced, ok := r.synced[key]; ok && synced == fingerprint {
+		log.Warnln("drift detected, alertmanager config in cortex differs from the local files")
+		reconcileDriftTotal.WithLabelValues(reconcileKindAlertmanager, "").Inc()
+	}
+
+	operation := "updated"
+	if errors.Is(err, client.ErrResourceNotFound) {
+		operation = "created"
+	}
+
+	log.Infof("loading alertmanager config")
+	if err := r.cli.CreateAlertmanagerConfig(ctx, cfg, templates); err != nil {
+		return err
+	}
+	reconcileChangesTotal.WithLabelValues(reconcileKindAlertmanager, "", operation).Inc()
+	return nil
+}
+
+func (r *reconciler) shouldSync(namespace string) bool {
+	if r.namespaces != nil {
+		_, ok := r.namespaces[namespace]
+		return ok
+	}
+	_, ignored := r.ignoredNamespaces[namespace]
+	return !ignored
+}
+
+func rulesFingerprint(groups []rwrulefmt.RuleGroup) (string, error) {
+	out, err := yamlv3.Marshal(groups)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(out)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func alertmanagerFingerprint(cfg string, templates map[string]string) string {
+	h := sha256.New()
+	h.Write([]byte(cfg))
+	for _, name := range util.SortedKeys(templates) {
+		fmt.Fprintf(h, "\x00%s\x00%s", name, templates[name])
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// namespaceBackoff tracks namespaces failing to sync, delaying their retries
+// exponentially. A namespace is retried right away if its local content
+// changes, as the change may fix the failure.
+type namespaceBackoff struct {
+	min, max time.Duration
+	failing  map[string]*backoffState
+}
+
+type backoffState struct {
+	failures    int
+	retryAt     time.Time
+	fingerprint string
+}
+
+func newNamespaceBackoff(minBackoff, maxBackoff time.Duration) *namespaceBackoff {
+	return &namespaceBackoff{
+		min:     minBackoff,
+		max:     maxBackoff,
+		failing: map[string]*backoffState{},
+	}
+}
+
+// ready returns whether a namespace can be synced.
+func (b *namespaceBackoff) ready(key, fingerprint string, now time.Time) bool {
+	s, ok := b.failing[key]
+	if !ok || s.fingerprint != fingerprint {
+		return true
+	}
+	return !now.Before(s.retryAt)
+}
+
+// failure records a failed sync and returns the delay before the next retry.
+func (b *namespaceBackoff) failure(key, fingerprint string, now time.Time) time.Duration {
+	s, ok := b.failing[key]
+	if !ok || s.fingerprint != fingerprint {
+		s = &backoffState{fingerprint: fingerprint}
+		b.failing[key] = s
+	}
+	s.failures++
+
+	delay := b.min
+	for i := 1; i < s.failures && delay < b.max; i++ {
+		delay *= 2
+	}
+	if delay > b.max {
+		delay = b.max
+	}
+	s.retryAt = now.Add(delay)
+	return delay
+}
+
+func (b *namespaceBackoff) success(key string) {
+	delete(b.failing, key)
+}
+
+// next returns the earliest time a failing namespace can be retried.
+func (b *namespaceBackoff) next() (time.Time, bool) {
+	var (
+		at    time.Time
+		found bool
+	)
+	for _, s := range b.failing {
+		if !found || s.retryAt.Before(at) {
+			at, found = s.retryAt, true
+		}
+	}
+	return at, found
+}
@@ -0,0 +1,274 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cortexproject/cortex-tools/pkg/client"
+	"github.com/cortexproject/cortex-tools/pkg/rules"
+	"github.com/cortexproject/cortex-tools/pkg/rules/rwrulefmt"
+)
+
+type fakeReconcileClient struct {
+	rules     map[string][]rwrulefmt.RuleGroup
+	failing   map[string]bool
+	cfg       string
+	templates map[string]string
+	writes    int
+	listErr   error
+	lists     int
+}
+
+func (f *fakeReconcileClient) ListRules(_ context.Context, _ string) (map[string][]rwrulefmt.RuleGroup, error) {
+	f.lists++
+	if f.listErr != nil {
+		return nil, f.listErr
+	}
+	out := map[string][]rwrulefmt.RuleGroup{}
+	for ns, groups := range f.rules {
+		out[ns] = append([]rwrulefmt.RuleGroup(nil), groups...)
+	}
+	return out, nil
+}
+
+func (f *fakeReconcileClient) CreateRuleGroup(_ context.Context, namespace string, rg rwrulefmt.RuleGroup) error {
+	if f.failing[namespace] {
+		return errors.New("ruler unavailable")
+	}
+	f.writes++
+	groups := f.rules[namespace]
+	for i, g := range groups {
+		if g.Name == rg.Name {
+			groups[i] = rg
+			return nil
+		}
+	}
+	f.rules[namespace] = append(groups, rg)
+	return nil
+}
+
+func (f *fakeReconcileClient) DeleteRuleGroup(_ context.Context, namespace, groupName string) error {
+	if f.failing[namespace] {
+		return errors.New("ruler unavailable")
+	}
+	f.writes++
+	var groups []rwrulefmt.RuleGroup
+	for _, g := range f.rules[namespace] {
+		if g.Name != groupName {
+			groups = append(groups, g)
+		}
+	}
+	if len(groups) == 0 {
+		delete(f.rules, namespace)
+		return nil
+	}
+	f.rules[namespace] = groups
+	return nil
+}
+
+func (f *fakeReconcileClient) GetAlertmanagerConfig(_ context.Context) (string, map[string]string, error) {
+	if f.cfg == "" {
+		return "", nil, client.ErrResourceNotFound
+	}
+	return f.cfg, f.templates, nil
+}
+
+func (f *fakeReconcileClient) CreateAlertmanagerConfig(_ context.Context, cfg string, templates map[string]string) error {
+	f.writes++
+	f.cfg, f.templates = cfg, templates
+	return nil
+}
+
+func writeRuleFile(t *testing.T, dir, namespace, record string) {
+	t.Helper()
+	content := "namespace: " + namespace + "\ngroups:\n- name: group\n  rules:\n  - record: " + record + "\n    expr: up\n"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, namespace+".yaml"), []byte(content), 0644))
+}
+
+// counterValue returns the value of a reconcile counter. The counters are
+// shared by every test in the package, so tests assert their increase.
+func counterValue(c *prometheus.CounterVec, lvs ...string) float64 {
+	return testutil.ToFloat64(c.WithLabelValues(lvs...))
+}
+
+func TestReconcileRules(t *testing.T) {
+	dir := t.TempDir()
+	writeRuleFile(t, dir, "reconcile-a", "job:up:a")
+	writeRuleFile(t, dir, "reconcile-b", "job:up:b")
+
+	cli := &fakeReconcileClient{
+		rules: map[string][]rwrulefmt.RuleGroup{
+			"reconcile-stale": {{}},
+		},
+		failing: map[string]bool{"reconcile-b": true},
+	}
+	r := newReconciler(cli, 10*time.Second, time.Minute)
+	r.ruleDirs = []string{dir}
+
+	errorsB := counterValue(reconcileErrorsTotal, reconcileKindRules, "reconcile-b")
+	createdA := counterValue(reconcileChangesTotal, reconcileKindRules, "reconcile-a", "created")
+	deletedStale := counterValue(reconcileChangesTotal, reconcileKindRules, "reconcile-stale", "deleted")
+	driftA := counterValue(reconcileDriftTotal, reconcileKindRules, "reconcile-a")
+
+	now := time.Now()
+	require.NoError(t, r.reconcile(context.Background(), now))
+
+	// The failing namespace does not prevent the others from being synced.
+	assert.Contains(t, cli.rules, "reconcile-a")
+	assert.NotContains(t, cli.rules, "reconcile-b")
+	assert.NotContains(t, cli.rules, "reconcile-stale")
+	assert.Equal(t, errorsB+1, counterValue(reconcileErrorsTotal, reconcileKindRules, "reconcile-b"))
+	assert.Equal(t, createdA+1, counterValue(reconcileChangesTotal, reconcileKindRules, "reconcile-a", "created"))
+	assert.Equal(t, deletedStale+1, counterValue(reconcileChangesTotal, reconcileKindRules, "reconcile-stale", "deleted"))
+
+	// The failing namespace is not retried before its backoff expires.
+	writes := cli.writes
+	require.NoError(t, r.reconcile(context.Background(), now.Add(5*time.Second)))
+	assert.Equal(t, writes, cli.writes)
+	assert.Equal(t, errorsB+1, counterValue(reconcileErrorsTotal, reconcileKindRules, "reconcile-b"))
+
+	cli.failing = nil
+	require.NoError(t, r.reconcile(context.Background(), now.Add(10*time.Second)))
+	assert.Contains(t, cli.rules, "reconcile-b")
+
+	// Changes made in cortex are reverted and reported as drift.
+	delete(cli.rules, "reconcile-a")
+	require.NoError(t, r.reconcile(context.Background(), now.Add(20*time.Second)))
+	assert.Contains(t, cli.rules, "reconcile-a")
+	assert.Equal(t, driftA+1, counterValue(reconcileDriftTotal, reconcileKindRules, "reconcile-a"))
+
+	// Local changes are not drift.
+	writeRuleFile(t, dir, "reconcile-a", "job:up:changed")
+	require.NoError(t, r.reconcile(context.Background(), now.Add(30*time.Second)))
+	assert.Equal(t, "job:up:changed", cli.rules["reconcile-a"][0].Rules[0].Record.Value)
+	assert.Equal(t, driftA+1, counterValue(reconcileDriftTotal, reconcileKindRules, "reconcile-a"))
+}
+
+func TestReconcileRulesInvalidFile(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "invalid.yaml"), []byte("groups: [{"), 0644))
+
+	cli := &fakeReconcileClient{
+		rules: map[string][]rwrulefmt.RuleGroup{"reconcile-existing": {{}}},
+	}
+	r := newReconciler(cli, time.Second, time.Minute)
+	r.ruleDirs = []string{dir}
+
+	require.Error(t, r.reconcile(context.Background(), time.Now()))
+	assert.Contains(t, cli.rules, "reconcile-existing")
+	assert.Zero(t, cli.writes)
+}
+
+func TestReconcileRulesBackoff(t *testing.T) {
+	dir := t.TempDir()
+	writeRuleFile(t, dir, "reconcile-a", "job:up:a")
+
+	cli := &fakeReconcileClient{
+		rules:   map[string][]rwrulefmt.RuleGroup{},
+		listErr: errors.New("cortex unavailable"),
+	}
+	r := newReconciler(cli, time.Minute, time.Hour)
+	r.ruleDirs = []string{dir}
+	now := time.Now()
+
+	require.Error(t, r.reconcile(context.Background(), now))
+	assert.Equal(t, 1, cli.lists)
+
+	// The rules are not fetched again until the backoff expires.
+	require.NoError(t, r.reconcile(context.Background(), now.Add(30*time.Second)))
+	assert.Equal(t, 1, cli.lists)
+
+	// A change to the local files is retried right away.
+	writeRuleFile(t, dir, "reconcile-a", "job:up:changed")
+	require.Error(t, r.reconcile(context.Background(), now.Add(40*time.Second)))
+	assert.Equal(t, 2, cli.lists)
+
+	// A missing rule set is not a failure, even when wrapped.
+	cli.listErr = fmt.Errorf("listing rules: %w", client.ErrResourceNotFound)
+	require.NoError(t, r.reconcile(context.Background(), now.Add(3*time.Minute)))
+	assert.Equal(t, 3, cli.lists)
+	assert.Contains(t, cli.rules, "reconcile-a")
+}
+
+func TestReconcileAlertmanager(t *testing.T) {
+	dir := t.TempDir()
+	cfgFile := filepath.Join(dir, "alertmanager.yaml")
+	cfg := "route:\n  receiver: default\nreceivers:\n- name: default\n"
+	require.NoError(t, os.WriteFile(cfgFile, []byte(cfg), 0644))
+
+	cli := &fakeReconcileClient{}
+	r := newReconciler(cli, time.Second, time.Minute)
+	r.alertmanagerConfigFile = cfgFile
+
+	require.NoError(t, r.reconcile(context.Background(), time.Now()))
+	assert.Equal(t, cfg, cli.cfg)
+	assert.Equal(t, 1, cli.writes)
+
+	// An unchanged config is not uploaded again.
+	require.NoError(t, r.reconcile(context.Background(), time.Now()))
+	assert.Equal(t, 1, cli.writes)
+}
+
+func TestNamespaceBackoff(t *testing.T) {
+	b := newNamespaceBackoff(time.Second, 5*time.Second)
+	now := time.Now()
+
+	assert.True(t, b.ready("ns", "v1", now))
+	_, ok := b.next()
+	assert.False(t, ok)
+
+	for _, expected := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second} {
+		assert.Equal(t, expected, b.failure("ns", "v1", now))
+	}
+	assert.False(t, b.ready("ns", "v1", now.Add(4*time.Second)))
+	assert.True(t, b.ready("ns", "v1", now.Add(5*time.Second)))
+
+	next, ok := b.next()
+	assert.True(t, ok)
+	assert.Equal(t, now.Add(5*time.Second), next)
+
+	// A change to the local content is retried right away, with a fresh backoff.
+	assert.True(t, b.ready("ns", "v2", now))
+	assert.Equal(t, time.Second, b.failure("ns", "v2", now))
+
+	b.success("ns")
+	assert.True(t, b.ready("ns", "v2", now))
+}
+
+func TestReconcileRulesOwnership(t *testing.T) {
+	dirA, dirB := t.TempDir(), t.TempDir()
+	writeRuleFile(t, dirA, "owned-a", "job:up:a")
+	writeRuleFile(t, dirB, "owned-b", "job:up:b")
+
+	cli := &fakeReconcileClient{
+		rules: map[string][]rwrulefmt.RuleGroup{"unowned": {{}}},
+	}
+	a := newReconciler(cli, time.Second, time.Minute)
+	a.ruleDirs, a.ownerID = []string{dirA}, "a"
+	b := newReconciler(cli, time.Second, time.Minute)
+	b.ruleDirs, b.ownerID = []string{dirB}, "b"
+
+	require.NoError(t, a.reconcile(context.Background(), time.Now()))
+	require.NoError(t, b.reconcile(context.Background(), time.Now()))
+	require.NoError(t, a.reconcile(context.Background(), time.Now()))
+
+	// Each reconciler only deletes the namespaces it owns.
+	assert.Equal(t, "a", rules.NamespaceOwner(cli.rules["owned-a"]))
+	assert.Equal(t, "b", rules.NamespaceOwner(cli.rules["owned-b"]))
+	assert.Contains(t, cli.rules, "unowned")
+
+	require.NoError(t, os.Remove(filepath.Join(dirA, "owned-a.yaml")))
+	require.NoError(t, a.reconcile(context.Background(), time.Now()))
+	assert.NotContains(t, cli.rules, "owned-a")
+	assert.Contains(t, cli.rules, "owned-b")
+}
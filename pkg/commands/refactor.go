@@ -0,0 +1,241 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/pmezard/go-difflib/difflib"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/alecthomas/kingpin.v2"
+	yamlv3 "gopkg.in/yaml.v3"
+
+	"github.com/cortexproject/cortex-tools/pkg/refactor"
+	"github.com/cortexproject/cortex-tools/pkg/rules"
+	"github.com/cortexproject/cortex-tools/pkg/util"
+)
+
+// RefactorCommand rewrites metrics used in rule files and Grafana dashboards.
+type RefactorCommand struct {
+	From        string
+	To          string
+	Regex       bool
+	MappingFile string
+
+	RuleFiles      string
+	RuleFilesPath  string
+	DashboardFiles string
+	DashboardPath  string
+
+	Fallback bool
+	DryRun   bool
+}
+
+// Register refactor related commands and flags with the kingpin application
+func (r *RefactorCommand) Register(app *kingpin.Application) {
+	refactorCmd := app.Command("refactor", "Refactor the metrics used in rule files and Grafana dashboards.")
+
+	renameCmd := refactorCmd.Command("rename-metric", "Rename metrics in the expressions of rule files and the queries of Grafana dashboards.").Action(r.renameMetric)
+	renameCmd.Flag("from", "Name of the metric to rename.").StringVar(&r.From)
+	renameCmd.Flag("to", "New name of the metric.").StringVar(&r.To)
+	renameCmd.Flag("regex", "Rename the metrics fully matching the --from regular expression. --to can reference its capture groups, as in $1.").BoolVar(&r.Regex)
+	renameCmd.Flag("mapping-file", "YAML file mapping the metrics to rename to their new names. Cannot be used together with --from and --to.").ExistingFileVar(&r.MappingFile)
+	renameCmd.Flag("rule-files", "Comma separated list of rule files to refactor.").StringVar(&r.RuleFiles)
+	renameCmd.Flag(
+		"rule-dirs",
+		"Comma separated list of paths to directories containing rules yaml files. Each file in a directory with a .yml or .yaml suffix will be refactored.",
+	).StringVar(&r.RuleFilesPath)
+	renameCmd.Flag("dashboard-files", "Comma separated list of Grafana dashboard files to refactor.").StringVar(&r.DashboardFiles)
+	renameCmd.Flag(
+		"dashboard-dirs",
+		"Comma separated list of paths to directories containing Grafana dashboards. Each file in a directory with a .json suffix will be refactored.",
+	).StringVar(&r.DashboardPath)
+	renameCmd.Flag("fallback", "Combine the renamed expressions with the original ones using `or`, so they keep returning results while both metric names are in use.").BoolVar(&r.Fallback)
+	renameCmd.Flag("dry-run", "Print a diff of the changes instead of writing the files.").BoolVar(&r.DryRun)
+}
+
+func (r *RefactorCommand) renamer() (*refactor.Renamer, error) {
+	if r.MappingFile != "" {
+		if r.From != "" || r.To != "" || r.Regex {
+			return nil, errors.New("--mapping-file cannot be used together with --from, --to and --regex")
+		}
+		return refactor.LoadMappingFile(r.MappingFile)
+	}
+
+	if r.From == "" || r.To == "" {
+		return nil, errors.New("either --from and --to, or --mapping-file, must be set")
+	}
+	if r.Regex {
+		return refactor.NewRegexRenamer(r.From, r.To)
+	}
+	return refactor.NewRenamer(r.From, r.To), nil
+}
+
+func (r *RefactorCommand) renameMetric(_ *kingpin.ParseContext) error {
+	renamer, err := r.renamer()
+	if err != nil {
+		return err
+	}
+
+	ruleFiles, err := listFiles(r.RuleFiles, r.RuleFilesPath, findRuleFiles)
+	if err != nil {
+		return err
+	}
+	dashboardFiles, err := listFiles(r.DashboardFiles, r.DashboardPath, findDashboardFiles)
+	if err != nil {
+		return err
+	}
+	if len(ruleFiles) == 0 && len(dashboardFiles) == 0 {
+		return errors.New("no rule files or dashboards to refactor")
+	}
+
+	if len(ruleFiles) > 0 {
+		if err := r.renameInRules(renamer, ruleFiles); err != nil {
+			return err
+		}
+	}
+	return r.renameInDashboards(renamer, dashboardFiles)
+}
+
+func (r *RefactorCommand) renameInRules(renamer *refactor.Renamer, files []string) error {
+	namespaces, err := rules.ParseFiles(files)
+	if err != nil {
+		return err
+	}
+
+	var count, mod int
+	changed := map[string]rules.RuleNamespace{}
+	for _, name := range util.SortedKeys(namespaces) {
+		ns := namespaces[name]
+		n, err := renamer.RenameInRules(&ns, r.Fallback)
+		if err != nil {
+			return errors.Wrap(err, ns.Filepath)
+		}
+		if n == 0 {
+			continue
+		}
+		count++
+		mod += n
+		changed[name] = ns
+
+		// The diff is against the file as written, as saving the namespace
+		// also reformats it.
+		if r.DryRun {
+			before, err := os.ReadFile(ns.Filepath)
+			if err != nil {
+				return err
+			}
+			after, err := yamlv3.Marshal(ns)
+			if err != nil {
+				return err
+			}
+			if err := printFileDiff(ns.Filepath, before, after); err != nil {
+				return err
+			}
+		}
+	}
+
+	log.WithFields(log.Fields{
+		"files":    count,
+		"modified": mod,
+	}).Infof("renamed metrics in %s", "rules")
+
+	if r.DryRun {
+		return nil
+	}
+	return save(changed, true)
+}
+
+func (r *RefactorCommand) renameInDashboards(renamer *refactor.Renamer, files []string) error {
+	var count, mod int
+	for _, file := range files {
+		before, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+
+		after, n, err := renamer.RenameInDashboard(before, r.Fallback)
+		if err != nil {
+			return errors.Wrap(err, file)
+		}
+		if n == 0 {
+			continue
+		}
+		count++
+		mod += n
+
+		if r.DryRun {
+			if err := printFileDiff(file, before, after); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := os.WriteFile(file, after, 0644); err != nil {
+			return err
+		}
+	}
+
+	log.WithFields(log.Fields{
+		"files":    count,
+		"modified": mod,
+	}).Infof("renamed metrics in %s", "dashboards")
+	return nil
+}
+
+// listFiles returns the comma separated files, and the files found in the
+// comma separated directories.
+func listFiles(files, dirs string, find func(string) ([]string, error)) ([]string, error) {
+	var list []string
+	for _, file := range strings.Split(files, ",") {
+		if file != "" {
+			list = append(list, file)
+		}
+	}
+
+	for _, dir := range strings.Split(dirs, ",") {
+		if dir != "" {
+			found, err := find(dir)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, found...)
+		}
+	}
+	return list, nil
+}
+
+// findDashboardFiles returns the files in a directory, and its
+// subdirectories, with a .json suffix.
+func findDashboardFiles(dir string) ([]string, error) {
+	var files []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() && strings.HasSuffix(info.Name(), ".json") {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error walking the path %q: %v", dir, err)
+	}
+	return files, nil
+}
+
+func printFileDiff(file string, before, after []byte) error {
+	diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(string(before)),
+		B:        difflib.SplitLines(string(after)),
+		FromFile: file,
+		ToFile:   file,
+		Context:  3,
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Print(diff)
+	return nil
+}
@@ -1,12 +1,16 @@
 package commands
 
 import (
+	"bytes"
 	"context"
 	"fmt"
+	"maps"
 	"os"
 	"path/filepath"
 	"reflect"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
@@ -60,18 +64,36 @@ type RuleCommand struct {
 	namespacesMap        map[string]struct{}
 	IgnoredNamespaces    string
 	ignoredNamespacesMap map[string]struct{}
+	OwnerID              string
 
 	// Prepare Rules Config
 	InPlaceEdit                            bool
 	AggregationLabel                       string
 	AggregationLabelExcludedRuleGroups     string
 	aggregationLabelExcludedRuleGroupsList map[string]struct{}
+	AggregationLabelRuleGroups             string
+	aggregationLabelRuleGroupsList         map[string]struct{}
+	InjectLabelValue                       string
 
 	// Lint Rules Config
-	LintDryRun bool
+	LintDryRun      bool
+	LintPretty      bool
+	LintPrettyWidth int
+	LintCheck       bool
 
 	// Rules check flags
-	Strict bool
+	Strict              bool
+	CheckAgainstCluster bool
+	CheckLookback       time.Duration
+	MetadataFromCluster bool
+	MetadataFile        string
+	QueryCostBudget     float64
+
+	// Policy file evaluated by the check and sync commands
+	PolicyFile string
+
+	// Tenant limits checked by the sync command
+	LimitsFile string
 
 	// List Rules Config
 	Format string
@@ -80,6 +102,32 @@ type RuleCommand struct {
 
 	// Diff Rules Config
 	Verbose bool
+
+	// Backtest Rules Config
+	BacktestLookback time.Duration
+	BacktestStep     time.Duration
+	BacktestCompare  bool
+
+	// Backfill Rules Config
+	BackfillFrom      string
+	BackfillTo        string
+	BackfillStep      time.Duration
+	BackfillOutputDir string
+	BackfillTimeout   time.Duration
+
+	// Render Rules Config
+	RenderSamples        []string
+	RenderFromCluster    bool
+	RenderMaxSeries      int
+	RenderExternalURL    string
+	RenderExternalLabels map[string]string
+
+	// Reorganize Rules Config
+	ReorganizeMaxGroupSize   int
+	ReorganizeMinGroupSize   int
+	ReorganizeNamespaceLabel string
+	ReorganizeMappingFile    string
+	ReorganizeOutputDir      string
 }
 
 // Register rule related commands and flags with the kingpin application
@@ -123,46 +171,28 @@ func (r *RuleCommand) Register(app *kingpin.Application) {
 	checkCmd := rulesCmd.
 		Command("check", "runs various best practice checks against rules.").
 		Action(r.checkRecordingRuleNames)
+	backtestCmd := rulesCmd.
+		Command("backtest", "evaluates alerting rules over historical data and reports how often they would have fired.").
+		Action(r.backtestRules)
+	backfillCmd := rulesCmd.
+		Command("backfill", "evaluates recording rules over historical data and writes the results as TSDB blocks.").
+		Action(r.backfillRules)
+	renderCmd := rulesCmd.
+		Command("render", "expands the labels and annotations templates of alerting rules, and reports template errors.").
+		Action(r.renderRules)
+	reorganizeCmd := rulesCmd.
+		Command("reorganize", "splits, merges and moves rule groups between namespaces, and writes the result as new rule files.").
+		Action(r.reorganizeRules)
 
 	// Require Cortex cluster address and tentant ID on all these commands
-	for _, c := range []*kingpin.CmdClause{listCmd, printRulesCmd, getRuleGroupCmd, deleteRuleGroupCmd, deleteRuleNamespaceCmd, loadRulesCmd, diffRulesCmd, syncRulesCmd} {
-		c.Flag("address", "Address of the cortex cluster, alternatively set CORTEX_ADDRESS.").
-			Envar("CORTEX_ADDRESS").
-			Required().
-			StringVar(&r.ClientConfig.Address)
-
-		c.Flag("id", "Cortex tenant id, alternatively set CORTEX_TENANT_ID.").
-			Envar("CORTEX_TENANT_ID").
-			Required().
-			StringVar(&r.ClientConfig.ID)
-
-		c.Flag("use-legacy-routes", "If set, API requests to cortex will use the legacy /api/prom/ routes, alternatively set CORTEX_USE_LEGACY_ROUTES.").
-			Default("false").
-			Envar("CORTEX_USE_LEGACY_ROUTES").
-			BoolVar(&r.ClientConfig.UseLegacyRoutes)
-
-		c.Flag("ruler-api-path", "if set, API requests to cortex will use an alternative path for the ruler API, alternatively set CORTEX_RULER_API_PATH. The default is /api/v1/rules").
-			Default("").
-			Envar("CORTEX_RULER_API_PATH").
-			StringVar(&r.ClientConfig.RulerAPIPath)
-
-		c.Flag("tls-ca-path", "TLS CA certificate to verify cortex API as part of mTLS, alternatively set CORTEX_TLS_CA_PATH.").
-			Default("").
-			Envar("CORTEX_TLS_CA_CERT").
-			StringVar(&r.ClientConfig.TLS.CAPath)
-
-		c.Flag("tls-cert-path", "TLS client certificate to authenticate with cortex API as part of mTLS, alternatively set CORTEX_TLS_CERT_PATH.").
-			Default("").
-			Envar("CORTEX_TLS_CLIENT_CERT").
-			StringVar(&r.ClientConfig.TLS.CertPath)
-
-		c.Flag("tls-key-path", "TLS client certificate private key to authenticate with cortex API as part of mTLS, alternatively set CORTEX_TLS_KEY_PATH.").
-			Default("").
-			Envar("CORTEX_TLS_CLIENT_KEY").
-			StringVar(&r.ClientConfig.TLS.KeyPath)
-
+	for _, c := range []*kingpin.CmdClause{listCmd, printRulesCmd, getRuleGroupCmd, deleteRuleGroupCmd, deleteRuleNamespaceCmd, loadRulesCmd, diffRulesCmd, syncRulesCmd, backtestCmd, backfillCmd} {
+		registerClientFlags(c, &r.ClientConfig, true)
 	}
 
+	// The check command only contacts cortex when checking rules against the cluster
+	registerClientFlags(checkCmd, &r.ClientConfig, false)
+	registerClientFlags(renderCmd, &r.ClientConfig, false)
+
 	// Print Rules Command
 	printRulesCmd.Flag("disable-color", "disable colored output").BoolVar(&r.DisableColor)
 
@@ -190,6 +220,7 @@ func (r *RuleCommand) Register(app *kingpin.Application) {
 		"rule-dirs",
 		"Comma separated list of paths to directories containing rules yaml files. Each file in a directory with a .yml or .yaml suffix will be parsed.",
 	).StringVar(&r.RuleFilesPath)
+	diffRulesCmd.Flag("owner-id", "ID of the pipeline syncing the rules. Only namespaces owned by it, or without an owner, are changed, and only the ones it owns are deleted.").StringVar(&r.OwnerID)
 	diffRulesCmd.Flag("disable-color", "disable colored output").BoolVar(&r.DisableColor)
 	diffRulesCmd.Flag("verbose", "show diff output with rules changes").BoolVar(&r.Verbose)
 
@@ -202,6 +233,9 @@ func (r *RuleCommand) Register(app *kingpin.Application) {
 		"rule-dirs",
 		"Comma separated list of paths to directories containing rules yaml files. Each file in a directory with a .yml or .yaml suffix will be parsed.",
 	).StringVar(&r.RuleFilesPath)
+	syncRulesCmd.Flag("owner-id", "ID of the pipeline syncing the rules, recorded in a marker rule group of each synced namespace. Only namespaces owned by it, or without an owner, are changed, and only the ones it owns are deleted.").StringVar(&r.OwnerID)
+	syncRulesCmd.Flag("limits-file", "File with the per-tenant limits in the Cortex runtime config format. The sync is aborted if the resulting rule groups would exceed the limits of the tenant.").ExistingFileVar(&r.LimitsFile)
+	syncRulesCmd.Flag("policy-file", "Policy file with the conventions rules have to follow. The sync is aborted if any rule violates them.").ExistingFileVar(&r.PolicyFile)
 
 	// Prepare Command
 	prepareCmd.Arg("rule-files", "The rule files to check.").ExistingFilesVar(&r.RuleFilesList)
@@ -216,6 +250,8 @@ func (r *RuleCommand) Register(app *kingpin.Application) {
 	).Short('i').BoolVar(&r.InPlaceEdit)
 	prepareCmd.Flag("label", "label to include as part of the aggregations.").Default(defaultPrepareAggregationLabel).Short('l').StringVar(&r.AggregationLabel)
 	prepareCmd.Flag("label-excluded-rule-groups", "Comma separated list of rule group names to exclude when including the configured label to aggregations.").StringVar(&r.AggregationLabelExcludedRuleGroups)
+	prepareCmd.Flag("label-rule-groups", "Comma separated list of rule group names to include the configured label to. All rule groups are modified if unset.").StringVar(&r.AggregationLabelRuleGroups)
+	prepareCmd.Flag("inject-label-value", "Scopes every vector and matrix selector to the series with the configured label set to this value, and keeps the label in the results of aggregations and binary operations.").StringVar(&r.InjectLabelValue)
 
 	// Lint Command
 	lintCmd.Arg("rule-files", "The rule files to check.").ExistingFilesVar(&r.RuleFilesList)
@@ -225,6 +261,9 @@ func (r *RuleCommand) Register(app *kingpin.Application) {
 		"Comma separated list of paths to directories containing rules yaml files. Each file in a directory with a .yml or .yaml suffix will be parsed.",
 	).StringVar(&r.RuleFilesPath)
 	lintCmd.Flag("dry-run", "Performs a trial run that doesn't make any changes and (mostly) produces the same outpupt as a real run.").Short('n').BoolVar(&r.LintDryRun)
+	lintCmd.Flag("pretty", "Formats PromQL expressions with the Prometheus pretty-printer, splitting long expressions over multiple lines.").BoolVar(&r.LintPretty)
+	lintCmd.Flag("pretty-width", "Maximum line width of the expressions formatted with --pretty.").Default(strconv.Itoa(rules.DefaultPrettyWidth)).IntVar(&r.LintPrettyWidth)
+	lintCmd.Flag("check", "Doesn't make any changes and exits with a non-zero code if any file is not formatted.").BoolVar(&r.LintCheck)
 
 	// Check Command
 	checkCmd.Arg("rule-files", "The rule files to check.").ExistingFilesVar(&r.RuleFilesList)
@@ -234,12 +273,123 @@ func (r *RuleCommand) Register(app *kingpin.Application) {
 		"Comma separated list of paths to directories containing rules yaml files. Each file in a directory with a .yml or .yaml suffix will be parsed.",
 	).StringVar(&r.RuleFilesPath)
 	checkCmd.Flag("strict", "fails rules checks that do not match best practices exactly").BoolVar(&r.Strict)
+	checkCmd.Flag("policy-file", "Policy file with the conventions rules have to follow.").ExistingFileVar(&r.PolicyFile)
+	checkCmd.Flag("against-cluster", "reports rules whose selectors match no series in the tenant. Requires --address and --id.").BoolVar(&r.CheckAgainstCluster)
+	checkCmd.Flag("metadata-from-cluster", "checks that functions and aggregations are applied to metrics of the right type, using the metric metadata of the tenant. Requires --address and --id.").BoolVar(&r.MetadataFromCluster)
+	checkCmd.Flag("metadata-file", "checks that functions and aggregations are applied to metrics of the right type, using a dump of the /api/v1/metadata endpoint.").ExistingFileVar(&r.MetadataFile)
+	checkCmd.Flag("query-cost-budget", "reports the rules whose expressions have an estimated cost, as computed by promql explain, greater than this budget, most expensive first. 0 disables the budget.").Default("0").Float64Var(&r.QueryCostBudget)
+	checkCmd.Flag("lookback", "How far back in time to look for series when checking against the cluster.").Default("24h").DurationVar(&r.CheckLookback)
+	checkCmd.Flag("format", "Output format of the checks against the cluster: <json|text>").Default("text").EnumVar(&r.Format, "json", "text")
+
+	// Backtest Command
+	backtestCmd.Arg("rule-files", "The rule files to backtest.").ExistingFilesVar(&r.RuleFilesList)
+	backtestCmd.Flag("rule-files", "The rule files to backtest. Flag can be reused to load multiple files.").StringVar(&r.RuleFiles)
+	backtestCmd.Flag(
+		"rule-dirs",
+		"Comma separated list of paths to directories containing rules yaml files. Each file in a directory with a .yml or .yaml suffix will be parsed.",
+	).StringVar(&r.RuleFilesPath)
+	backtestCmd.Flag("namespaces", "comma-separated list of namespaces to backtest. Cannot be used together with --ignored-namespaces.").StringVar(&r.Namespaces)
+	backtestCmd.Flag("ignored-namespaces", "comma-separated list of namespaces to ignore during a backtest. Cannot be used together with --namespaces.").StringVar(&r.IgnoredNamespaces)
+	backtestCmd.Flag("lookback", "How far back in time to evaluate the alerting rules.").Default("168h").DurationVar(&r.BacktestLookback)
+	backtestCmd.Flag("step", "Evaluation step of the range queries. Defaults to the interval of each rule group, or 1m if unset.").DurationVar(&r.BacktestStep)
+	backtestCmd.Flag("compare-remote", "only backtest the rule groups that differ from the ones in the ruler, and compare them with the current version of each alert.").BoolVar(&r.BacktestCompare)
+	backtestCmd.Flag("format", "Output format: <json|table>").Default("table").EnumVar(&r.Format, "json", "table")
+
+	// Backfill Command
+	backfillCmd.Arg("rule-files", "The rule files to backfill.").ExistingFilesVar(&r.RuleFilesList)
+	backfillCmd.Flag("rule-files", "The rule files to backfill. Flag can be reused to load multiple files.").StringVar(&r.RuleFiles)
+	backfillCmd.Flag(
+		"rule-dirs",
+		"Comma separated list of paths to directories containing rules yaml files. Each file in a directory with a .yml or .yaml suffix will be parsed.",
+	).StringVar(&r.RuleFilesPath)
+	backfillCmd.Flag("namespaces", "comma-separated list of namespaces to backfill. Cannot be used together with --ignored-namespaces.").StringVar(&r.Namespaces)
+	backfillCmd.Flag("ignored-namespaces", "comma-separated list of namespaces to ignore during a backfill. Cannot be used together with --namespaces.").StringVar(&r.IgnoredNamespaces)
+	backfillCmd.Flag("from", "Start of the time range to backfill, in RFC3339 format.").Required().StringVar(&r.BackfillFrom)
+	backfillCmd.Flag("to", "End of the time range to backfill, in RFC3339 format. Defaults to now.").StringVar(&r.BackfillTo)
+	backfillCmd.Flag("step", "Evaluation step of the recording rules. Defaults to the interval of each rule group, or 1m if unset.").DurationVar(&r.BackfillStep)
+	backfillCmd.Flag("output-dir", "Directory the TSDB blocks are written to.").Default("data/").StringVar(&r.BackfillOutputDir)
+	backfillCmd.Flag("read-timeout", "Timeout of the remote read requests used to evaluate rules depending on other backfilled rules.").Default("5m").DurationVar(&r.BackfillTimeout)
+
+	// Render Command
+	renderCmd.Arg("rule-files", "The rule files to render.").ExistingFilesVar(&r.RuleFilesList)
+	renderCmd.Flag("rule-files", "The rule files to render. Flag can be reused to load multiple files.").StringVar(&r.RuleFiles)
+	renderCmd.Flag(
+		"rule-dirs",
+		"Comma separated list of paths to directories containing rules yaml files. Each file in a directory with a .yml or .yaml suffix will be parsed.",
+	).StringVar(&r.RuleFilesPath)
+	renderCmd.Flag("namespaces", "comma-separated list of namespaces to render. Cannot be used together with --ignored-namespaces.").StringVar(&r.Namespaces)
+	renderCmd.Flag("ignored-namespaces", "comma-separated list of namespaces to ignore during a render. Cannot be used together with --namespaces.").StringVar(&r.IgnoredNamespaces)
+	renderCmd.Flag("sample", `Sample the templates are rendered for, as a label set optionally followed by a value, as in '{job="api", instance="a"} 42'. Flag can be reused to render several samples.`).StringsVar(&r.RenderSamples)
+	renderCmd.Flag("from-cluster", "Render the templates for the series returned by the alert expressions, evaluated against the cluster. Requires --address and --id.").BoolVar(&r.RenderFromCluster)
+	renderCmd.Flag("max-series", "Maximum number of series rendered for each alert with --from-cluster.").Default("5").IntVar(&r.RenderMaxSeries)
+	renderCmd.Flag("external-url", "Value of $externalURL in the templates.").Default("").StringVar(&r.RenderExternalURL)
+	renderCmd.Flag("external-label", "Label of $externalLabels in the templates, as name=value. Flag can be reused to set several labels.").StringMapVar(&r.RenderExternalLabels)
+	renderCmd.Flag("format", "Output format: <json|text>").Default("text").EnumVar(&r.Format, "json", "text")
+
+	// Reorganize Command
+	reorganizeCmd.Arg("rule-files", "The rule files to reorganize.").ExistingFilesVar(&r.RuleFilesList)
+	reorganizeCmd.Flag("rule-files", "The rule files to reorganize. Flag can be reused to load multiple files.").StringVar(&r.RuleFiles)
+	reorganizeCmd.Flag(
+		"rule-dirs",
+		"Comma separated list of paths to directories containing rules yaml files. Each file in a directory with a .yml or .yaml suffix will be parsed.",
+	).StringVar(&r.RuleFilesPath)
+	reorganizeCmd.Flag("max-group-size", "Groups with more rules are split. Rules depending on each other are kept in the same group. 0 disables splitting.").Default("0").IntVar(&r.ReorganizeMaxGroupSize)
+	reorganizeCmd.Flag("min-group-size", "Groups with fewer rules are merged with the other small groups of their namespace with the same interval. 0 disables merging.").Default("0").IntVar(&r.ReorganizeMinGroupSize)
+	reorganizeCmd.Flag("namespace-label", "Moves the groups whose rules all have this label to the namespace named after its value.").StringVar(&r.ReorganizeNamespaceLabel)
+	reorganizeCmd.Flag("namespace-mapping-file", "YAML file of regexes matching <namespace>/<group> and the namespaces the matching groups are moved to.").ExistingFileVar(&r.ReorganizeMappingFile)
+	reorganizeCmd.Flag("output-dir", "Directory the rule files are written to, one per namespace.").Required().StringVar(&r.ReorganizeOutputDir)
+	reorganizeCmd.Flag("format", "Output format of the change summary: <json|text>").Default("text").EnumVar(&r.Format, "json", "text")
 
 	// List Command
 	listCmd.Flag("format", "Backend type to interact with: <json|yaml|table>").Default("table").EnumVar(&r.Format, formats...)
 	listCmd.Flag("disable-color", "disable colored output").BoolVar(&r.DisableColor)
 }
 
+// registerClientFlags registers the flags used to contact the cortex cluster.
+// The address and tenant ID are only mandatory if required is set.
+func registerClientFlags(c *kingpin.CmdClause, cfg *client.Config, required bool) {
+	address := c.Flag("address", "Address of the cortex cluster, alternatively set CORTEX_ADDRESS.").
+		Envar("CORTEX_ADDRESS")
+	id := c.Flag("id", "Cortex tenant id, alternatively set CORTEX_TENANT_ID.").
+		Envar("CORTEX_TENANT_ID")
+	if required {
+		address.Required()
+		id.Required()
+	}
+	address.StringVar(&cfg.Address)
+	id.StringVar(&cfg.ID)
+
+	c.Flag("use-legacy-routes", "If set, API requests to cortex will use the legacy /api/prom/ routes, alternatively set CORTEX_USE_LEGACY_ROUTES.").
+		Default("false").
+		Envar("CORTEX_USE_LEGACY_ROUTES").
+		BoolVar(&cfg.UseLegacyRoutes)
+
+	c.Flag("ruler-api-path", "if set, API requests to cortex will use an alternative path for the ruler API, alternatively set CORTEX_RULER_API_PATH. The default is /api/v1/rules").
+		Default("").
+		Envar("CORTEX_RULER_API_PATH").
+		StringVar(&cfg.RulerAPIPath)
+
+	c.Flag("prometheus-api-path", "if set, queries to cortex will use an alternative prefix for the Prometheus HTTP API, alternatively set CORTEX_PROMETHEUS_API_PATH. The default is /prometheus").
+		Default("").
+		Envar("CORTEX_PROMETHEUS_API_PATH").
+		StringVar(&cfg.PrometheusAPIPath)
+
+	c.Flag("tls-ca-path", "TLS CA certificate to verify cortex API as part of mTLS, alternatively set CORTEX_TLS_CA_PATH.").
+		Default("").
+		Envar("CORTEX_TLS_CA_CERT").
+		StringVar(&cfg.TLS.CAPath)
+
+	c.Flag("tls-cert-path", "TLS client certificate to authenticate with cortex API as part of mTLS, alternatively set CORTEX_TLS_CERT_PATH.").
+		Default("").
+		Envar("CORTEX_TLS_CLIENT_CERT").
+		StringVar(&cfg.TLS.CertPath)
+
+	c.Flag("tls-key-path", "TLS client certificate private key to authenticate with cortex API as part of mTLS, alternatively set CORTEX_TLS_KEY_PATH.").
+		Default("").
+		Envar("CORTEX_TLS_CLIENT_KEY").
+		StringVar(&cfg.TLS.KeyPath)
+}
+
 func (r *RuleCommand) setup(_ *kingpin.ParseContext) error {
 	prometheus.MustRegister(
 		ruleLoadTimestamp,
@@ -288,6 +438,16 @@ func (r *RuleCommand) setupFiles() error {
 		}
 	}
 
+	// Set up rule groups label aggregation is restricted to.
+	if r.AggregationLabelRuleGroups != "" {
+		r.aggregationLabelRuleGroupsList = map[string]struct{}{}
+		for _, name := range strings.Split(r.AggregationLabelRuleGroups, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				r.aggregationLabelRuleGroupsList[name] = struct{}{}
+			}
+		}
+	}
+
 	for _, file := range strings.Split(r.RuleFiles, ",") {
 		if file != "" {
 			log.WithFields(log.Fields{
@@ -299,37 +459,49 @@ func (r *RuleCommand) setupFiles() error {
 
 	for _, dir := range strings.Split(r.RuleFilesPath, ",") {
 		if dir != "" {
-			err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
-				if err != nil {
-					return err
-				}
-				if info.IsDir() {
-					return nil
-				}
-
-				if strings.HasSuffix(info.Name(), ".yml") || strings.HasSuffix(info.Name(), ".yaml") {
-					log.WithFields(log.Fields{
-						"file": info.Name(),
-						"path": path,
-					}).Debugf("adding file in rule-path")
-					r.RuleFilesList = append(r.RuleFilesList, path)
-					return nil
-				}
-				log.WithFields(log.Fields{
-					"file": info.Name(),
-					"path": path,
-				}).Debugf("ignorings file in rule-path")
-				return nil
-			})
+			files, err := findRuleFiles(dir)
 			if err != nil {
-				return fmt.Errorf("error walking the path %q: %v", dir, err)
+				return err
 			}
+			r.RuleFilesList = append(r.RuleFilesList, files...)
 		}
 	}
 
 	return nil
 }
 
+// findRuleFiles returns the files in a directory, and its subdirectories,
+// with a .yml or .yaml suffix.
+func findRuleFiles(dir string) ([]string, error) {
+	var files []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+
+		if strings.HasSuffix(info.Name(), ".yml") || strings.HasSuffix(info.Name(), ".yaml") {
+			log.WithFields(log.Fields{
+				"file": info.Name(),
+				"path": path,
+			}).Debugf("adding file in rule-path")
+			files = append(files, path)
+			return nil
+		}
+		log.WithFields(log.Fields{
+			"file": info.Name(),
+			"path": path,
+		}).Debugf("ignorings file in rule-path")
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error walking the path %q: %v", dir, err)
+	}
+	return files, nil
+}
+
 func (r *RuleCommand) listRules(_ *kingpin.ParseContext) error {
 	rules, err := r.cli.ListRules(context.Background(), "")
 	if err != nil {
@@ -471,7 +643,15 @@ func (r *RuleCommand) diffRules(_ *kingpin.ParseContext) error {
 			continue
 		}
 
+		if r.OwnerID != "" {
+			ns.SetOwner(r.OwnerID)
+		}
+
 		currentNamespace, exists := currentNamespaceMap[ns.Namespace]
+		if exists && !r.canChangeNamespace(ns.Namespace, currentNamespace, false) {
+			delete(currentNamespaceMap, ns.Namespace)
+			continue
+		}
 		if !exists {
 			changes = append(changes, rules.NamespaceChange{
 				State:         rules.Created,
@@ -493,7 +673,7 @@ func (r *RuleCommand) diffRules(_ *kingpin.ParseContext) error {
 	}
 
 	for ns, deletedGroups := range currentNamespaceMap {
-		if !r.shouldCheckNamespace(ns) {
+		if !r.shouldCheckNamespace(ns) || !r.canChangeNamespace(ns, deletedGroups, true) {
 			continue
 		}
 
@@ -519,6 +699,18 @@ func (r *RuleCommand) syncRules(_ *kingpin.ParseContext) error {
 		return errors.Wrap(err, "sync operation unsuccessful, unable to parse rules files")
 	}
 
+	if r.PolicyFile != "" {
+		var synced []rules.RuleNamespace
+		for _, ns := range sortedNamespaces(nss) {
+			if r.shouldCheckNamespace(ns.Namespace) {
+				synced = append(synced, ns)
+			}
+		}
+		if err := r.evaluatePolicies(synced); err != nil {
+			return errors.Wrap(err, "sync operation unsuccessful")
+		}
+	}
+
 	currentNamespaceMap, err := r.cli.ListRules(context.Background(), "")
 	//TODO: Skipping the 404s here might end up in an unsual scenario.
 	// If we're unable to reach the Cortex API due to a bad URL, we'll assume no rules are
@@ -527,6 +719,9 @@ func (r *RuleCommand) syncRules(_ *kingpin.ParseContext) error {
 		return errors.Wrap(err, "sync operation unsuccessful, unable to contact cortex api")
 	}
 
+	// The namespaces are removed from the map while computing the changes.
+	currentRuleGroups := maps.Clone(currentNamespaceMap)
+
 	changes := []rules.NamespaceChange{}
 
 	for _, ns := range nss {
@@ -534,7 +729,15 @@ func (r *RuleCommand) syncRules(_ *kingpin.ParseContext) error {
 			continue
 		}
 
+		if r.OwnerID != "" {
+			ns.SetOwner(r.OwnerID)
+		}
+
 		currentNamespace, exists := currentNamespaceMap[ns.Namespace]
+		if exists && !r.canChangeNamespace(ns.Namespace, currentNamespace, false) {
+			delete(currentNamespaceMap, ns.Namespace)
+			continue
+		}
 		if !exists {
 			changes = append(changes, rules.NamespaceChange{
 				State:         rules.Created,
@@ -556,7 +759,7 @@ func (r *RuleCommand) syncRules(_ *kingpin.ParseContext) error {
 	}
 
 	for ns, deletedGroups := range currentNamespaceMap {
-		if !r.shouldCheckNamespace(ns) {
+		if !r.shouldCheckNamespace(ns) || !r.canChangeNamespace(ns, deletedGroups, true) {
 			continue
 		}
 
@@ -567,6 +770,12 @@ func (r *RuleCommand) syncRules(_ *kingpin.ParseContext) error {
 		})
 	}
 
+	if r.LimitsFile != "" {
+		if err := r.checkLimits(rules.ApplyChanges(currentRuleGroups, changes)); err != nil {
+			return errors.Wrap(err, "sync operation unsuccessful")
+		}
+	}
+
 	err = r.executeChanges(context.Background(), changes)
 	if err != nil {
 		return errors.Wrap(err, "sync operation unsuccessful, unable to complete executing changes")
@@ -575,6 +784,26 @@ func (r *RuleCommand) syncRules(_ *kingpin.ParseContext) error {
 	return nil
 }
 
+// canChangeNamespace returns whether the namespace can be updated, or deleted,
+// given the owner recorded in its current groups.
+func (r *RuleCommand) canChangeNamespace(namespace string, current []rwrulefmt.RuleGroup, deleting bool) bool {
+	if err := rules.CheckOwnership(r.OwnerID, current, deleting); err != nil {
+		log.WithFields(log.Fields{
+			"namespace": namespace,
+			"owner_id":  r.OwnerID,
+		}).WithError(err).Warnln("skipping namespace not owned by this sync")
+		return false
+	}
+
+	if owner := rules.NamespaceOwner(current); deleting && r.OwnerID == "" && owner != "" {
+		log.WithFields(log.Fields{
+			"namespace": namespace,
+			"owner":     owner,
+		}).Warnln("deleting namespace owned by another sync, set --owner-id to only delete owned namespaces")
+	}
+	return true
+}
+
 func (r *RuleCommand) executeChanges(ctx context.Context, changes []rules.NamespaceChange) error {
 	var err error
 	for _, ch := range changes {
@@ -641,12 +870,21 @@ func (r *RuleCommand) prepare(_ *kingpin.ParseContext) error {
 		return errors.Wrap(err, "prepare operation unsuccessful, unable to parse rules files")
 	}
 
-	// Do not apply the aggregation label to excluded rule groups.
+	// Only apply the aggregation label to the selected rule groups, if any, and never to excluded ones.
 	applyTo := func(group rwrulefmt.RuleGroup, _ rulefmt.RuleNode) bool {
+		if r.aggregationLabelRuleGroupsList != nil {
+			if _, included := r.aggregationLabelRuleGroupsList[group.Name]; !included {
+				return false
+			}
+		}
 		_, excluded := r.aggregationLabelExcludedRuleGroupsList[group.Name]
 		return !excluded
 	}
 
+	if r.InjectLabelValue != "" {
+		return r.injectLabel(namespaces, applyTo)
+	}
+
 	var count, mod int
 	for _, ruleNamespace := range namespaces {
 		c, m, err := ruleNamespace.AggregateBy(r.AggregationLabel, applyTo)
@@ -668,6 +906,36 @@ func (r *RuleCommand) prepare(_ *kingpin.ParseContext) error {
 	return nil
 }
 
+// injectLabel scopes the selectors of the rules to the label value, then
+// prints a report of the rewritten expressions.
+func (r *RuleCommand) injectLabel(namespaces map[string]rules.RuleNamespace, applyTo func(rwrulefmt.RuleGroup, rulefmt.RuleNode) bool) error {
+	var count int
+	var rewrites []rules.ExpressionRewrite
+	for _, ruleNamespace := range sortedNamespaces(namespaces) {
+		c, rw, err := ruleNamespace.InjectLabel(r.AggregationLabel, r.InjectLabelValue, applyTo)
+		if err != nil {
+			return errors.Wrapf(err, "prepare operation unsuccessful, unable to inject label in namespace %s", ruleNamespace.Namespace)
+		}
+
+		count += c
+		rewrites = append(rewrites, rw...)
+	}
+
+	if err := save(namespaces, r.InPlaceEdit); err != nil {
+		return err
+	}
+
+	for _, rw := range rewrites {
+		fmt.Printf("namespace: %s, group: %s, rule: %s\n", rw.Namespace, rw.Group, rw.Rule)
+		fmt.Printf("\t- %s\n", strings.ReplaceAll(rw.Before, "\n", "\n\t  "))
+		fmt.Printf("\t+ %s\n", strings.ReplaceAll(rw.After, "\n", "\n\t  "))
+	}
+
+	log.Infof("SUCCESS: %d rules found, %d modified expressions", count, len(rewrites))
+
+	return nil
+}
+
 func (r *RuleCommand) lint(_ *kingpin.ParseContext) error {
 	err := r.setupFiles()
 	if err != nil {
@@ -681,7 +949,12 @@ func (r *RuleCommand) lint(_ *kingpin.ParseContext) error {
 
 	var count, mod int
 	for _, ruleNamespace := range namespaces {
-		c, m, err := ruleNamespace.LintExpressions()
+		var c, m int
+		if r.LintPretty {
+			c, m, err = ruleNamespace.PrettifyExpressions(r.LintPrettyWidth)
+		} else {
+			c, m, err = ruleNamespace.LintExpressions()
+		}
 		if err != nil {
 			return err
 		}
@@ -690,6 +963,10 @@ func (r *RuleCommand) lint(_ *kingpin.ParseContext) error {
 		mod += m
 	}
 
+	if r.LintCheck {
+		return checkFormatted(namespaces)
+	}
+
 	if !r.LintDryRun {
 		// linting will always in-place edit unless is a dry-run.
 		if err := save(namespaces, true); err != nil {
@@ -731,6 +1008,28 @@ func (r *RuleCommand) checkRecordingRuleNames(_ *kingpin.ParseContext) error {
 		}
 	}
 
+	if r.PolicyFile != "" {
+		if err := r.evaluatePolicies(sortedNamespaces(namespaces)); err != nil {
+			return err
+		}
+	}
+
+	if r.QueryCostBudget > 0 {
+		if err := r.checkQueryCosts(sortedNamespaces(namespaces)); err != nil {
+			return err
+		}
+	}
+
+	if r.MetadataFromCluster || r.MetadataFile != "" {
+		if err := r.checkMetricTypes(sortedNamespaces(namespaces)); err != nil {
+			return err
+		}
+	}
+
+	if r.CheckAgainstCluster {
+		return r.checkAgainstCluster(sortedNamespaces(namespaces))
+	}
+
 	return nil
 }
 
@@ -772,6 +1071,36 @@ func ruleMetric(rule rulefmt.RuleNode) string {
 
 // End taken from https://github.com/prometheus/prometheus/blob/8c8de46003d1800c9d40121b4a5e5de8582ef6e1/cmd/promtool/main.go#L403
 
+// checkFormatted returns an error if saving the namespaces would change any of
+// their files.
+func checkFormatted(nss map[string]rules.RuleNamespace) error {
+	var unformatted int
+	for _, ns := range sortedNamespaces(nss) {
+		payload, err := yamlv3.Marshal(ns)
+		if err != nil {
+			return err
+		}
+
+		content, err := os.ReadFile(ns.Filepath)
+		if err != nil {
+			return err
+		}
+
+		if !bytes.Equal(content, payload) {
+			log.WithFields(log.Fields{
+				"namespace": ns.Namespace,
+				"file":      ns.Filepath,
+			}).Errorf("file is not formatted")
+			unformatted++
+		}
+	}
+
+	if unformatted != 0 {
+		return fmt.Errorf("%d files are not formatted", unformatted)
+	}
+	return nil
+}
+
 // save saves a set of rule files to to disk. You can specify whenever you want the
 // file(s) to be edited in-place.
 func save(nss map[string]rules.RuleNamespace, i bool) error {
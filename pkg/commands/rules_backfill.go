@@ -0,0 +1,261 @@
+package commands
+
+import (
+	"context"
+	"io"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/prometheus/prometheus/storage/remote"
+	"github.com/prometheus/prometheus/tsdb"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/alecthomas/kingpin.v2"
+
+	"github.com/cortexproject/cortex-tools/pkg/backfill"
+	"github.com/cortexproject/cortex-tools/pkg/rules"
+)
+
+// maxSamplesInAppender is the number of samples committed at once when
+// writing blocks.
+const maxSamplesInAppender = 5000
+
+func (r *RuleCommand) backfillRules(_ *kingpin.ParseContext) error {
+	err := r.setupFiles()
+	if err != nil {
+		return errors.Wrap(err, "backfill operation unsuccessful, unable to load rules files")
+	}
+
+	start, err := time.Parse(time.RFC3339, r.BackfillFrom)
+	if err != nil {
+		return errors.Wrapf(err, "backfill operation unsuccessful, unable to parse --from %q", r.BackfillFrom)
+	}
+	end := time.Now()
+	if r.BackfillTo != "" {
+		end, err = time.Parse(time.RFC3339, r.BackfillTo)
+		if err != nil {
+			return errors.Wrapf(err, "backfill operation unsuccessful, unable to parse --to %q", r.BackfillTo)
+		}
+	}
+	if !start.Before(end) {
+		return errors.New("backfill operation unsuccessful, --from must be before --to")
+	}
+
+	nss, err := rules.ParseFiles(r.RuleFilesList)
+	if err != nil {
+		return errors.Wrap(err, "backfill operation unsuccessful, unable to parse rules files")
+	}
+
+	var selected []rules.RuleNamespace
+	for _, ns := range sortedNamespaces(nss) {
+		if r.shouldCheckNamespace(ns.Namespace) {
+			selected = append(selected, ns)
+		}
+	}
+	recordingRules, err := rules.SortRecordingRules(selected)
+	if err != nil {
+		return errors.Wrap(err, "backfill operation unsuccessful")
+	}
+
+	promAPI, err := r.cli.PrometheusAPI()
+	if err != nil {
+		return errors.Wrap(err, "backfill operation unsuccessful, unable to create query client")
+	}
+
+	if err := os.MkdirAll(r.BackfillOutputDir, 0755); err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	var readClient remote.ReadClient
+	for _, rr := range recordingRules {
+		step := r.BackfillStep
+		if step == 0 {
+			step = rr.Interval
+		}
+		if step == 0 {
+			step = defaultEvaluationInterval
+		}
+
+		log.WithFields(log.Fields{
+			"record":    rr.Rule.Record.Value,
+			"group":     rr.Group,
+			"namespace": rr.Namespace,
+		}).Infof("backfilling recording rule")
+
+		var matrix model.Matrix
+		if len(rr.Dependencies) == 0 {
+			matrix, err = queryRange(ctx, promAPI, rr.Rule.Expr.Value, start.Truncate(step), end, step)
+		} else {
+			// The series recorded by the rules it depends on are only in the
+			// blocks written so far, so the rule is evaluated locally.
+			if readClient == nil {
+				readClient, err = r.cli.RemoteReadClient(r.BackfillTimeout)
+				if err != nil {
+					return errors.Wrap(err, "backfill operation unsuccessful, unable to create remote read client")
+				}
+			}
+			matrix, err = evaluateLocally(ctx, readClient, r.BackfillOutputDir, rr.Rule.Expr.Value, start.Truncate(step), end, step)
+		}
+		if err != nil {
+			return errors.Wrapf(err, "backfill operation unsuccessful, unable to evaluate recording rule %q", rr.Rule.Record.Value)
+		}
+
+		if err := writeRecordingRuleBlocks(rr, matrix, r.BackfillOutputDir); err != nil {
+			return errors.Wrapf(err, "backfill operation unsuccessful, unable to write blocks of recording rule %q", rr.Rule.Record.Value)
+		}
+	}
+
+	log.WithFields(log.Fields{
+		"rules":  len(recordingRules),
+		"output": r.BackfillOutputDir,
+	}).Infof("backfill complete")
+	return nil
+}
+
+// evaluateLocally evaluates an expression over a range with the Prometheus
+// engine, reading series from the blocks in dir and from the remote read API.
+func evaluateLocally(ctx context.Context, readClient remote.ReadClient, dir, expr string, start, end time.Time, step time.Duration) (model.Matrix, error) {
+	db, err := tsdb.OpenDBReadOnly(dir, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	remoteQueryable := remote.NewSampleAndChunkQueryableClient(readClient, labels.EmptyLabels(), nil, true, func() (int64, error) { return 0, nil })
+	queryable := storage.QueryableFunc(func(mint, maxt int64) (storage.Querier, error) {
+		local, err := db.Querier(mint, maxt)
+		if err != nil {
+			return nil, err
+		}
+		fromRemote, err := remoteQueryable.Querier(mint, maxt)
+		if err != nil {
+			return nil, err
+		}
+		return storage.NewMergeQuerier([]storage.Querier{local, fromRemote}, nil, storage.ChainedSeriesMerge), nil
+	})
+
+	engine := promql.NewEngine(promql.EngineOpts{
+		MaxSamples:               50000000,
+		Timeout:                  time.Hour,
+		EnableAtModifier:         true,
+		EnableNegativeOffset:     true,
+		NoStepSubqueryIntervalFn: func(int64) int64 { return step.Milliseconds() },
+	})
+	q, err := engine.NewRangeQuery(ctx, queryable, nil, expr, start, end, step)
+	if err != nil {
+		return nil, err
+	}
+	defer q.Close()
+
+	res := q.Exec(ctx)
+	if res.Err != nil {
+		return nil, res.Err
+	}
+	m, err := res.Matrix()
+	if err != nil {
+		return nil, err
+	}
+
+	matrix := make(model.Matrix, 0, len(m))
+	for _, series := range m {
+		ss := &model.SampleStream{Metric: model.Metric{}}
+		series.Metric.Range(func(l labels.Label) {
+			ss.Metric[model.LabelName(l.Name)] = model.LabelValue(l.Value)
+		})
+		// Only float samples can be written to the blocks.
+		if len(series.Histograms) > 0 {
+			log.WithFields(log.Fields{
+				"expr":    expr,
+				"series":  series.Metric.String(),
+				"samples": len(series.Histograms),
+			}).Warnln("skipping native histogram samples, only float samples are backfilled")
+		}
+		for _, p := range series.Floats {
+			ss.Values = append(ss.Values, model.SamplePair{Timestamp: model.Time(p.T), Value: model.SampleValue(p.F)})
+		}
+		matrix = append(matrix, ss)
+	}
+	return matrix, nil
+}
+
+// writeRecordingRuleBlocks writes the samples of a recording rule, named
+// after its record and with its labels, as TSDB blocks.
+func writeRecordingRuleBlocks(rr rules.RecordingRule, matrix model.Matrix, dir string) error {
+	var mint, maxt int64
+	series := make([]recordedSeries, 0, len(matrix))
+	for _, ss := range matrix {
+		if len(ss.Histograms) > 0 {
+			log.WithField("record", rr.Rule.Record.Value).Warnln("native histogram samples are not backfilled")
+		}
+		if len(ss.Values) == 0 {
+			continue
+		}
+
+		b := labels.NewBuilder(labels.EmptyLabels())
+		for name, value := range ss.Metric {
+			b.Set(string(name), string(value))
+		}
+		for name, value := range rr.Rule.Labels {
+			b.Set(name, value)
+		}
+		b.Set(labels.MetricName, rr.Rule.Record.Value)
+
+		first, last := int64(ss.Values[0].Timestamp), int64(ss.Values[len(ss.Values)-1].Timestamp)
+		if len(series) == 0 || first < mint {
+			mint = first
+		}
+		if len(series) == 0 || last > maxt {
+			maxt = last
+		}
+		series = append(series, recordedSeries{labels: b.Labels(), values: ss.Values})
+	}
+
+	if len(series) == 0 {
+		log.WithField("record", rr.Rule.Record.Value).Warnln("recording rule returned no samples")
+		return nil
+	}
+
+	output := log.StandardLogger().Writer()
+	defer output.Close()
+	return backfill.CreateBlocks(func() backfill.Iterator {
+		return &recordedSeriesIterator{series: series, value: -1}
+	}, mint, maxt, maxSamplesInAppender, dir, true, output)
+}
+
+type recordedSeries struct {
+	labels labels.Labels
+	values []model.SamplePair
+}
+
+// recordedSeriesIterator iterates over the samples of recorded series.
+type recordedSeriesIterator struct {
+	series []recordedSeries
+	idx    int
+	value  int
+}
+
+func (i *recordedSeriesIterator) Next() error {
+	for i.idx < len(i.series) {
+		i.value++
+		if i.value < len(i.series[i.idx].values) {
+			return nil
+		}
+		i.idx++
+		i.value = -1
+	}
+	return io.EOF
+}
+
+func (i *recordedSeriesIterator) Sample() (int64, float64) {
+	p := i.series[i.idx].values[i.value]
+	return int64(p.Timestamp), float64(p.Value)
+}
+
+func (i *recordedSeriesIterator) Labels() labels.Labels {
+	return i.series[i.idx].labels
+}
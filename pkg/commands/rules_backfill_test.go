@@ -0,0 +1,109 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/prometheus/prometheus/storage/remote"
+	"github.com/prometheus/prometheus/tsdb"
+	"github.com/prometheus/prometheus/tsdb/chunkenc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cortexproject/cortex-tools/pkg/client"
+)
+
+func TestBackfillRules(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := start.Add(10 * time.Minute)
+
+	var queries []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/prometheus/api/v1/query_range":
+			require.NoError(t, req.ParseForm())
+			queries = append(queries, req.Form.Get("query"))
+
+			var values []string
+			for ts := start; !ts.After(end); ts = ts.Add(time.Minute) {
+				values = append(values, fmt.Sprintf(`[%d,"2"]`, ts.Unix()))
+			}
+			fmt.Fprintf(w, `{"status":"success","data":{"resultType":"matrix","result":[{"metric":{"job":"api"},"values":[%s]}]}}`, strings.Join(values, ","))
+		case "/prometheus/api/v1/read":
+			_, err := remote.DecodeReadRequest(req)
+			require.NoError(t, err)
+			resp := &prompb.ReadResponse{Results: []*prompb.QueryResult{{}}}
+			require.NoError(t, remote.EncodeReadResponse(resp, w))
+		default:
+			http.NotFound(w, req)
+		}
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	ruleFile := filepath.Join(dir, "rules.yaml")
+	require.NoError(t, os.WriteFile(ruleFile, []byte(`namespace: backfill
+groups:
+- name: group
+  rules:
+  - record: job:up:double
+    expr: job:up:sum * 2
+    labels:
+      source: backfill
+  - record: job:up:sum
+    expr: sum by (job) (up)
+`), 0644))
+
+	cli, err := client.New(client.Config{Address: srv.URL, ID: "tenant"})
+	require.NoError(t, err)
+
+	output := filepath.Join(dir, "data")
+	r := &RuleCommand{
+		cli:               cli,
+		RuleFilesList:     []string{ruleFile},
+		BackfillFrom:      start.Format(time.RFC3339),
+		BackfillTo:        end.Format(time.RFC3339),
+		BackfillOutputDir: output,
+		BackfillTimeout:   time.Minute,
+	}
+	require.NoError(t, r.backfillRules(nil))
+
+	// Only the rule without dependencies is evaluated by Cortex.
+	assert.Equal(t, []string{"sum by (job) (up)"}, queries)
+
+	db, err := tsdb.OpenDBReadOnly(output, nil)
+	require.NoError(t, err)
+	defer db.Close()
+	q, err := db.Querier(start.UnixMilli(), end.UnixMilli())
+	require.NoError(t, err)
+	defer q.Close()
+
+	expected := map[string]float64{
+		`{__name__="job:up:sum", job="api"}`:                       2,
+		`{__name__="job:up:double", job="api", source="backfill"}`: 4,
+	}
+	ss := q.Select(context.Background(), true, nil, labels.MustNewMatcher(labels.MatchRegexp, labels.MetricName, "job:up:.+"))
+	found := map[string]float64{}
+	for ss.Next() {
+		series := ss.At()
+		it := series.Iterator(nil)
+		var samples int
+		for it.Next() == chunkenc.ValFloat {
+			_, v := it.At()
+			found[series.Labels().String()] = v
+			samples++
+		}
+		assert.Equal(t, 11, samples, series.Labels().String())
+	}
+	require.NoError(t, ss.Err())
+	assert.Equal(t, expected, found)
+}
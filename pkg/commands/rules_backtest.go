@@ -0,0 +1,253 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pkg/errors"
+	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/rulefmt"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/alecthomas/kingpin.v2"
+
+	"github.com/cortexproject/cortex-tools/pkg/client"
+	"github.com/cortexproject/cortex-tools/pkg/rules"
+	"github.com/cortexproject/cortex-tools/pkg/rules/rwrulefmt"
+)
+
+const (
+	// defaultEvaluationInterval matches the default evaluation interval of the Cortex ruler.
+	defaultEvaluationInterval = time.Minute
+
+	// maxPointsPerQuery is kept below the 11,000 points per series a range query can return.
+	maxPointsPerQuery = 10000
+)
+
+// backtestResult is the backtest of a single alerting rule. Original is set
+// when the rule was compared against the version currently in the ruler.
+type backtestResult struct {
+	Namespace string               `json:"namespace"`
+	Group     string               `json:"group"`
+	Local     rules.AlertBacktest  `json:"local"`
+	Original  *rules.AlertBacktest `json:"original,omitempty"`
+}
+
+func (r *RuleCommand) backtestRules(_ *kingpin.ParseContext) error {
+	err := r.setupFiles()
+	if err != nil {
+		return errors.Wrap(err, "backtest operation unsuccessful, unable to load rules files")
+	}
+
+	nss, err := rules.ParseFiles(r.RuleFilesList)
+	if err != nil {
+		return errors.Wrap(err, "backtest operation unsuccessful, unable to parse rules files")
+	}
+
+	ctx := context.Background()
+	promAPI, err := r.cli.PrometheusAPI()
+	if err != nil {
+		return errors.Wrap(err, "backtest operation unsuccessful, unable to create query client")
+	}
+
+	var remoteNamespaces map[string][]rwrulefmt.RuleGroup
+	if r.BacktestCompare {
+		remoteNamespaces, err = r.cli.ListRules(ctx, "")
+		if err != nil && !errors.Is(err, client.ErrResourceNotFound) {
+			return errors.Wrap(err, "backtest operation unsuccessful, unable to contact cortex api")
+		}
+	}
+
+	end := time.Now()
+	start := end.Add(-r.BacktestLookback)
+
+	var results []backtestResult
+	for _, ns := range sortedNamespaces(nss) {
+		if !r.shouldCheckNamespace(ns.Namespace) {
+			continue
+		}
+
+		for _, group := range ns.Groups {
+			var original *rwrulefmt.RuleGroup
+			if r.BacktestCompare {
+				for _, g := range remoteNamespaces[ns.Namespace] {
+					if g.Name == group.Name {
+						g := g
+						original = &g
+						break
+					}
+				}
+
+				// Only the groups changed in a diff are backtested when comparing.
+				if original != nil && rules.CompareGroups(group, *original) == nil {
+					log.WithFields(log.Fields{
+						"group":     group.Name,
+						"namespace": ns.Namespace,
+					}).Debugf("group unchanged, skipping")
+					continue
+				}
+			}
+
+			step := r.BacktestStep
+			if step == 0 {
+				step = time.Duration(group.Interval)
+			}
+			if step == 0 {
+				step = defaultEvaluationInterval
+			}
+
+			for _, rule := range group.Rules {
+				if rule.Alert.Value == "" {
+					continue
+				}
+
+				log.WithFields(log.Fields{
+					"alert":     rule.Alert.Value,
+					"group":     group.Name,
+					"namespace": ns.Namespace,
+				}).Infof("backtesting alert")
+
+				local, err := backtestAlert(ctx, promAPI, rule, start, end, step)
+				if err != nil {
+					return errors.Wrapf(err, "backtest operation unsuccessful, unable to backtest alert %q", rule.Alert.Value)
+				}
+
+				result := backtestResult{
+					Namespace: ns.Namespace,
+					Group:     group.Name,
+					Local:     local,
+				}
+
+				if original != nil {
+					for _, origRule := range original.Rules {
+						if origRule.Alert.Value != rule.Alert.Value {
+							continue
+						}
+
+						orig, err := backtestAlert(ctx, promAPI, origRule, start, end, step)
+						if err != nil {
+							return errors.Wrapf(err, "backtest operation unsuccessful, unable to backtest current version of alert %q", rule.Alert.Value)
+						}
+						result.Original = &orig
+						break
+					}
+				}
+
+				results = append(results, result)
+			}
+		}
+	}
+
+	return printBacktestResults(results, r.Format, os.Stdout)
+}
+
+func backtestAlert(ctx context.Context, promAPI v1.API, rule rulefmt.RuleNode, start, end time.Time, step time.Duration) (rules.AlertBacktest, error) {
+	// Align the range to the step so the chunked queries share evaluation timestamps.
+	start = start.Truncate(step)
+
+	matrix, err := queryRange(ctx, promAPI, rule.Expr.Value, start, end, step)
+	if err != nil {
+		return rules.AlertBacktest{}, err
+	}
+
+	log.WithFields(log.Fields{
+		"alert":  rule.Alert.Value,
+		"series": len(matrix),
+	}).Debugf("evaluated alert expression")
+
+	return rules.BacktestAlert(rule, matrix, start, end, step), nil
+}
+
+// queryRange runs a range query, splitting it into several queries when the
+// range holds more points than a single query is allowed to return.
+func queryRange(ctx context.Context, promAPI v1.API, query string, start, end time.Time, step time.Duration) (model.Matrix, error) {
+	bySeries := map[model.Fingerprint]*model.SampleStream{}
+	var order []model.Fingerprint
+
+	window := step * maxPointsPerQuery
+	for from := start; !from.After(end); from = from.Add(window + step) {
+		to := from.Add(window)
+		if to.After(end) {
+			to = end
+		}
+
+		value, warnings, err := promAPI.QueryRange(ctx, query, v1.Range{Start: from, End: to, Step: step})
+		if err != nil {
+			return nil, errors.Wrapf(err, "error querying %s", query)
+		}
+		for _, w := range warnings {
+			log.WithField("query", query).Warnln(w)
+		}
+
+		matrix, ok := value.(model.Matrix)
+		if !ok {
+			return nil, fmt.Errorf("unexpected result type %q for range query %s", value.Type(), query)
+		}
+
+		for _, s := range matrix {
+			fp := s.Metric.Fingerprint()
+			if existing, ok := bySeries[fp]; ok {
+				existing.Values = append(existing.Values, s.Values...)
+				existing.Histograms = append(existing.Histograms, s.Histograms...)
+				continue
+			}
+			bySeries[fp] = s
+			order = append(order, fp)
+		}
+	}
+
+	result := make(model.Matrix, 0, len(order))
+	for _, fp := range order {
+		result = append(result, bySeries[fp])
+	}
+	return result, nil
+}
+
+// sortedNamespaces returns the parsed namespaces sorted by name.
+func sortedNamespaces(nss map[string]rules.RuleNamespace) []rules.RuleNamespace {
+	sorted := make([]rules.RuleNamespace, 0, len(nss))
+	for _, ns := range nss {
+		sorted = append(sorted, ns)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Namespace < sorted[j].Namespace })
+	return sorted
+}
+
+func printBacktestResults(results []backtestResult, format string, w io.Writer) error {
+	if format == "json" {
+		out, err := json.MarshalIndent(results, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, string(out))
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAMESPACE\tGROUP\tALERT\tVERSION\tFIRINGS\tSERIES\tFLAPPING\tMIN\tMEDIAN\tMAX")
+	for _, res := range results {
+		printBacktestRow(tw, res.Namespace, res.Group, "local", res.Local)
+		if res.Original != nil {
+			printBacktestRow(tw, res.Namespace, res.Group, "current", *res.Original)
+		}
+	}
+	return tw.Flush()
+}
+
+func printBacktestRow(w io.Writer, namespace, group, version string, b rules.AlertBacktest) {
+	shortest, median, longest := "-", "-", "-"
+	if durations := b.Durations(); len(durations) > 0 {
+		shortest = durations[0].String()
+		median = durations[len(durations)/2].String()
+		longest = durations[len(durations)-1].String()
+	}
+
+	fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%d\t%d\t%s\t%s\t%s\n",
+		namespace, group, b.Alert, version, len(b.Firings), b.Series(), b.Flapping(), shortest, median, longest)
+}
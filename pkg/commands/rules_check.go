@@ -0,0 +1,227 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/prometheus/promql/parser"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/cortexproject/cortex-tools/pkg/analyse"
+	"github.com/cortexproject/cortex-tools/pkg/limits"
+	"github.com/cortexproject/cortex-tools/pkg/rules"
+	"github.com/cortexproject/cortex-tools/pkg/rules/rwrulefmt"
+)
+
+func (r *RuleCommand) checkAgainstCluster(namespaces []rules.RuleNamespace) error {
+	if r.ClientConfig.Address == "" || r.ClientConfig.ID == "" {
+		return errors.New("--address and --id are required to check rules against the cluster")
+	}
+
+	promAPI, err := r.cli.PrometheusAPI()
+	if err != nil {
+		return errors.Wrap(err, "check operation unsuccessful, unable to create query client")
+	}
+
+	end := time.Now()
+	unmatched, err := rules.CheckAgainstCluster(context.Background(), promAPI, namespaces, end.Add(-r.CheckLookback), end)
+	if err != nil {
+		return errors.Wrap(err, "check operation unsuccessful, unable to check rules against the cluster")
+	}
+
+	if err := printUnmatchedSelectors(unmatched, r.Format, os.Stdout); err != nil {
+		return err
+	}
+
+	if len(unmatched) != 0 {
+		return fmt.Errorf("%d rule selectors match no series", len(unmatched))
+	}
+	return nil
+}
+
+func (r *RuleCommand) checkMetricTypes(namespaces []rules.RuleNamespace) error {
+	if r.MetadataFromCluster && r.MetadataFile != "" {
+		return errors.New("--metadata-from-cluster and --metadata-file cannot be set at the same time")
+	}
+
+	var md rules.MetricMetadata
+	if r.MetadataFile != "" {
+		var err error
+		md, err = rules.LoadMetricMetadata(r.MetadataFile)
+		if err != nil {
+			return errors.Wrap(err, "check operation unsuccessful, unable to load metadata file")
+		}
+	} else {
+		if r.ClientConfig.Address == "" || r.ClientConfig.ID == "" {
+			return errors.New("--address and --id are required to fetch metric metadata from the cluster")
+		}
+
+		promAPI, err := r.cli.PrometheusAPI()
+		if err != nil {
+			return errors.Wrap(err, "check operation unsuccessful, unable to create query client")
+		}
+
+		md, err = promAPI.Metadata(context.Background(), "", "")
+		if err != nil {
+			return errors.Wrap(err, "check operation unsuccessful, unable to fetch metric metadata")
+		}
+	}
+
+	var count int
+	for _, ns := range namespaces {
+		n, err := ns.CheckMetricTypes(md)
+		if err != nil {
+			return errors.Wrapf(err, "check operation unsuccessful, unable to check metric types of namespace %s", ns.Namespace)
+		}
+		count += n
+	}
+
+	if count != 0 {
+		return fmt.Errorf("%d expressions misuse metric types", count)
+	}
+	return nil
+}
+
+// evaluatePolicies returns an error if any rule in the namespaces violates the
+// policy file.
+func (r *RuleCommand) evaluatePolicies(namespaces []rules.RuleNamespace) error {
+	pf, err := rules.LoadPolicyFile(r.PolicyFile)
+	if err != nil {
+		return errors.Wrap(err, "unable to load policy file")
+	}
+
+	var count int
+	for _, ns := range namespaces {
+		violations, err := pf.Evaluate(ns)
+		if err != nil {
+			return errors.Wrapf(err, "unable to evaluate policies for namespace %s", ns.Namespace)
+		}
+
+		for _, v := range violations {
+			log.WithFields(log.Fields{
+				"namespace": v.Namespace,
+				"group":     v.Group,
+				"rule":      v.Rule,
+				"file":      ns.Filepath,
+				"error":     v.Message,
+			}).Errorf("policy violation")
+		}
+		count += len(violations)
+	}
+
+	if count != 0 {
+		return fmt.Errorf("%d policy violations", count)
+	}
+	return nil
+}
+
+// checkQueryCosts returns an error if the estimated cost of any rule
+// expression is over the budget.
+func (r *RuleCommand) checkQueryCosts(namespaces []rules.RuleNamespace) error {
+	type ruleCost struct {
+		namespace, group, rule string
+		cost                   *analyse.QueryCost
+	}
+
+	var over []ruleCost
+	for _, ns := range namespaces {
+		for _, group := range ns.Groups {
+			for _, rule := range group.Rules {
+				expr, err := parser.ParseExpr(rule.Expr.Value)
+				if err != nil {
+					return errors.Wrapf(err, "unable to estimate the cost of rule %s in namespace %s", ruleMetric(rule), ns.Namespace)
+				}
+				cost := analyse.ExplainQuery(expr)
+				if cost.Score > r.QueryCostBudget {
+					over = append(over, ruleCost{namespace: ns.Namespace, group: group.Name, rule: ruleMetric(rule), cost: cost})
+				}
+			}
+		}
+	}
+
+	sort.SliceStable(over, func(i, j int) bool {
+		return over[i].cost.Score > over[j].cost.Score
+	})
+	for _, rc := range over {
+		log.WithFields(log.Fields{
+			"namespace": rc.namespace,
+			"group":     rc.group,
+			"rule":      rc.rule,
+			"cost":      rc.cost.Score,
+		}).Errorf("rule expression is over the query cost budget")
+	}
+
+	if len(over) != 0 {
+		return fmt.Errorf("%d rule expressions over the query cost budget of %g", len(over), r.QueryCostBudget)
+	}
+	return nil
+}
+
+// checkLimits returns an error if the rule groups, keyed by namespace, exceed
+// the limits of the tenant.
+func (r *RuleCommand) checkLimits(ruleGroups map[string][]rwrulefmt.RuleGroup) error {
+	tenantLimits, err := limits.LoadTenantLimits(r.LimitsFile, r.ClientConfig.ID)
+	if err != nil {
+		return errors.Wrap(err, "unable to load limits file")
+	}
+
+	errs := tenantLimits.ValidateRules(ruleGroups)
+	for _, err := range errs {
+		log.WithError(err).Errorf("limit exceeded")
+	}
+
+	if len(errs) != 0 {
+		return fmt.Errorf("%d limits exceeded", len(errs))
+	}
+	return nil
+}
+
+func printUnmatchedSelectors(unmatched []rules.UnmatchedSelector, format string, w io.Writer) error {
+	if format == "json" {
+		if unmatched == nil {
+			unmatched = []rules.UnmatchedSelector{}
+		}
+		out, err := json.MarshalIndent(unmatched, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, string(out))
+		return err
+	}
+
+	var missing, mismatched []rules.UnmatchedSelector
+	for _, u := range unmatched {
+		if u.Problem == rules.MetricMissing {
+			missing = append(missing, u)
+		} else {
+			mismatched = append(mismatched, u)
+		}
+	}
+
+	if len(missing) > 0 {
+		fmt.Fprintf(w, "%d selector(s) reference metrics missing from the tenant:\n", len(missing))
+		for _, u := range missing {
+			fmt.Fprintf(w, "\tnamespace: %s, group: %s, rule: %s, selector: %s\n", u.Namespace, u.Group, u.Rule, u.Selector)
+		}
+	}
+
+	if len(mismatched) > 0 {
+		fmt.Fprintf(w, "%d selector(s) have label matchers that never match:\n", len(mismatched))
+		for _, u := range mismatched {
+			fmt.Fprintf(w, "\tnamespace: %s, group: %s, rule: %s, selector: %s", u.Namespace, u.Group, u.Rule, u.Selector)
+			if len(u.Matchers) > 0 {
+				fmt.Fprintf(w, ", matchers: %s", strings.Join(u.Matchers, ", "))
+			}
+			fmt.Fprintln(w)
+		}
+	}
+
+	return nil
+}
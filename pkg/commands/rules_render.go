@@ -0,0 +1,229 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/prometheus/prometheus/template"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/alecthomas/kingpin.v2"
+
+	"github.com/cortexproject/cortex-tools/pkg/rules"
+	"github.com/cortexproject/cortex-tools/pkg/util"
+)
+
+func (r *RuleCommand) renderRules(_ *kingpin.ParseContext) error {
+	err := r.setupFiles()
+	if err != nil {
+		return errors.Wrap(err, "render operation unsuccessful, unable to load rules files")
+	}
+
+	samples := make([]rules.RenderSample, 0, len(r.RenderSamples))
+	for _, s := range r.RenderSamples {
+		sample, err := rules.ParseRenderSample(s)
+		if err != nil {
+			return errors.Wrap(err, "render operation unsuccessful")
+		}
+		samples = append(samples, sample)
+	}
+	if len(samples) == 0 {
+		samples = []rules.RenderSample{{Labels: map[string]string{}}}
+	}
+
+	nss, err := rules.ParseFiles(r.RuleFilesList)
+	if err != nil {
+		return errors.Wrap(err, "render operation unsuccessful, unable to parse rules files")
+	}
+
+	now := time.Now()
+	opts := rules.RenderOptions{
+		ExternalLabels: r.RenderExternalLabels,
+		ExternalURL:    r.RenderExternalURL,
+		Time:           now,
+	}
+
+	var promAPI v1.API
+	if r.RenderFromCluster {
+		if r.ClientConfig.Address == "" || r.ClientConfig.ID == "" {
+			return errors.New("--address and --id are required to render rules against the cluster")
+		}
+		promAPI, err = r.cli.PrometheusAPI()
+		if err != nil {
+			return errors.Wrap(err, "render operation unsuccessful, unable to create query client")
+		}
+		opts.QueryFunc = clusterQueryFunc(promAPI)
+	}
+
+	ctx := context.Background()
+	var rendered []rules.RenderedAlert
+	for _, ns := range sortedNamespaces(nss) {
+		if !r.shouldCheckNamespace(ns.Namespace) {
+			continue
+		}
+		for _, group := range ns.Groups {
+			for _, rule := range group.Rules {
+				if rule.Alert.Value == "" {
+					continue
+				}
+
+				ruleSamples := samples
+				if r.RenderFromCluster {
+					ruleSamples, err = querySamples(ctx, promAPI, rule.Expr.Value, now, r.RenderMaxSeries)
+					if err != nil {
+						return errors.Wrapf(err, "render operation unsuccessful, unable to evaluate alert %q", rule.Alert.Value)
+					}
+					if len(ruleSamples) == 0 {
+						log.WithFields(log.Fields{
+							"alert":     rule.Alert.Value,
+							"group":     group.Name,
+							"namespace": ns.Namespace,
+						}).Warnln("alert expression returned no series, rendering with an empty sample")
+						ruleSamples = []rules.RenderSample{{Labels: map[string]string{}}}
+					}
+				}
+
+				for _, sample := range ruleSamples {
+					alert := rules.RenderAlert(ctx, rule, sample, opts)
+					alert.Namespace = ns.Namespace
+					alert.Group = group.Name
+					rendered = append(rendered, alert)
+				}
+			}
+		}
+	}
+
+	if err := printRenderedAlerts(rendered, r.Format, os.Stdout); err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, alert := range rendered {
+		if len(alert.Errors) > 0 {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d alerts failed to render", failed)
+	}
+	return nil
+}
+
+// querySamples returns up to max series of an instant query, as samples
+// alert templates are rendered for.
+func querySamples(ctx context.Context, promAPI v1.API, query string, ts time.Time, max int) ([]rules.RenderSample, error) {
+	vector, err := queryInstant(ctx, promAPI, query, ts)
+	if err != nil {
+		return nil, err
+	}
+
+	var samples []rules.RenderSample
+	for _, s := range vector {
+		if max > 0 && len(samples) >= max {
+			break
+		}
+		lbls := make(map[string]string, len(s.Metric))
+		for name, value := range s.Metric {
+			lbls[string(name)] = string(value)
+		}
+		samples = append(samples, rules.RenderSample{Labels: lbls, Value: float64(s.Value)})
+	}
+	return samples, nil
+}
+
+// clusterQueryFunc runs the queries of the query template function against
+// the cluster.
+func clusterQueryFunc(promAPI v1.API) template.QueryFunc {
+	return func(ctx context.Context, query string, ts time.Time) (promql.Vector, error) {
+		vector, err := queryInstant(ctx, promAPI, query, ts)
+		if err != nil {
+			return nil, err
+		}
+
+		result := make(promql.Vector, 0, len(vector))
+		for _, s := range vector {
+			b := labels.NewScratchBuilder(len(s.Metric))
+			for name, value := range s.Metric {
+				b.Add(string(name), string(value))
+			}
+			b.Sort()
+			result = append(result, promql.Sample{
+				Metric: b.Labels(),
+				T:      int64(s.Timestamp),
+				F:      float64(s.Value),
+			})
+		}
+		return result, nil
+	}
+}
+
+func queryInstant(ctx context.Context, promAPI v1.API, query string, ts time.Time) (model.Vector, error) {
+	value, warnings, err := promAPI.Query(ctx, query, ts)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error querying %s", query)
+	}
+	for _, w := range warnings {
+		log.WithField("query", query).Warnln(w)
+	}
+
+	switch v := value.(type) {
+	case model.Vector:
+		return v, nil
+	case *model.Scalar:
+		return model.Vector{{Metric: model.Metric{}, Value: v.Value, Timestamp: v.Timestamp}}, nil
+	default:
+		return nil, fmt.Errorf("unexpected result type %s for query %s", value.Type(), query)
+	}
+}
+
+func printRenderedAlerts(rendered []rules.RenderedAlert, format string, w io.Writer) error {
+	if format == "json" {
+		if rendered == nil {
+			rendered = []rules.RenderedAlert{}
+		}
+		out, err := json.MarshalIndent(rendered, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, string(out))
+		return err
+	}
+
+	for _, alert := range rendered {
+		fmt.Fprintf(w, "namespace: %s, group: %s, alert: %s\n", alert.Namespace, alert.Group, alert.Alert)
+		fmt.Fprintf(w, "\tsample: %s %v\n", toLabelSet(alert.Sample.Labels), alert.Sample.Value)
+		fmt.Fprintln(w, "\tlabels:")
+		for _, name := range util.SortedKeys(alert.Labels) {
+			fmt.Fprintf(w, "\t\t%s: %s\n", name, alert.Labels[name])
+		}
+		if len(alert.Annotations) > 0 {
+			fmt.Fprintln(w, "\tannotations:")
+			for _, name := range util.SortedKeys(alert.Annotations) {
+				fmt.Fprintf(w, "\t\t%s: %s\n", name, alert.Annotations[name])
+			}
+		}
+		for _, e := range alert.Errors {
+			fmt.Fprintf(w, "\terror: %s: %s\n", e.Field, e.Err)
+		}
+		for _, name := range alert.MissingLabels {
+			fmt.Fprintf(w, "\twarning: label %q is referenced by templates but missing from the sample\n", name)
+		}
+	}
+	return nil
+}
+
+func toLabelSet(m map[string]string) model.LabelSet {
+	ls := make(model.LabelSet, len(m))
+	for name, value := range m {
+		ls[model.LabelName(name)] = model.LabelValue(value)
+	}
+	return ls
+}
@@ -0,0 +1,108 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/alecthomas/kingpin.v2"
+	yamlv3 "gopkg.in/yaml.v3"
+
+	"github.com/cortexproject/cortex-tools/pkg/rules"
+)
+
+func (r *RuleCommand) reorganizeRules(_ *kingpin.ParseContext) error {
+	err := r.setupFiles()
+	if err != nil {
+		return errors.Wrap(err, "reorganize operation unsuccessful, unable to load rules files")
+	}
+
+	cfg := rules.ReorganizeConfig{
+		MaxGroupSize:   r.ReorganizeMaxGroupSize,
+		MinGroupSize:   r.ReorganizeMinGroupSize,
+		NamespaceLabel: r.ReorganizeNamespaceLabel,
+	}
+	if cfg.MaxGroupSize > 0 && cfg.MinGroupSize > cfg.MaxGroupSize {
+		return errors.New("reorganize operation unsuccessful, --min-group-size must not be greater than --max-group-size")
+	}
+	if r.ReorganizeMappingFile != "" {
+		cfg.Mappings, err = rules.LoadNamespaceMappingFile(r.ReorganizeMappingFile)
+		if err != nil {
+			return errors.Wrap(err, "reorganize operation unsuccessful, unable to load namespace mapping file")
+		}
+	}
+
+	nss, err := rules.ParseFiles(r.RuleFilesList)
+	if err != nil {
+		return errors.Wrap(err, "reorganize operation unsuccessful, unable to parse rules files")
+	}
+
+	reorganized, changes, err := rules.Reorganize(sortedNamespaces(nss), cfg)
+	if err != nil {
+		return errors.Wrap(err, "reorganize operation unsuccessful")
+	}
+	for _, ns := range reorganized {
+		if errs := ns.Validate(); len(errs) > 0 {
+			return errors.Wrapf(errs[0], "reorganize operation unsuccessful, namespace %s is invalid", ns.Namespace)
+		}
+	}
+
+	if err := os.MkdirAll(r.ReorganizeOutputDir, 0755); err != nil {
+		return err
+	}
+	for _, ns := range reorganized {
+		payload, err := yamlv3.Marshal(ns)
+		if err != nil {
+			return err
+		}
+		file := filepath.Join(r.ReorganizeOutputDir, namespaceFilename(ns.Namespace))
+		if err := os.WriteFile(file, payload, 0644); err != nil {
+			return err
+		}
+		log.WithFields(log.Fields{
+			"namespace": ns.Namespace,
+			"groups":    len(ns.Groups),
+			"file":      file,
+		}).Debugln("wrote namespace")
+	}
+
+	return printReorganizeChanges(nss, reorganized, changes, r.Format, os.Stdout)
+}
+
+// namespaceFilename returns the name of the file a namespace is written to.
+func namespaceFilename(namespace string) string {
+	return strings.NewReplacer("/", "_", "\\", "_").Replace(namespace) + ".yaml"
+}
+
+func printReorganizeChanges(before map[string]rules.RuleNamespace, after []rules.RuleNamespace, changes []rules.ReorganizeChange, format string, w io.Writer) error {
+	if format == "json" {
+		if changes == nil {
+			changes = []rules.ReorganizeChange{}
+		}
+		out, err := json.MarshalIndent(changes, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, string(out))
+		return err
+	}
+
+	for _, c := range changes {
+		fmt.Fprintln(w, c)
+	}
+
+	groupsBefore, groupsAfter := 0, 0
+	for _, ns := range before {
+		groupsBefore += len(ns.Groups)
+	}
+	for _, ns := range after {
+		groupsAfter += len(ns.Groups)
+	}
+	fmt.Fprintf(w, "%d namespaces and %d groups reorganized into %d namespaces and %d groups, %d changes\n", len(before), groupsBefore, len(after), groupsAfter, len(changes))
+	return nil
+}
@@ -0,0 +1,191 @@
+// Package limits validates rules and alertmanager configs against the
+// per-tenant limits of a Cortex cluster before they are uploaded.
+package limits
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"reflect"
+	"strings"
+
+	"github.com/prometheus/alertmanager/config"
+	log "github.com/sirupsen/logrus"
+	yaml "gopkg.in/yaml.v3"
+
+	"github.com/cortexproject/cortex-tools/pkg/client"
+	"github.com/cortexproject/cortex-tools/pkg/rules/rwrulefmt"
+	"github.com/cortexproject/cortex-tools/pkg/util"
+)
+
+// TenantLimits holds the Cortex limits enforced when rules and alertmanager
+// configs are uploaded. A limit of 0 means unlimited.
+type TenantLimits struct {
+	RulerMaxRulesPerRuleGroup   int `yaml:"ruler_max_rules_per_rule_group"`
+	RulerMaxRuleGroupsPerTenant int `yaml:"ruler_max_rule_groups_per_tenant"`
+
+	AlertmanagerMaxConfigSizeBytes             int    `yaml:"alertmanager_max_config_size_bytes"`
+	AlertmanagerMaxTemplatesCount              int    `yaml:"alertmanager_max_templates_count"`
+	AlertmanagerMaxTemplateSizeBytes           int    `yaml:"alertmanager_max_template_size_bytes"`
+	AlertmanagerReceiversBlockCIDRNetworks     string `yaml:"alertmanager_receivers_firewall_block_cidr_networks"`
+	AlertmanagerReceiversBlockPrivateAddresses bool   `yaml:"alertmanager_receivers_firewall_block_private_addresses"`
+}
+
+// runtimeConfig is the subset of the Cortex runtime config holding the
+// per-tenant overrides.
+type runtimeConfig struct {
+	Overrides map[string]TenantLimits `yaml:"overrides"`
+}
+
+// LoadTenantLimits reads the limits of a tenant from a file in the Cortex
+// runtime config format. If the tenant has no overrides, no limits are enforced.
+func LoadTenantLimits(filename, tenant string) (TenantLimits, error) {
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return TenantLimits{}, err
+	}
+
+	var cfg runtimeConfig
+	if err := yaml.Unmarshal(content, &cfg); err != nil {
+		return TenantLimits{}, fmt.Errorf("unable to parse limits file %s: %w", filename, err)
+	}
+
+	limits, ok := cfg.Overrides[tenant]
+	if !ok {
+		log.WithFields(log.Fields{
+			"file":   filename,
+			"tenant": tenant,
+		}).Warnln("no overrides found for tenant, limits will not be checked")
+	}
+	return limits, nil
+}
+
+// ValidateRules checks the rule groups a tenant will have once a sync is
+// complete, keyed by namespace, against the ruler limits.
+func (l TenantLimits) ValidateRules(namespaces map[string][]rwrulefmt.RuleGroup) []error {
+	var errs []error
+
+	var groups int
+	for _, ns := range util.SortedKeys(namespaces) {
+		groups += len(namespaces[ns])
+
+		if l.RulerMaxRulesPerRuleGroup <= 0 {
+			continue
+		}
+		for _, g := range namespaces[ns] {
+			if len(g.Rules) > l.RulerMaxRulesPerRuleGroup {
+				errs = append(errs, fmt.Errorf("namespace %q, group %q: %d rules exceed the limit of %d rules per rule group", ns, g.Name, len(g.Rules), l.RulerMaxRulesPerRuleGroup))
+			}
+		}
+	}
+
+	if l.RulerMaxRuleGroupsPerTenant > 0 && groups > l.RulerMaxRuleGroupsPerTenant {
+		errs = append(errs, fmt.Errorf("%d rule groups exceed the limit of %d rule groups per tenant", groups, l.RulerMaxRuleGroupsPerTenant))
+	}
+
+	return errs
+}
+
+// ValidateAlertmanagerConfig checks an alertmanager config and its templates
+// against the alertmanager limits. Receiver URLs are only checked against the
+// firewall when their host is an IP address, as hostnames are not resolved.
+func (l TenantLimits) ValidateAlertmanagerConfig(cfg string, templates map[string]string) ([]error, error) {
+	var errs []error
+
+	if l.AlertmanagerMaxConfigSizeBytes > 0 {
+		payload, err := client.AlertmanagerConfigPayload(cfg, templates)
+		if err != nil {
+			return nil, err
+		}
+		if len(payload) > l.AlertmanagerMaxConfigSizeBytes {
+			errs = append(errs, fmt.Errorf("config size of %d bytes exceeds the limit of %d bytes", len(payload), l.AlertmanagerMaxConfigSizeBytes))
+		}
+	}
+
+	if l.AlertmanagerMaxTemplatesCount > 0 && len(templates) > l.AlertmanagerMaxTemplatesCount {
+		errs = append(errs, fmt.Errorf("%d templates exceed the limit of %d templates", len(templates), l.AlertmanagerMaxTemplatesCount))
+	}
+
+	if l.AlertmanagerMaxTemplateSizeBytes > 0 {
+		for _, name := range util.SortedKeys(templates) {
+			if size := len(templates[name]); size > l.AlertmanagerMaxTemplateSizeBytes {
+				errs = append(errs, fmt.Errorf("template %q: size of %d bytes exceeds the limit of %d bytes", name, size, l.AlertmanagerMaxTemplateSizeBytes))
+			}
+		}
+	}
+
+	if l.AlertmanagerReceiversBlockCIDRNetworks == "" && !l.AlertmanagerReceiversBlockPrivateAddresses {
+		return errs, nil
+	}
+
+	var blocked []*net.IPNet
+	for _, cidr := range strings.Split(l.AlertmanagerReceiversBlockCIDRNetworks, ",") {
+		if cidr = strings.TrimSpace(cidr); cidr == "" {
+			continue
+		}
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR %q in alertmanager_receivers_firewall_block_cidr_networks: %w", cidr, err)
+		}
+		blocked = append(blocked, network)
+	}
+
+	amCfg, err := config.Load(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, rcv := range amCfg.Receivers {
+		for _, u := range receiverURLs(reflect.ValueOf(rcv)) {
+			ip := net.ParseIP(u.Hostname())
+			if ip == nil {
+				continue
+			}
+
+			if l.AlertmanagerReceiversBlockPrivateAddresses && (ip.IsPrivate() || ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsUnspecified()) {
+				errs = append(errs, fmt.Errorf("receiver %q: host %s is a private address blocked by the receivers firewall", rcv.Name, u.Host))
+				continue
+			}
+			for _, network := range blocked {
+				if network.Contains(ip) {
+					errs = append(errs, fmt.Errorf("receiver %q: host %s is in the network %s blocked by the receivers firewall", rcv.Name, u.Host, network))
+					break
+				}
+			}
+		}
+	}
+
+	return errs, nil
+}
+
+var urlType = reflect.TypeOf(&url.URL{})
+
+// receiverURLs returns all the URLs set in the integrations of a receiver.
+func receiverURLs(v reflect.Value) []*url.URL {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		if v.Type() == urlType {
+			return []*url.URL{v.Interface().(*url.URL)}
+		}
+		return receiverURLs(v.Elem())
+	case reflect.Struct:
+		var urls []*url.URL
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).IsExported() {
+				urls = append(urls, receiverURLs(v.Field(i))...)
+			}
+		}
+		return urls
+	case reflect.Slice:
+		var urls []*url.URL
+		for i := 0; i < v.Len(); i++ {
+			urls = append(urls, receiverURLs(v.Index(i))...)
+		}
+		return urls
+	}
+	return nil
+}
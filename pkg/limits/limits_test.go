@@ -0,0 +1,134 @@
+package limits
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/prometheus/model/rulefmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cortexproject/cortex-tools/pkg/rules/rwrulefmt"
+)
+
+func TestLoadTenantLimits(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "overrides.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+overrides:
+  "tenant-a":
+    ingestion_rate: 350000
+    ruler_max_rules_per_rule_group: 20
+    ruler_max_rule_groups_per_tenant: 70
+    alertmanager_receivers_firewall_block_private_addresses: true
+`), 0644))
+
+	l, err := LoadTenantLimits(path, "tenant-a")
+	require.NoError(t, err)
+	assert.Equal(t, TenantLimits{
+		RulerMaxRulesPerRuleGroup:                  20,
+		RulerMaxRuleGroupsPerTenant:                70,
+		AlertmanagerReceiversBlockPrivateAddresses: true,
+	}, l)
+
+	l, err = LoadTenantLimits(path, "tenant-b")
+	require.NoError(t, err)
+	assert.Equal(t, TenantLimits{}, l)
+}
+
+func TestValidateRules(t *testing.T) {
+	group := func(name string, rules int) rwrulefmt.RuleGroup {
+		g := rwrulefmt.RuleGroup{RuleGroup: rulefmt.RuleGroup{Name: name}}
+		for i := 0; i < rules; i++ {
+			g.Rules = append(g.Rules, rulefmt.RuleNode{})
+		}
+		return g
+	}
+
+	namespaces := map[string][]rwrulefmt.RuleGroup{
+		"ns1": {group("small", 1), group("large", 3)},
+		"ns2": {group("small", 2)},
+	}
+
+	assert.Empty(t, TenantLimits{}.ValidateRules(namespaces))
+	assert.Empty(t, TenantLimits{RulerMaxRulesPerRuleGroup: 3, RulerMaxRuleGroupsPerTenant: 3}.ValidateRules(namespaces))
+
+	errs := TenantLimits{RulerMaxRulesPerRuleGroup: 2, RulerMaxRuleGroupsPerTenant: 2}.ValidateRules(namespaces)
+	require.Len(t, errs, 2)
+	assert.EqualError(t, errs[0], `namespace "ns1", group "large": 3 rules exceed the limit of 2 rules per rule group`)
+	assert.EqualError(t, errs[1], "3 rule groups exceed the limit of 2 rule groups per tenant")
+}
+
+func TestValidateAlertmanagerConfig(t *testing.T) {
+	cfg := `
+route:
+  receiver: default
+receivers:
+- name: default
+  webhook_configs:
+  - url: http://10.0.0.1/alerts
+- name: public
+  webhook_configs:
+  - url: http://203.0.113.10/alerts
+- name: hostname
+  webhook_configs:
+  - url: http://alerts.example.com/
+`
+	templates := map[string]string{
+		"a.tmpl": `{{ define "a" }}a{{ end }}`,
+		"b.tmpl": `{{ define "b" }}` + strings.Repeat("b", 100) + `{{ end }}`,
+	}
+
+	tt := []struct {
+		name     string
+		limits   TenantLimits
+		expected []string
+	}{
+		{
+			name: "no limits",
+		},
+		{
+			name:   "config size",
+			limits: TenantLimits{AlertmanagerMaxConfigSizeBytes: 100},
+			expected: []string{
+				"config size of 516 bytes exceeds the limit of 100 bytes",
+			},
+		},
+		{
+			name:   "templates",
+			limits: TenantLimits{AlertmanagerMaxTemplatesCount: 1, AlertmanagerMaxTemplateSizeBytes: 50},
+			expected: []string{
+				"2 templates exceed the limit of 1 templates",
+				`template "b.tmpl": size of 125 bytes exceeds the limit of 50 bytes`,
+			},
+		},
+		{
+			name:   "private addresses",
+			limits: TenantLimits{AlertmanagerReceiversBlockPrivateAddresses: true},
+			expected: []string{
+				`receiver "default": host 10.0.0.1 is a private address blocked by the receivers firewall`,
+			},
+		},
+		{
+			name:   "blocked networks",
+			limits: TenantLimits{AlertmanagerReceiversBlockCIDRNetworks: "192.0.2.0/24, 203.0.113.0/24"},
+			expected: []string{
+				`receiver "public": host 203.0.113.10 is in the network 203.0.113.0/24 blocked by the receivers firewall`,
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			errs, err := tc.limits.ValidateAlertmanagerConfig(cfg, templates)
+			require.NoError(t, err)
+
+			var messages []string
+			for _, err := range errs {
+				messages = append(messages, err.Error())
+			}
+			assert.Equal(t, tc.expected, messages)
+		})
+	}
+}
@@ -13,6 +13,7 @@ import (
 	"github.com/mitchellh/colorstring"
 	"gopkg.in/yaml.v3"
 
+	"github.com/cortexproject/cortex-tools/pkg/alertmanager"
 	"github.com/cortexproject/cortex-tools/pkg/rules"
 	"github.com/cortexproject/cortex-tools/pkg/rules/rwrulefmt"
 )
@@ -60,8 +61,15 @@ func (p *Printer) PrintAlertmanagerConfig(config string, templates map[string]st
 		fmt.Println(string(config))
 	}
 
+	names := make([]string, 0, len(templates))
+	for fn := range templates {
+		names = append(names, fn)
+	}
+	sort.Strings(names)
+
 	fmt.Printf("\nTemplates:\n")
-	for fn, template := range templates {
+	for _, fn := range names {
+		template := templates[fn]
 		fmt.Println(fn + ":")
 		if !p.disableColor {
 			err := quick.Highlight(os.Stdout, template, "go-text-template", "terminal", "swapoff")
@@ -182,6 +190,54 @@ func (p *Printer) PrintComparisonResult(results []rules.NamespaceChange, verbose
 	return nil
 }
 
+// PrintAlertmanagerConfigChanges prints the differences between the stored
+// alertmanager config and templates and the local ones.
+func (p *Printer) PrintAlertmanagerConfigChanges(changes []alertmanager.ConfigChange, verbose bool) {
+	if len(changes) == 0 {
+		fmt.Println("no changes detected")
+		return
+	}
+
+	fmt.Println("The following changes will be made if the provided alertmanager config is synced:")
+	for _, change := range changes {
+		name := change.Section
+		if change.Name != "" {
+			name = fmt.Sprintf("%s: %s", change.Section, change.Name)
+		}
+		switch change.Kind {
+		case alertmanager.Added:
+			p.Printf("[green]+ %v\n", name)
+		case alertmanager.Removed:
+			p.Printf("[red]- %v\n", name)
+		default:
+			p.Printf("[yellow]~ %v\n", name)
+		}
+		if change.SecretsOnly {
+			fmt.Println("  (secret values changed)")
+		}
+
+		// The route tree is always shown, a change of a single matcher is
+		// otherwise hard to spot.
+		if !verbose && change.Section != "route" {
+			continue
+		}
+		for _, l := range strings.Split(strings.TrimRight(change.Diff, "\n"), "\n") {
+			switch {
+			case l == "" || strings.HasPrefix(l, "---") || strings.HasPrefix(l, "+++"):
+			case strings.HasPrefix(l, "+"):
+				p.Printf("[green]  %v\n", l)
+			case strings.HasPrefix(l, "-"):
+				p.Printf("[red]  %v\n", l)
+			default:
+				fmt.Printf("  %v\n", l)
+			}
+		}
+	}
+
+	fmt.Println()
+	fmt.Printf("Diff Summary: %v Changes\n", len(changes))
+}
+
 func (p *Printer) PrintRuleSet(rules map[string][]rwrulefmt.RuleGroup, format string, writer io.Writer) error {
 	nsKeys := make([]string, 0, len(rules))
 	for k := range rules {
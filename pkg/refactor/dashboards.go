@@ -0,0 +1,174 @@
+package refactor
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+
+	"github.com/grafana-tools/sdk"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/cortexproject/cortex-tools/pkg/analyse"
+)
+
+var (
+	labelValuesRe = regexp.MustCompile(`^(\s*label_values\()(.+)(,\s*[a-zA-Z_][a-zA-Z0-9_]*\s*\)\s*)$`)
+	queryResultRe = regexp.MustCompile(`^(\s*query_result\()(.+)(\)\s*)$`)
+	htmlEscapedRe = regexp.MustCompile(`\\u00(3c|3e|26)`)
+)
+
+// RenameInDashboard renames the metrics selected by the queries of a Grafana
+// dashboard, in the panel targets and the query template variables. It
+// returns the new dashboard and the number of queries modified.
+func (r *Renamer) RenameInDashboard(content []byte, fallback bool) ([]byte, int, error) {
+	var board sdk.Board
+	if err := json.Unmarshal(content, &board); err != nil {
+		return nil, 0, err
+	}
+
+	renamed := map[string]string{}
+	for _, panel := range analyse.PanelsInBoard(board) {
+		targets := panel.GetTargets()
+		if targets == nil {
+			continue
+		}
+		for _, target := range *targets {
+			// Prometheus has this set.
+			if target.Expr == "" {
+				continue
+			}
+			renameDashboardQuery(renamed, target.Expr, func(q string) (string, int, error) {
+				return r.RenameQuery(q, fallback)
+			})
+		}
+	}
+
+	for _, templateVar := range board.Templating.List {
+		if templateVar.Type != "query" {
+			continue
+		}
+		query, ok := templateVar.Query.(string)
+		if !ok {
+			continue
+		}
+		renameDashboardQuery(renamed, query, func(q string) (string, int, error) {
+			return r.renameTemplateQuery(q, fallback)
+		})
+	}
+
+	content, err := ReplaceDashboardQueries(content, renamed)
+	if err != nil {
+		return nil, 0, err
+	}
+	return content, len(renamed), nil
+}
+
+// ReplaceDashboardQueries replaces the queries of a Grafana dashboard, given
+// as a map of the current queries to the new ones. Only the queries are
+// replaced in the JSON document, to keep the rest of the dashboard as written.
+// Every query is replaced in a single pass over the original document, so
+// replacements are never replaced again.
+func ReplaceDashboardQueries(content []byte, queries map[string]string) ([]byte, error) {
+	var out bytes.Buffer
+	found := make(map[string]bool, len(queries))
+	for i := 0; i < len(content); i++ {
+		if content[i] != '"' {
+			out.WriteByte(content[i])
+			continue
+		}
+
+		end := jsonStringEnd(content, i)
+		if end < 0 {
+			return nil, fmt.Errorf("unterminated string in the dashboard")
+		}
+		literal := content[i : end+1]
+		i = end
+
+		var query string
+		if err := json.Unmarshal(literal, &query); err != nil {
+			return nil, err
+		}
+		to, ok := queries[query]
+		if !ok {
+			out.Write(literal)
+			continue
+		}
+		found[query] = true
+
+		// Dashboards are written with and without HTML escaping.
+		replacement, err := encodeJSONString(to, htmlEscapedRe.Match(literal))
+		if err != nil {
+			return nil, err
+		}
+		out.Write(replacement)
+	}
+
+	missing := make([]string, 0, len(queries))
+	for query := range queries {
+		if !found[query] {
+			missing = append(missing, query)
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return nil, fmt.Errorf("unable to find query %q in the dashboard", missing[0])
+	}
+	return out.Bytes(), nil
+}
+
+func renameDashboardQuery(renamed map[string]string, query string, rename func(string) (string, int, error)) {
+	if _, ok := renamed[query]; ok {
+		return
+	}
+
+	to, n, err := rename(query)
+	if err != nil {
+		// Dashboards can have queries for other datasources.
+		log.WithError(err).WithField("query", query).Warnln("unable to rename metrics in query")
+		return
+	}
+	if n > 0 {
+		renamed[query] = to
+	}
+}
+
+// renameTemplateQuery renames the metrics in the query of a template
+// variable, using the Prometheus datasource query functions.
+func (r *Renamer) renameTemplateQuery(query string, fallback bool) (string, int, error) {
+	if m := labelValuesRe.FindStringSubmatch(query); m != nil {
+		// The label values of a fallback would mix both metrics.
+		to, n, err := r.RenameQuery(m[2], false)
+		return m[1] + to + m[3], n, err
+	}
+	if m := queryResultRe.FindStringSubmatch(query); m != nil {
+		to, n, err := r.RenameQuery(m[2], fallback)
+		return m[1] + to + m[3], n, err
+	}
+	return query, 0, nil
+}
+
+// jsonStringEnd returns the index of the quote ending the JSON string
+// starting at start, or -1 if the string isn't terminated.
+func jsonStringEnd(content []byte, start int) int {
+	for i := start + 1; i < len(content); i++ {
+		switch content[i] {
+		case '\\':
+			i++
+		case '"':
+			return i
+		}
+	}
+	return -1
+}
+
+func encodeJSONString(s string, escapeHTML bool) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(escapeHTML)
+	if err := enc.Encode(s); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
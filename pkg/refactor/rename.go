@@ -0,0 +1,156 @@
+package refactor
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql/parser"
+	log "github.com/sirupsen/logrus"
+	yaml "gopkg.in/yaml.v3"
+
+	"github.com/cortexproject/cortex-tools/pkg/analyse"
+)
+
+// Renamer renames metrics, either from a fixed mapping or from a regular
+// expression.
+type Renamer struct {
+	mapping     map[string]string
+	re          *regexp.Regexp
+	replacement string
+}
+
+// NewRenamer returns a Renamer renaming the metric from to to.
+func NewRenamer(from, to string) *Renamer {
+	return &Renamer{mapping: map[string]string{from: to}}
+}
+
+// NewRegexRenamer returns a Renamer renaming the metrics fully matching the
+// regular expression from. The replacement to can reference the capture
+// groups of the expression, as in $1.
+func NewRegexRenamer(from, to string) (*Renamer, error) {
+	re, err := regexp.Compile("^(?:" + from + ")$")
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid metric regex")
+	}
+	return &Renamer{re: re, replacement: to}, nil
+}
+
+// LoadMappingFile returns a Renamer from a YAML file mapping the old metric
+// names to the new ones.
+func LoadMappingFile(filename string) (*Renamer, error) {
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	mapping := map[string]string{}
+	if err := yaml.Unmarshal(content, &mapping); err != nil {
+		return nil, errors.Wrapf(err, "unable to parse mapping file %q", filename)
+	}
+	if len(mapping) == 0 {
+		return nil, fmt.Errorf("mapping file %q is empty", filename)
+	}
+	return &Renamer{mapping: mapping}, nil
+}
+
+// Rename returns the new name of a metric and whether the metric is renamed.
+func (r *Renamer) Rename(name string) (string, bool) {
+	var renamed string
+	if r.re != nil {
+		if !r.re.MatchString(name) {
+			return name, false
+		}
+		renamed = r.re.ReplaceAllString(name, r.replacement)
+	} else {
+		to, ok := r.mapping[name]
+		if !ok {
+			return name, false
+		}
+		renamed = to
+	}
+	return renamed, renamed != name
+}
+
+// RenameQuery renames the metrics selected by a PromQL query. Only the metric
+// names are rewritten, the rest of the query is kept as written. When fallback
+// is set, the renamed query is combined with the original one using `or`, so
+// it keeps returning results while both metric names are in use. It returns
+// the new query and the number of selectors renamed.
+func (r *Renamer) RenameQuery(query string, fallback bool) (string, int, error) {
+	expr, err := parser.ParseExpr(analyse.MaskGrafanaVariables(query))
+	if err != nil {
+		return "", 0, err
+	}
+
+	type edit struct {
+		pos      int
+		from, to string
+	}
+	var edits []edit
+	err = parser.Walk(inspector(func(node parser.Node, _ []parser.Node) error {
+		vs, ok := node.(*parser.VectorSelector)
+		if !ok || vs.Name == "" {
+			return nil
+		}
+		to, ok := r.Rename(vs.Name)
+		if !ok {
+			return nil
+		}
+		if !model.IsValidMetricName(model.LabelValue(to)) {
+			return fmt.Errorf("invalid metric name %q for %q", to, vs.Name)
+		}
+
+		pos := int(vs.PosRange.Start)
+		if !strings.HasPrefix(query[pos:], vs.Name) {
+			// The name is set with a __name__ matcher.
+			log.WithFields(log.Fields{
+				"query":  query,
+				"metric": vs.Name,
+			}).Warnf("unable to rename metrics selected with a %s matcher", labels.MetricName)
+			return nil
+		}
+		edits = append(edits, edit{pos: pos, from: vs.Name, to: to})
+		return nil
+	}), expr, nil)
+	if err != nil {
+		return "", 0, err
+	}
+	if len(edits) == 0 {
+		return query, 0, nil
+	}
+
+	// Edit from the end of the query so the positions stay valid.
+	sort.Slice(edits, func(i, j int) bool { return edits[i].pos > edits[j].pos })
+	renamed := query
+	for _, e := range edits {
+		renamed = renamed[:e.pos] + e.to + renamed[e.pos+len(e.from):]
+	}
+
+	if fallback {
+		renamed = fmt.Sprintf("(%s) or (%s)", strings.TrimSpace(renamed), strings.TrimSpace(query))
+	}
+
+	// Scalar expressions can't be combined with `or`.
+	if _, err := parser.ParseExpr(analyse.MaskGrafanaVariables(renamed)); err != nil {
+		return "", 0, errors.Wrapf(err, "renamed query %q is invalid", renamed)
+	}
+
+	return renamed, len(edits), nil
+}
+
+// inspector walks an expression like parser.Inspect, but stops on the first
+// error returned by the function.
+type inspector func(parser.Node, []parser.Node) error
+
+func (f inspector) Visit(node parser.Node, path []parser.Node) (parser.Visitor, error) {
+	if err := f(node, path); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
@@ -0,0 +1,225 @@
+package refactor
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/prometheus/prometheus/model/rulefmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	yaml "gopkg.in/yaml.v3"
+
+	"github.com/cortexproject/cortex-tools/pkg/rules"
+	"github.com/cortexproject/cortex-tools/pkg/rules/rwrulefmt"
+)
+
+func TestRenameQuery(t *testing.T) {
+	renamer := NewRenamer("node_memory_MemFree", "node_memory_MemFree_bytes")
+
+	tt := []struct {
+		name     string
+		query    string
+		fallback bool
+		expected string
+		renamed  int
+	}{
+		{
+			name:     "only metric names are rewritten",
+			query:    `sum by (instance) (node_memory_MemFree{job="node"})  /  node_memory_MemTotal`,
+			expected: `sum by (instance) (node_memory_MemFree_bytes{job="node"})  /  node_memory_MemTotal`,
+			renamed:  1,
+		},
+		{
+			name:     "range selectors and grafana variables",
+			query:    `avg_over_time(node_memory_MemFree[$__rate_interval]) - node_memory_MemFree offset $__range`,
+			expected: `avg_over_time(node_memory_MemFree_bytes[$__rate_interval]) - node_memory_MemFree_bytes offset $__range`,
+			renamed:  2,
+		},
+		{
+			name:     "label values are not renamed",
+			query:    `up{metric="node_memory_MemFree"}`,
+			expected: `up{metric="node_memory_MemFree"}`,
+		},
+		{
+			name:     "__name__ matchers are not renamed",
+			query:    `{__name__="node_memory_MemFree"}`,
+			expected: `{__name__="node_memory_MemFree"}`,
+		},
+		{
+			name:     "fallback",
+			query:    `node_memory_MemFree > 0`,
+			fallback: true,
+			expected: `(node_memory_MemFree_bytes > 0) or (node_memory_MemFree > 0)`,
+			renamed:  1,
+		},
+		{
+			name:     "no fallback without renames",
+			query:    `up`,
+			fallback: true,
+			expected: `up`,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			query, n, err := renamer.RenameQuery(tc.query, tc.fallback)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, query)
+			assert.Equal(t, tc.renamed, n)
+		})
+	}
+
+	_, _, err := renamer.RenameQuery(`scalar(node_memory_MemFree)`, true)
+	assert.Error(t, err)
+}
+
+func TestRegexRenamer(t *testing.T) {
+	renamer, err := NewRegexRenamer(`node_memory_(.+)`, "node_memory_${1}_bytes")
+	require.NoError(t, err)
+
+	query, n, err := renamer.RenameQuery(`node_memory_MemFree / node_memory_MemTotal / up`, false)
+	require.NoError(t, err)
+	assert.Equal(t, `node_memory_MemFree_bytes / node_memory_MemTotal_bytes / up`, query)
+	assert.Equal(t, 2, n)
+
+	// The expression must match the whole name.
+	_, ok := renamer.Rename("my_node_memory_MemFree")
+	assert.False(t, ok)
+
+	renamer, err = NewRegexRenamer(`(.+)`, "invalid-$1")
+	require.NoError(t, err)
+	_, _, err = renamer.RenameQuery(`up`, false)
+	assert.Error(t, err)
+}
+
+func TestLoadMappingFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "mapping.yaml")
+	require.NoError(t, os.WriteFile(file, []byte("old_a: new_a\nold_b: new_b\n"), 0644))
+
+	renamer, err := LoadMappingFile(file)
+	require.NoError(t, err)
+
+	query, n, err := renamer.RenameQuery(`old_a + old_b + old_c`, false)
+	require.NoError(t, err)
+	assert.Equal(t, `new_a + new_b + old_c`, query)
+	assert.Equal(t, 2, n)
+}
+
+func TestRenameInRules(t *testing.T) {
+	ns := rules.RuleNamespace{Groups: []rwrulefmt.RuleGroup{{
+		RuleGroup: rulefmt.RuleGroup{
+			Name: "group",
+			Rules: []rulefmt.RuleNode{
+				{
+					Record: yaml.Node{Kind: yaml.ScalarNode, Value: "old_metric"},
+					Expr:   yaml.Node{Kind: yaml.ScalarNode, Value: "sum(up)"},
+				},
+				{
+					Alert: yaml.Node{Kind: yaml.ScalarNode, Value: "Alert"},
+					Expr:  yaml.Node{Kind: yaml.ScalarNode, Value: "old_metric > 0"},
+				},
+				{
+					Alert: yaml.Node{Kind: yaml.ScalarNode, Value: "Unchanged"},
+					Expr:  yaml.Node{Kind: yaml.ScalarNode, Value: "up == 0"},
+				},
+			},
+		},
+	}}}
+
+	mod, err := NewRenamer("old_metric", "new_metric").RenameInRules(&ns, false)
+	require.NoError(t, err)
+	assert.Equal(t, 2, mod)
+
+	rs := ns.Groups[0].Rules
+	assert.Equal(t, "new_metric", rs[0].Record.Value)
+	assert.Equal(t, "sum(up)", rs[0].Expr.Value)
+	assert.Equal(t, "new_metric > 0", rs[1].Expr.Value)
+	assert.Equal(t, "up == 0", rs[2].Expr.Value)
+}
+
+func TestRenameInDashboard(t *testing.T) {
+	dashboard := `{
+  "title": "Node",
+  "panels": [
+    {
+      "type": "graph",
+      "title": "Memory",
+      "targets": [
+        {"expr": "node_memory_MemFree{instance=\"$instance\"} > 0", "refId": "A"},
+        {"expr": "up", "refId": "B"}
+      ]
+    },
+    {
+      "type": "row",
+      "collapsed": true,
+      "panels": [
+        {
+          "type": "graph",
+          "targets": [{"expr": "rate(node_memory_MemFree[$__rate_interval])"}]
+        }
+      ]
+    }
+  ],
+  "templating": {
+    "list": [
+      {"name": "instance", "type": "query", "query": "label_values(node_memory_MemFree, instance)"},
+      {"name": "max", "type": "query", "query": "query_result(max(node_memory_MemFree))"}
+    ]
+  }
+}`
+
+	expected := `{
+  "title": "Node",
+  "panels": [
+    {
+      "type": "graph",
+      "title": "Memory",
+      "targets": [
+        {"expr": "(node_memory_MemFree_bytes{instance=\"$instance\"} > 0) or (node_memory_MemFree{instance=\"$instance\"} > 0)", "refId": "A"},
+        {"expr": "up", "refId": "B"}
+      ]
+    },
+    {
+      "type": "row",
+      "collapsed": true,
+      "panels": [
+        {
+          "type": "graph",
+          "targets": [{"expr": "(rate(node_memory_MemFree_bytes[$__rate_interval])) or (rate(node_memory_MemFree[$__rate_interval]))"}]
+        }
+      ]
+    }
+  ],
+  "templating": {
+    "list": [
+      {"name": "instance", "type": "query", "query": "label_values(node_memory_MemFree_bytes, instance)"},
+      {"name": "max", "type": "query", "query": "query_result((max(node_memory_MemFree_bytes)) or (max(node_memory_MemFree)))"}
+    ]
+  }
+}`
+
+	out, n, err := NewRenamer("node_memory_MemFree", "node_memory_MemFree_bytes").RenameInDashboard([]byte(dashboard), true)
+	require.NoError(t, err)
+	assert.Equal(t, 4, n)
+	assert.Equal(t, expected, string(out))
+}
+
+func TestReplaceDashboardQueries(t *testing.T) {
+	dashboard := `{"targets": [{"expr": "a"}, {"expr": "b"}, {"expr": "a \u003e 0"}], "title": "Overview"}`
+	queries := map[string]string{
+		"a":     "b",
+		"b":     "c",
+		"a > 0": "b > 0",
+	}
+
+	// Chained replacements are applied to the original queries only.
+	for i := 0; i < 10; i++ {
+		out, err := ReplaceDashboardQueries([]byte(dashboard), queries)
+		require.NoError(t, err)
+		assert.Equal(t, `{"targets": [{"expr": "b"}, {"expr": "c"}, {"expr": "b \u003e 0"}], "title": "Overview"}`, string(out))
+	}
+
+	_, err := ReplaceDashboardQueries([]byte(dashboard), map[string]string{"d": "e"})
+	assert.EqualError(t, err, `unable to find query "d" in the dashboard`)
+}
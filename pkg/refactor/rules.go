@@ -0,0 +1,56 @@
+package refactor
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/common/model"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/cortexproject/cortex-tools/pkg/rules"
+)
+
+// RenameInRules renames the metrics selected by the expressions of a rule
+// namespace, and the metrics recorded by its recording rules. It returns the
+// number of rules modified.
+func (r *Renamer) RenameInRules(ns *rules.RuleNamespace, fallback bool) (int, error) {
+	var mod int
+	for i, group := range ns.Groups {
+		for j := range group.Rules {
+			rule := &ns.Groups[i].Rules[j]
+			name := rule.Record.Value
+			if name == "" {
+				name = rule.Alert.Value
+			}
+
+			expr, n, err := r.RenameQuery(rule.Expr.Value, fallback)
+			if err != nil {
+				return mod, errors.Wrapf(err, "group %q, rule %q", group.Name, name)
+			}
+
+			record := rule.Record.Value
+			if to, ok := r.Rename(record); ok && record != "" {
+				if !model.IsValidMetricName(model.LabelValue(to)) {
+					return mod, fmt.Errorf("group %q, rule %q: invalid metric name %q", group.Name, name, to)
+				}
+				record = to
+			}
+
+			if n == 0 && record == rule.Record.Value {
+				continue
+			}
+
+			log.WithFields(log.Fields{
+				"group":       group.Name,
+				"rule":        name,
+				"currentExpr": rule.Expr.Value,
+				"afterExpr":   expr,
+			}).Debugf("renaming metrics")
+
+			mod++
+			rule.Expr.Value = expr
+			rule.Record.Value = record
+		}
+	}
+	return mod, nil
+}
@@ -0,0 +1,165 @@
+package rules
+
+import (
+	"sort"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/rulefmt"
+)
+
+// AlertFiring is a single firing of an alert for one label set.
+type AlertFiring struct {
+	Labels model.Metric `json:"labels"`
+	// ActiveAt is the first evaluation at which the expression returned the series.
+	ActiveAt time.Time `json:"activeAt"`
+	// FiredAt is the evaluation at which the alert transitioned from pending to firing.
+	FiredAt time.Time `json:"firedAt"`
+	// ResolvedAt is zero if the alert was still firing at the end of the backtest.
+	ResolvedAt time.Time `json:"resolvedAt,omitempty"`
+}
+
+// AlertBacktest holds the firings an alerting rule would have produced over a
+// time range.
+type AlertBacktest struct {
+	Alert   string        `json:"alert"`
+	Expr    string        `json:"expr"`
+	Start   time.Time     `json:"start"`
+	End     time.Time     `json:"end"`
+	Firings []AlertFiring `json:"firings"`
+}
+
+// Durations returns the sorted durations of all the firings. Firings that
+// were still active at the end of the backtest last until its end.
+func (b AlertBacktest) Durations() []time.Duration {
+	durations := make([]time.Duration, 0, len(b.Firings))
+	for _, f := range b.Firings {
+		resolved := f.ResolvedAt
+		if resolved.IsZero() {
+			resolved = b.End
+		}
+		durations = append(durations, resolved.Sub(f.FiredAt))
+	}
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+	return durations
+}
+
+// Series returns the number of distinct label sets that fired.
+func (b AlertBacktest) Series() int {
+	seen := map[model.Fingerprint]struct{}{}
+	for _, f := range b.Firings {
+		seen[f.Labels.Fingerprint()] = struct{}{}
+	}
+	return len(seen)
+}
+
+// Flapping returns the number of label sets that fired more than once.
+func (b AlertBacktest) Flapping() int {
+	firings := map[model.Fingerprint]int{}
+	for _, f := range b.Firings {
+		firings[f.Labels.Fingerprint()]++
+	}
+
+	var flapping int
+	for _, n := range firings {
+		if n > 1 {
+			flapping++
+		}
+	}
+	return flapping
+}
+
+// BacktestAlert replays the result of an alerting rule expression, evaluated as
+// a range query at every step between start and end, through the `for` and
+// `keep_firing_for` semantics of the Prometheus rule manager.
+func BacktestAlert(rule rulefmt.RuleNode, result model.Matrix, start, end time.Time, step time.Duration) AlertBacktest {
+	backtest := AlertBacktest{
+		Alert: rule.Alert.Value,
+		Expr:  rule.Expr.Value,
+		Start: start,
+		End:   end,
+	}
+
+	// Like the rule manager, the metric name is dropped from the alert labels
+	// and the labels of the rule are added, overriding the series labels.
+	// Series that only differ by these labels end up as the same alert.
+	// Templated rule labels are kept as written.
+	type series struct {
+		labels  model.Metric
+		present map[model.Time]struct{}
+	}
+	bySeries := map[model.Fingerprint]*series{}
+	var order []model.Fingerprint
+	for _, s := range result {
+		lbls := s.Metric.Clone()
+		delete(lbls, model.MetricNameLabel)
+		for name, value := range rule.Labels {
+			lbls[model.LabelName(name)] = model.LabelValue(value)
+		}
+
+		fp := lbls.Fingerprint()
+		ss, ok := bySeries[fp]
+		if !ok {
+			ss = &series{labels: lbls, present: map[model.Time]struct{}{}}
+			bySeries[fp] = ss
+			order = append(order, fp)
+		}
+		for _, v := range s.Values {
+			ss.present[v.Timestamp] = struct{}{}
+		}
+	}
+
+	holdDuration := time.Duration(rule.For)
+	keepFiringFor := time.Duration(rule.KeepFiringFor)
+
+	for _, fp := range order {
+		s := bySeries[fp]
+
+		var (
+			current         *AlertFiring
+			firing          bool
+			keepFiringSince time.Time
+		)
+		for ts := start; !ts.After(end); ts = ts.Add(step) {
+			_, present := s.present[model.TimeFromUnixNano(ts.UnixNano())]
+
+			if present {
+				if current == nil {
+					current = &AlertFiring{Labels: s.labels, ActiveAt: ts}
+				}
+				keepFiringSince = time.Time{}
+				if !firing && ts.Sub(current.ActiveAt) >= holdDuration {
+					firing = true
+					current.FiredAt = ts
+				}
+				continue
+			}
+
+			if current == nil {
+				continue
+			}
+
+			if firing && keepFiringFor > 0 {
+				if keepFiringSince.IsZero() {
+					keepFiringSince = ts
+				}
+				if ts.Sub(keepFiringSince) < keepFiringFor {
+					continue
+				}
+			}
+
+			// Pending alerts are dropped, firing alerts are resolved.
+			if firing {
+				current.ResolvedAt = ts
+				backtest.Firings = append(backtest.Firings, *current)
+			}
+			current, firing, keepFiringSince = nil, false, time.Time{}
+		}
+
+		if current != nil && firing {
+			backtest.Firings = append(backtest.Firings, *current)
+		}
+	}
+
+	return backtest
+}
@@ -0,0 +1,105 @@
+package rules
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/rulefmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	yaml "gopkg.in/yaml.v3"
+)
+
+func TestBacktestAlert(t *testing.T) {
+	start := time.Unix(0, 0).UTC()
+	step := time.Minute
+	end := start.Add(10 * step)
+
+	// samples returns a sample for each of the given steps.
+	samples := func(steps ...int) []model.SamplePair {
+		var pairs []model.SamplePair
+		for _, s := range steps {
+			pairs = append(pairs, model.SamplePair{
+				Timestamp: model.TimeFromUnixNano(start.Add(time.Duration(s) * step).UnixNano()),
+				Value:     1,
+			})
+		}
+		return pairs
+	}
+	at := func(s int) time.Time { return start.Add(time.Duration(s) * step) }
+
+	series := model.Metric{model.MetricNameLabel: "up", "job": "api"}
+	alertLabels := model.Metric{"job": "api"}
+
+	tt := []struct {
+		name     string
+		rule     rulefmt.RuleNode
+		result   model.Matrix
+		expected []AlertFiring
+		flapping int
+	}{
+		{
+			name: "without for the alert fires immediately",
+			rule: rulefmt.RuleNode{Alert: yaml.Node{Value: "Down"}},
+			result: model.Matrix{
+				{Metric: series, Values: samples(2, 3, 4)},
+			},
+			expected: []AlertFiring{
+				{Labels: alertLabels, ActiveAt: at(2), FiredAt: at(2), ResolvedAt: at(5)},
+			},
+		},
+		{
+			name: "pending alerts shorter than for never fire",
+			rule: rulefmt.RuleNode{Alert: yaml.Node{Value: "Down"}, For: model.Duration(3 * time.Minute)},
+			result: model.Matrix{
+				{Metric: series, Values: samples(1, 2, 3, 5, 6, 7, 8, 9, 10)},
+			},
+			expected: []AlertFiring{
+				{Labels: alertLabels, ActiveAt: at(5), FiredAt: at(8)},
+			},
+		},
+		{
+			name: "keep_firing_for bridges short gaps",
+			rule: rulefmt.RuleNode{Alert: yaml.Node{Value: "Down"}, KeepFiringFor: model.Duration(2 * time.Minute)},
+			result: model.Matrix{
+				{Metric: series, Values: samples(1, 2, 4, 5)},
+			},
+			expected: []AlertFiring{
+				{Labels: alertLabels, ActiveAt: at(1), FiredAt: at(1), ResolvedAt: at(8)},
+			},
+		},
+		{
+			name: "flapping alerts fire several times",
+			rule: rulefmt.RuleNode{Alert: yaml.Node{Value: "Down"}},
+			result: model.Matrix{
+				{Metric: series, Values: samples(1, 3, 5)},
+			},
+			expected: []AlertFiring{
+				{Labels: alertLabels, ActiveAt: at(1), FiredAt: at(1), ResolvedAt: at(2)},
+				{Labels: alertLabels, ActiveAt: at(3), FiredAt: at(3), ResolvedAt: at(4)},
+				{Labels: alertLabels, ActiveAt: at(5), FiredAt: at(5), ResolvedAt: at(6)},
+			},
+			flapping: 1,
+		},
+		{
+			name: "rule labels are added to the series labels",
+			rule: rulefmt.RuleNode{Alert: yaml.Node{Value: "Down"}, Labels: map[string]string{"severity": "page", "job": "api-server"}},
+			result: model.Matrix{
+				{Metric: model.Metric{model.MetricNameLabel: "up", "job": "api", "instance": "a"}, Values: samples(1, 2)},
+			},
+			expected: []AlertFiring{
+				{Labels: model.Metric{"job": "api-server", "instance": "a", "severity": "page"}, ActiveAt: at(1), FiredAt: at(1), ResolvedAt: at(3)},
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			b := BacktestAlert(tc.rule, tc.result, start, end, step)
+			require.Equal(t, tc.expected, b.Firings)
+			assert.Equal(t, tc.flapping, b.Flapping())
+			assert.Len(t, b.Durations(), len(tc.expected))
+		})
+	}
+}
@@ -0,0 +1,192 @@
+package rules
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql/parser"
+	log "github.com/sirupsen/logrus"
+)
+
+// SelectorProblem describes why a selector does not match any series.
+type SelectorProblem string
+
+const (
+	// MetricMissing denotes that no series exist for the metric name of the selector.
+	MetricMissing SelectorProblem = "metric_missing"
+	// LabelMatcherMismatch denotes that the metric exists but its label matchers never match.
+	LabelMatcherMismatch SelectorProblem = "label_matcher_never_matches"
+)
+
+// SeriesAPI is the subset of the Prometheus HTTP API used to look up series.
+type SeriesAPI interface {
+	LabelValues(ctx context.Context, label string, matches []string, startTime, endTime time.Time) (model.LabelValues, v1.Warnings, error)
+	Series(ctx context.Context, matches []string, startTime, endTime time.Time) ([]model.LabelSet, v1.Warnings, error)
+}
+
+// UnmatchedSelector is a selector of a rule that matches zero series.
+type UnmatchedSelector struct {
+	Namespace string          `json:"namespace"`
+	Group     string          `json:"group"`
+	Rule      string          `json:"rule"`
+	Selector  string          `json:"selector"`
+	Problem   SelectorProblem `json:"problem"`
+	// Matchers lists the label matchers that never match any series of the metric.
+	Matchers []string `json:"matchers,omitempty"`
+}
+
+// RuleSelectors returns the distinct vector selectors in a PromQL expression.
+// Offset and @ modifiers are dropped.
+func RuleSelectors(expr string) ([]*parser.VectorSelector, error) {
+	e, err := parser.ParseExpr(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	var selectors []*parser.VectorSelector
+	seen := map[string]struct{}{}
+	parser.Inspect(e, func(node parser.Node, _ []parser.Node) error {
+		if n, ok := node.(*parser.VectorSelector); ok {
+			vs := &parser.VectorSelector{Name: n.Name, LabelMatchers: n.LabelMatchers}
+			if _, ok := seen[vs.String()]; !ok {
+				seen[vs.String()] = struct{}{}
+				selectors = append(selectors, vs)
+			}
+		}
+		return nil
+	})
+
+	return selectors, nil
+}
+
+// CheckAgainstCluster looks up every selector of the rules in the namespaces and
+// returns those that matched no series between start and end. Selectors of
+// metrics recorded by the checked rules themselves are skipped.
+func CheckAgainstCluster(ctx context.Context, api SeriesAPI, namespaces []RuleNamespace, start, end time.Time) ([]UnmatchedSelector, error) {
+	metricNames, warnings, err := api.LabelValues(ctx, labels.MetricName, nil, start, end)
+	if err != nil {
+		return nil, fmt.Errorf("error querying metric names: %w", err)
+	}
+	logWarnings(warnings)
+
+	existing := make(map[string]struct{}, len(metricNames))
+	for _, name := range metricNames {
+		existing[string(name)] = struct{}{}
+	}
+
+	recorded := map[string]struct{}{}
+	for _, ns := range namespaces {
+		for _, g := range ns.Groups {
+			for _, rule := range g.Rules {
+				if rule.Record.Value != "" {
+					recorded[rule.Record.Value] = struct{}{}
+				}
+			}
+		}
+	}
+
+	// Cache lookups as the same selectors tend to be used by many rules.
+	matches := map[string]bool{}
+	seriesExist := func(selector string) (bool, error) {
+		if found, ok := matches[selector]; ok {
+			return found, nil
+		}
+		series, warnings, err := api.Series(ctx, []string{selector}, start, end)
+		if err != nil {
+			return false, fmt.Errorf("error querying series for %s: %w", selector, err)
+		}
+		logWarnings(warnings)
+
+		matches[selector] = len(series) > 0
+		return matches[selector], nil
+	}
+
+	var unmatched []UnmatchedSelector
+	for _, ns := range namespaces {
+		for _, g := range ns.Groups {
+			for _, rule := range g.Rules {
+				selectors, err := RuleSelectors(rule.Expr.Value)
+				if err != nil {
+					return nil, err
+				}
+
+				for _, vs := range selectors {
+					if _, ok := recorded[vs.Name]; ok {
+						log.WithFields(log.Fields{
+							"rule":     getRuleName(rule),
+							"selector": vs.String(),
+						}).Debugf("selector references a recording rule, skipping")
+						continue
+					}
+
+					result := UnmatchedSelector{
+						Namespace: ns.Namespace,
+						Group:     g.Name,
+						Rule:      getRuleName(rule),
+						Selector:  vs.String(),
+					}
+
+					if vs.Name != "" {
+						if _, ok := existing[vs.Name]; !ok {
+							result.Problem = MetricMissing
+							unmatched = append(unmatched, result)
+							continue
+						}
+					}
+
+					found, err := seriesExist(vs.String())
+					if err != nil {
+						return nil, err
+					}
+					if found {
+						continue
+					}
+
+					result.Problem = LabelMatcherMismatch
+					for _, m := range vs.LabelMatchers {
+						if m.Name == labels.MetricName {
+							continue
+						}
+
+						// Check each matcher on its own, scoped to the metric name if there is one.
+						single := &parser.VectorSelector{Name: vs.Name, LabelMatchers: []*labels.Matcher{m}}
+						if vs.Name != "" {
+							single.LabelMatchers = append(single.LabelMatchers, labels.MustNewMatcher(labels.MatchEqual, labels.MetricName, vs.Name))
+						}
+
+						found, err := seriesExist(single.String())
+						if err != nil {
+							return nil, err
+						}
+						if !found {
+							result.Matchers = append(result.Matchers, m.String())
+						}
+					}
+					unmatched = append(unmatched, result)
+				}
+			}
+		}
+	}
+
+	sort.SliceStable(unmatched, func(i, j int) bool {
+		a, b := unmatched[i], unmatched[j]
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		return a.Group < b.Group
+	})
+
+	return unmatched, nil
+}
+
+func logWarnings(warnings v1.Warnings) {
+	if len(warnings) > 0 {
+		log.Warnln(strings.Join(warnings, "; "))
+	}
+}
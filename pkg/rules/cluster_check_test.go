@@ -0,0 +1,120 @@
+package rules
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/rulefmt"
+	"github.com/prometheus/prometheus/promql/parser"
+	"github.com/stretchr/testify/require"
+	yaml "gopkg.in/yaml.v3"
+
+	"github.com/cortexproject/cortex-tools/pkg/rules/rwrulefmt"
+)
+
+// fakeSeriesAPI answers series lookups from a fixed set of series.
+type fakeSeriesAPI struct {
+	series []model.LabelSet
+}
+
+func (f *fakeSeriesAPI) LabelValues(_ context.Context, label string, _ []string, _, _ time.Time) (model.LabelValues, v1.Warnings, error) {
+	seen := map[model.LabelValue]struct{}{}
+	var values model.LabelValues
+	for _, s := range f.series {
+		if v, ok := s[model.LabelName(label)]; ok {
+			if _, ok := seen[v]; !ok {
+				seen[v] = struct{}{}
+				values = append(values, v)
+			}
+		}
+	}
+	return values, nil, nil
+}
+
+func (f *fakeSeriesAPI) Series(_ context.Context, matches []string, _, _ time.Time) ([]model.LabelSet, v1.Warnings, error) {
+	var result []model.LabelSet
+	for _, match := range matches {
+		matchers, err := parser.ParseMetricSelector(match)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		for _, s := range f.series {
+			lbls := labels.NewBuilder(labels.EmptyLabels())
+			for k, v := range s {
+				lbls.Set(string(k), string(v))
+			}
+			ls := lbls.Labels()
+
+			matched := true
+			for _, m := range matchers {
+				if !m.Matches(ls.Get(m.Name)) {
+					matched = false
+					break
+				}
+			}
+			if matched {
+				result = append(result, s)
+			}
+		}
+	}
+	return result, nil, nil
+}
+
+func TestCheckAgainstCluster(t *testing.T) {
+	api := &fakeSeriesAPI{series: []model.LabelSet{
+		{model.MetricNameLabel: "up", "job": "api", "instance": "a"},
+		{model.MetricNameLabel: "http_requests_total", "job": "api", "code": "200"},
+	}}
+
+	namespaces := []RuleNamespace{{
+		Namespace: "ns",
+		Groups: []rwrulefmt.RuleGroup{{
+			RuleGroup: rulefmt.RuleGroup{
+				Name: "group",
+				Rules: []rulefmt.RuleNode{
+					{Alert: yaml.Node{Value: "Down"}, Expr: yaml.Node{Value: `up{job="api"} == 0`}},
+					{Alert: yaml.Node{Value: "Renamed"}, Expr: yaml.Node{Value: `node_memory_MemFree > 0`}},
+					{Alert: yaml.Node{Value: "Mismatch"}, Expr: yaml.Node{Value: `rate(http_requests_total{job="web", code="200"}[5m]) > 0`}},
+					{Record: yaml.Node{Value: "job:up:sum"}, Expr: yaml.Node{Value: `sum by (job) (up)`}},
+					{Alert: yaml.Node{Value: "Recorded"}, Expr: yaml.Node{Value: `job:up:sum == 0`}},
+				},
+			},
+		}},
+	}}
+
+	unmatched, err := CheckAgainstCluster(context.Background(), api, namespaces, time.Now().Add(-time.Hour), time.Now())
+	require.NoError(t, err)
+	require.Equal(t, []UnmatchedSelector{
+		{
+			Namespace: "ns",
+			Group:     "group",
+			Rule:      "Renamed",
+			Selector:  "node_memory_MemFree",
+			Problem:   MetricMissing,
+		},
+		{
+			Namespace: "ns",
+			Group:     "group",
+			Rule:      "Mismatch",
+			Selector:  `http_requests_total{code="200",job="web"}`,
+			Problem:   LabelMatcherMismatch,
+			Matchers:  []string{`job="web"`},
+		},
+	}, unmatched)
+}
+
+func TestRuleSelectors(t *testing.T) {
+	selectors, err := RuleSelectors(`sum(rate(foo{a="b"}[5m] offset 1h)) / sum(rate(foo{a="b"}[5m])) > on() bar`)
+	require.NoError(t, err)
+
+	var got []string
+	for _, s := range selectors {
+		got = append(got, s.String())
+	}
+	require.Equal(t, []string{`foo{a="b"}`, "bar"}, got)
+}
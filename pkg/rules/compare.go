@@ -62,6 +62,47 @@ func SummarizeChanges(changes []NamespaceChange) (created, updated, deleted int)
 	return
 }
 
+// ApplyChanges returns the rule groups, keyed by namespace, that result from
+// executing a set of changes against the current ones. The current rule
+// groups are not modified.
+func ApplyChanges(current map[string][]rwrulefmt.RuleGroup, changes []NamespaceChange) map[string][]rwrulefmt.RuleGroup {
+	result := make(map[string][]rwrulefmt.RuleGroup, len(current))
+	for ns, groups := range current {
+		result[ns] = append([]rwrulefmt.RuleGroup(nil), groups...)
+	}
+
+	for _, change := range changes {
+		deleted := map[string]struct{}{}
+		for _, g := range change.GroupsDeleted {
+			deleted[g.Name] = struct{}{}
+		}
+		updated := map[string]rwrulefmt.RuleGroup{}
+		for _, g := range change.GroupsUpdated {
+			updated[g.New.Name] = g.New
+		}
+
+		var groups []rwrulefmt.RuleGroup
+		for _, g := range result[change.Namespace] {
+			if _, ok := deleted[g.Name]; ok {
+				continue
+			}
+			if u, ok := updated[g.Name]; ok {
+				g = u
+			}
+			groups = append(groups, g)
+		}
+		groups = append(groups, change.GroupsCreated...)
+
+		if len(groups) == 0 {
+			delete(result, change.Namespace)
+			continue
+		}
+		result[change.Namespace] = groups
+	}
+
+	return result
+}
+
 // UpdatedRuleGroup is used to store an change between a rule group
 type UpdatedRuleGroup struct {
 	New      rwrulefmt.RuleGroup
@@ -0,0 +1,132 @@
+package rules
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/rulefmt"
+	"github.com/prometheus/prometheus/promql/parser"
+)
+
+// RecordingRule is a recording rule, with the namespace and group it belongs
+// to.
+type RecordingRule struct {
+	Namespace string
+	Group     string
+	Interval  time.Duration
+	Rule      rulefmt.RuleNode
+
+	// Dependencies are the metrics recorded by other rules of the set that
+	// this rule selects.
+	Dependencies []string
+}
+
+// SortRecordingRules returns the recording rules of the namespaces in
+// dependency order: every rule comes after the rules recording the metrics it
+// selects. Otherwise, rules are kept in the order of the namespaces and of
+// their groups. It fails if rules depend on each other.
+func SortRecordingRules(nss []RuleNamespace) ([]RecordingRule, error) {
+	var recording []RecordingRule
+	producers := map[string][]int{}
+	for _, ns := range nss {
+		for _, group := range ns.Groups {
+			for _, rule := range group.Rules {
+				if rule.Record.Value == "" {
+					continue
+				}
+				producers[rule.Record.Value] = append(producers[rule.Record.Value], len(recording))
+				recording = append(recording, RecordingRule{
+					Namespace: ns.Namespace,
+					Group:     group.Name,
+					Interval:  time.Duration(group.Interval),
+					Rule:      rule,
+				})
+			}
+		}
+	}
+
+	// dependents lists the rules selecting the metric recorded by each rule.
+	dependents := make([][]int, len(recording))
+	pending := make([]int, len(recording))
+	for i, rr := range recording {
+		metrics, err := selectedMetrics(rr.Rule.Expr.Value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", rr.Rule.Record.Value, err)
+		}
+		for _, metric := range metrics {
+			if _, ok := producers[metric]; !ok {
+				continue
+			}
+			recording[i].Dependencies = append(recording[i].Dependencies, metric)
+			for _, p := range producers[metric] {
+				dependents[p] = append(dependents[p], i)
+				pending[i]++
+			}
+		}
+	}
+
+	sorted := make([]RecordingRule, 0, len(recording))
+	done := make([]bool, len(recording))
+	for len(sorted) < len(recording) {
+		progress := false
+		for i := range recording {
+			if done[i] || pending[i] > 0 {
+				continue
+			}
+			done[i] = true
+			progress = true
+			sorted = append(sorted, recording[i])
+			for _, d := range dependents[i] {
+				pending[d]--
+			}
+		}
+
+		if !progress {
+			var cycle []string
+			for i, rr := range recording {
+				if !done[i] {
+					cycle = append(cycle, rr.Rule.Record.Value)
+				}
+			}
+			return nil, fmt.Errorf("recording rules depend on each other: %s", strings.Join(cycle, ", "))
+		}
+	}
+	return sorted, nil
+}
+
+// selectedMetrics returns the sorted names of the metrics selected by an
+// expression.
+func selectedMetrics(expr string) ([]string, error) {
+	e, err := parser.ParseExpr(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	set := map[string]struct{}{}
+	parser.Inspect(e, func(node parser.Node, _ []parser.Node) error {
+		vs, ok := node.(*parser.VectorSelector)
+		if !ok {
+			return nil
+		}
+		if vs.Name != "" {
+			set[vs.Name] = struct{}{}
+			return nil
+		}
+		for _, m := range vs.LabelMatchers {
+			if m.Name == labels.MetricName && m.Type == labels.MatchEqual {
+				set[m.Value] = struct{}{}
+			}
+		}
+		return nil
+	})
+
+	metrics := make([]string, 0, len(set))
+	for m := range set {
+		metrics = append(metrics, m)
+	}
+	sort.Strings(metrics)
+	return metrics, nil
+}
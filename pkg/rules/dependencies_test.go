@@ -0,0 +1,60 @@
+package rules
+
+import (
+	"testing"
+
+	"github.com/prometheus/prometheus/model/rulefmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	yaml "gopkg.in/yaml.v3"
+
+	"github.com/cortexproject/cortex-tools/pkg/rules/rwrulefmt"
+)
+
+func recordingRuleNamespace(name string, rules ...[2]string) RuleNamespace {
+	group := rwrulefmt.RuleGroup{RuleGroup: rulefmt.RuleGroup{Name: "group"}}
+	for _, r := range rules {
+		group.Rules = append(group.Rules, rulefmt.RuleNode{
+			Record: yaml.Node{Kind: yaml.ScalarNode, Value: r[0]},
+			Expr:   yaml.Node{Kind: yaml.ScalarNode, Value: r[1]},
+		})
+	}
+	return RuleNamespace{Namespace: name, Groups: []rwrulefmt.RuleGroup{group}}
+}
+
+func TestSortRecordingRules(t *testing.T) {
+	nss := []RuleNamespace{
+		recordingRuleNamespace("a",
+			[2]string{"job:errors:ratio", "job:errors:rate5m / job:requests:rate5m"},
+			[2]string{"job:errors:rate5m", `sum by (job) (rate(requests_total{code="500"}[5m]))`},
+		),
+		recordingRuleNamespace("b",
+			[2]string{"job:requests:rate5m", "sum by (job) (rate(requests_total[5m]))"},
+			[2]string{"job:slo", `{__name__="job:errors:ratio"} > 0.01`},
+		),
+	}
+
+	sorted, err := SortRecordingRules(nss)
+	require.NoError(t, err)
+
+	var order []string
+	for _, r := range sorted {
+		order = append(order, r.Rule.Record.Value)
+	}
+	assert.Equal(t, []string{"job:errors:rate5m", "job:requests:rate5m", "job:errors:ratio", "job:slo"}, order)
+	assert.Empty(t, sorted[0].Dependencies)
+	assert.Equal(t, []string{"job:errors:rate5m", "job:requests:rate5m"}, sorted[2].Dependencies)
+	assert.Equal(t, "a", sorted[2].Namespace)
+	assert.Equal(t, []string{"job:errors:ratio"}, sorted[3].Dependencies)
+}
+
+func TestSortRecordingRulesCycle(t *testing.T) {
+	_, err := SortRecordingRules([]RuleNamespace{
+		recordingRuleNamespace("a",
+			[2]string{"a:b:c", "d:e:f"},
+			[2]string{"d:e:f", "a:b:c"},
+			[2]string{"g:h:i", "up"},
+		),
+	})
+	assert.EqualError(t, err, "recording rules depend on each other: a:b:c, d:e:f")
+}
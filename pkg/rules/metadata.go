@@ -0,0 +1,204 @@
+package rules
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/prometheus/promql/parser"
+	log "github.com/sirupsen/logrus"
+)
+
+// MetricMetadata maps metric names to their metadata, as returned by the
+// /api/v1/metadata endpoint.
+type MetricMetadata map[string][]v1.Metadata
+
+// LoadMetricMetadata reads a metadata dump from a file. Both the raw response
+// of the /api/v1/metadata endpoint and its `data` field alone are accepted.
+func LoadMetricMetadata(path string) (MetricMetadata, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var response struct {
+		Status string         `json:"status"`
+		Data   MetricMetadata `json:"data"`
+	}
+	if err := json.Unmarshal(content, &response); err == nil && response.Status != "" {
+		return response.Data, nil
+	}
+
+	md := MetricMetadata{}
+	if err := json.Unmarshal(content, &md); err != nil {
+		return nil, fmt.Errorf("unable to parse metadata file %s: %w", path, err)
+	}
+	return md, nil
+}
+
+// Type returns the type of a metric. The series of histograms and summaries,
+// as well as counters exposed without their `_total` suffix, are resolved
+// through the metadata of their metric family.
+func (m MetricMetadata) Type(name string) v1.MetricType {
+	if t := m.familyType(name); t != "" {
+		return t
+	}
+
+	if base := strings.TrimSuffix(name, "_total"); base != name && m.familyType(base) == v1.MetricTypeCounter {
+		return v1.MetricTypeCounter
+	}
+
+	for _, suffix := range []string{"_bucket", "_sum", "_count"} {
+		base := strings.TrimSuffix(name, suffix)
+		if base == name {
+			continue
+		}
+
+		switch m.familyType(base) {
+		case v1.MetricTypeHistogram, v1.MetricTypeSummary:
+			// The series of classic histograms and summaries are counters.
+			return v1.MetricTypeCounter
+		case v1.MetricTypeGaugeHistogram:
+			return v1.MetricTypeGauge
+		}
+	}
+
+	return v1.MetricTypeUnknown
+}
+
+func (m MetricMetadata) familyType(name string) v1.MetricType {
+	for _, md := range m[name] {
+		if md.Type != "" && md.Type != v1.MetricTypeUnknown {
+			return md.Type
+		}
+	}
+	return ""
+}
+
+// CheckMetricTypes checks the expressions of every rule for functions and
+// aggregations applied to metrics of the wrong type, based on their metadata.
+// Returns the number of issues found.
+func (r RuleNamespace) CheckMetricTypes(md MetricMetadata) (int, error) {
+	var count int
+	for _, group := range r.Groups {
+		for _, rule := range group.Rules {
+			log.WithFields(log.Fields{"rule": getRuleName(rule)}).Debugf("checking metric types")
+			issues, err := MetricTypeIssues(rule.Expr.Value, md)
+			if err != nil {
+				return count, err
+			}
+
+			for _, issue := range issues {
+				count++
+				log.WithFields(log.Fields{
+					"rule":      getRuleName(rule),
+					"ruleGroup": group.Name,
+					"file":      r.Filepath,
+					"error":     issue,
+				}).Errorf("metric type misuse")
+			}
+		}
+	}
+	return count, nil
+}
+
+// MetricTypeIssues returns a description of each misuse of a metric type in a
+// PromQL expression:
+//   - rate, irate or increase of a gauge
+//   - delta of a counter
+//   - histogram_quantile of series that are not histogram buckets
+//   - sum of counters without taking their rate first
+func MetricTypeIssues(expr string, md MetricMetadata) ([]string, error) {
+	e, err := parser.ParseExpr(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	var issues []string
+	parser.Inspect(e, func(node parser.Node, _ []parser.Node) error {
+		switch n := node.(type) {
+		case *parser.Call:
+			switch n.Func.Name {
+			case "rate", "irate", "increase":
+				for _, vs := range rangeSelectors(n.Args[0]) {
+					if md.Type(vs.Name) == v1.MetricTypeGauge {
+						issues = append(issues, fmt.Sprintf("%s() applied to gauge %s", n.Func.Name, vs.Name))
+					}
+				}
+			case "delta":
+				for _, vs := range rangeSelectors(n.Args[0]) {
+					if md.Type(vs.Name) == v1.MetricTypeCounter {
+						issues = append(issues, fmt.Sprintf("delta() applied to counter %s, use increase() instead", vs.Name))
+					}
+				}
+			case "histogram_quantile":
+				parser.Inspect(n.Args[1], func(node parser.Node, _ []parser.Node) error {
+					if vs, ok := node.(*parser.VectorSelector); ok && !isHistogramSeries(vs.Name, md) {
+						issues = append(issues, fmt.Sprintf("histogram_quantile() applied to %s, which is not a histogram bucket series", vs.Name))
+					}
+					return nil
+				})
+			}
+		case *parser.AggregateExpr:
+			if n.Op != parser.SUM {
+				return nil
+			}
+			for _, vs := range unwrappedSelectors(n.Expr) {
+				if md.Type(vs.Name) == v1.MetricTypeCounter {
+					issues = append(issues, fmt.Sprintf("sum() of counter %s without rate() or increase()", vs.Name))
+				}
+			}
+		}
+		return nil
+	})
+
+	return issues, nil
+}
+
+// rangeSelectors returns the selectors of the range vector argument of a function.
+func rangeSelectors(node parser.Node) []*parser.VectorSelector {
+	switch n := node.(type) {
+	case *parser.MatrixSelector:
+		if vs, ok := n.VectorSelector.(*parser.VectorSelector); ok {
+			return []*parser.VectorSelector{vs}
+		}
+	case *parser.SubqueryExpr:
+		return rangeSelectors(n.Expr)
+	case *parser.ParenExpr:
+		return rangeSelectors(n.Expr)
+	case *parser.VectorSelector:
+		return []*parser.VectorSelector{n}
+	}
+	return nil
+}
+
+// unwrappedSelectors returns the selectors of an expression that are not
+// wrapped in a function call or another aggregation.
+func unwrappedSelectors(node parser.Node) []*parser.VectorSelector {
+	switch n := node.(type) {
+	case *parser.VectorSelector:
+		return []*parser.VectorSelector{n}
+	case *parser.ParenExpr:
+		return unwrappedSelectors(n.Expr)
+	case *parser.UnaryExpr:
+		return unwrappedSelectors(n.Expr)
+	case *parser.StepInvariantExpr:
+		return unwrappedSelectors(n.Expr)
+	case *parser.BinaryExpr:
+		return append(unwrappedSelectors(n.LHS), unwrappedSelectors(n.RHS)...)
+	}
+	return nil
+}
+
+// isHistogramSeries returns whether a metric holds histogram buckets. Classic
+// histograms are identified by the `_bucket` suffix and native histograms by
+// their metadata. Selectors without a metric name and recorded series, which
+// have no metadata, are given the benefit of the doubt.
+func isHistogramSeries(name string, md MetricMetadata) bool {
+	if name == "" || strings.HasSuffix(name, "_bucket") || strings.Contains(name, ":") {
+		return true
+	}
+	return md.familyType(name) == v1.MetricTypeHistogram
+}
@@ -0,0 +1,94 @@
+package rules
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetricTypeIssues(t *testing.T) {
+	md := MetricMetadata{
+		"node_memory_MemFree_bytes":     {{Type: v1.MetricTypeGauge}},
+		"http_requests_total":           {{Type: v1.MetricTypeCounter}},
+		"process_cpu_seconds":           {{Type: v1.MetricTypeCounter}},
+		"request_duration_seconds":      {{Type: v1.MetricTypeHistogram}},
+		"native_request_duration":       {{Type: v1.MetricTypeHistogram}},
+		"rpc_duration_seconds":          {{Type: v1.MetricTypeSummary}},
+		"apiserver_storage_objects":     {{Type: v1.MetricTypeGauge}},
+		"unknown_metric_without_a_type": {{Type: v1.MetricTypeUnknown}},
+	}
+
+	tt := []struct {
+		name     string
+		expr     string
+		expected []string
+	}{
+		{
+			name: "correct usage",
+			expr: `sum(rate(http_requests_total[5m])) / sum(node_memory_MemFree_bytes) + histogram_quantile(0.9, sum by (le) (rate(request_duration_seconds_bucket[5m])))`,
+		},
+		{
+			name:     "rate of a gauge",
+			expr:     `rate(node_memory_MemFree_bytes[5m])`,
+			expected: []string{"rate() applied to gauge node_memory_MemFree_bytes"},
+		},
+		{
+			name:     "increase of a gauge in a subquery",
+			expr:     `increase(apiserver_storage_objects[1h:5m])`,
+			expected: []string{"increase() applied to gauge apiserver_storage_objects"},
+		},
+		{
+			name:     "delta of a counter exposed without its suffix",
+			expr:     `delta(process_cpu_seconds_total[5m])`,
+			expected: []string{"delta() applied to counter process_cpu_seconds_total, use increase() instead"},
+		},
+		{
+			name:     "histogram_quantile of a summary",
+			expr:     `histogram_quantile(0.9, rate(rpc_duration_seconds_count[5m]))`,
+			expected: []string{"histogram_quantile() applied to rpc_duration_seconds_count, which is not a histogram bucket series"},
+		},
+		{
+			name: "histogram_quantile of a native histogram or a recorded series",
+			expr: `histogram_quantile(0.9, rate(native_request_duration[5m])) or histogram_quantile(0.9, job:request_duration_seconds:rate5m)`,
+		},
+		{
+			name:     "sum of counters without rate",
+			expr:     `sum by (job) (http_requests_total) / sum(rate(http_requests_total[5m]))`,
+			expected: []string{"sum() of counter http_requests_total without rate() or increase()"},
+		},
+		{
+			name: "unknown metrics are not checked",
+			expr: `sum(unknown_metric_without_a_type) + rate(not_in_metadata[5m])`,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			issues, err := MetricTypeIssues(tc.expr, md)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, issues)
+		})
+	}
+}
+
+func TestLoadMetricMetadata(t *testing.T) {
+	dir := t.TempDir()
+
+	for name, content := range map[string]string{
+		"response.json": `{"status":"success","data":{"up":[{"type":"gauge","help":"","unit":""}]}}`,
+		"data.json":     `{"up":[{"type":"gauge","help":"","unit":""}]}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(dir, name)
+			require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+
+			md, err := LoadMetricMetadata(path)
+			require.NoError(t, err)
+			assert.Equal(t, v1.MetricTypeGauge, md.Type("up"))
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package rules
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/rulefmt"
+	yaml "gopkg.in/yaml.v3"
+
+	"github.com/cortexproject/cortex-tools/pkg/rules/rwrulefmt"
+)
+
+const (
+	// OwnerGroupName is the name of the marker group recording which sync
+	// owns a namespace.
+	OwnerGroupName = "cortextool_owner"
+	// OwnerLabel is the label of the marker rule holding the owner ID.
+	OwnerLabel = "owner"
+
+	ownerRecord   = "cortextool_namespace_owner"
+	ownerInterval = time.Hour
+)
+
+// OwnerGroup returns the marker group recording the owner of a namespace. It
+// holds a single recording rule, evaluated infrequently, as rule groups have
+// no labels of their own.
+func OwnerGroup(owner string) rwrulefmt.RuleGroup {
+	return rwrulefmt.RuleGroup{
+		RuleGroup: rulefmt.RuleGroup{
+			Name:     OwnerGroupName,
+			Interval: model.Duration(ownerInterval),
+			Rules: []rulefmt.RuleNode{{
+				Record: yaml.Node{Kind: yaml.ScalarNode, Value: ownerRecord},
+				Expr:   yaml.Node{Kind: yaml.ScalarNode, Value: "vector(1)"},
+				Labels: map[string]string{OwnerLabel: owner},
+			}},
+		},
+	}
+}
+
+// NamespaceOwner returns the owner recorded in the groups of a namespace, or
+// an empty string if the namespace has no owner.
+func NamespaceOwner(groups []rwrulefmt.RuleGroup) string {
+	for _, g := range groups {
+		if g.Name != OwnerGroupName {
+			continue
+		}
+		for _, rule := range g.Rules {
+			if rule.Record.Value == ownerRecord {
+				return rule.Labels[OwnerLabel]
+			}
+		}
+	}
+	return ""
+}
+
+// SetOwner records the owner of the namespace, replacing any previous owner.
+func (r *RuleNamespace) SetOwner(owner string) {
+	for i, g := range r.Groups {
+		if g.Name == OwnerGroupName {
+			r.Groups[i] = OwnerGroup(owner)
+			return
+		}
+	}
+	r.Groups = append(r.Groups, OwnerGroup(owner))
+}
+
+// CheckOwnership returns an error if a sync with the given owner ID must not
+// change a namespace, based on its current groups. A sync may update the
+// namespaces it owns or that have no owner yet, taking ownership of them, but
+// only deletes the namespaces it owns. A sync without an owner ID may change
+// any namespace.
+func CheckOwnership(owner string, current []rwrulefmt.RuleGroup, deleting bool) error {
+	if owner == "" {
+		return nil
+	}
+
+	currentOwner := NamespaceOwner(current)
+	switch {
+	case currentOwner == owner:
+		return nil
+	case currentOwner != "":
+		return fmt.Errorf("namespace is owned by %q", currentOwner)
+	case deleting:
+		return fmt.Errorf("namespace has no owner")
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package rules
+
+import (
+	"testing"
+
+	"github.com/prometheus/prometheus/model/rulefmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	yaml "gopkg.in/yaml.v3"
+
+	"github.com/cortexproject/cortex-tools/pkg/rules/rwrulefmt"
+)
+
+func TestSetOwner(t *testing.T) {
+	ns := RuleNamespace{
+		Namespace: "example",
+		Groups: []rwrulefmt.RuleGroup{
+			{RuleGroup: rulefmt.RuleGroup{Name: "group"}},
+		},
+	}
+	assert.Equal(t, "", NamespaceOwner(ns.Groups))
+
+	ns.SetOwner("team-a")
+	require.Len(t, ns.Groups, 2)
+	assert.Equal(t, "team-a", NamespaceOwner(ns.Groups))
+
+	ns.SetOwner("team-b")
+	require.Len(t, ns.Groups, 2)
+	assert.Equal(t, "team-b", NamespaceOwner(ns.Groups))
+
+	// The marker group survives a round trip through the ruler API.
+	out, err := yaml.Marshal(ns.Groups)
+	require.NoError(t, err)
+	var groups []rwrulefmt.RuleGroup
+	require.NoError(t, yaml.Unmarshal(out, &groups))
+	assert.Equal(t, "team-b", NamespaceOwner(groups))
+	assert.NoError(t, CompareGroups(groups[1], OwnerGroup("team-b")))
+}
+
+func TestCheckOwnership(t *testing.T) {
+	unowned := []rwrulefmt.RuleGroup{{RuleGroup: rulefmt.RuleGroup{Name: "group"}}}
+	ownedByA := append(unowned, OwnerGroup("team-a"))
+
+	tt := []struct {
+		name     string
+		owner    string
+		current  []rwrulefmt.RuleGroup
+		deleting bool
+		err      string
+	}{
+		{name: "no owner ID updates unowned namespaces", current: unowned},
+		{name: "no owner ID deletes owned namespaces", current: ownedByA, deleting: true},
+		{name: "updates own namespace", owner: "team-a", current: ownedByA},
+		{name: "deletes own namespace", owner: "team-a", current: ownedByA, deleting: true},
+		{name: "takes ownership of unowned namespaces", owner: "team-a", current: unowned},
+		{name: "does not delete unowned namespaces", owner: "team-a", current: unowned, deleting: true, err: "namespace has no owner"},
+		{name: "does not update namespaces of others", owner: "team-b", current: ownedByA, err: `namespace is owned by "team-a"`},
+		{name: "does not delete namespaces of others", owner: "team-b", current: ownedByA, deleting: true, err: `namespace is owned by "team-a"`},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			err := CheckOwnership(tc.owner, tc.current, tc.deleting)
+			if tc.err == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.EqualError(t, err, tc.err)
+		})
+	}
+}
@@ -0,0 +1,225 @@
+package rules
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/promql/parser"
+	yaml "gopkg.in/yaml.v3"
+
+	"github.com/cortexproject/cortex-tools/pkg/util"
+)
+
+// PolicyFile holds the conventions rules have to follow, scoped by namespace.
+type PolicyFile struct {
+	Policies []Policy `yaml:"policies"`
+}
+
+// Policy is a set of conventions for the rules of the namespaces matching
+// any of its globs. All the policies matching a namespace are applied.
+type Policy struct {
+	// Namespaces are globs, as supported by path.Match, of the namespaces the policy applies to.
+	Namespaces []string `yaml:"namespaces"`
+	// RequiredLabels must be set on every alerting rule. If allowed values are
+	// listed, the label must have one of them.
+	RequiredLabels map[string][]string `yaml:"required_labels,omitempty"`
+	// RequiredAnnotations must be set on every alerting rule and fully match the regex.
+	RequiredAnnotations map[string]string `yaml:"required_annotations,omitempty"`
+	// ForbiddenFunctions cannot be used in any rule expression.
+	ForbiddenFunctions []string `yaml:"forbidden_functions,omitempty"`
+	// MaxFor is the maximum `for` duration of alerting rules.
+	MaxFor model.Duration `yaml:"max_for,omitempty"`
+	// MaxGroupSize is the maximum number of rules in a group.
+	MaxGroupSize int `yaml:"max_group_size,omitempty"`
+
+	annotationRegexps map[string]*regexp.Regexp
+}
+
+// PolicyViolation is a rule or rule group not following a policy.
+type PolicyViolation struct {
+	Namespace string `json:"namespace"`
+	Group     string `json:"group"`
+	Rule      string `json:"rule,omitempty"`
+	Message   string `json:"message"`
+}
+
+func (v PolicyViolation) String() string {
+	if v.Rule == "" {
+		return fmt.Sprintf("namespace: %s, group: %s: %s", v.Namespace, v.Group, v.Message)
+	}
+	return fmt.Sprintf("namespace: %s, group: %s, rule: %s: %s", v.Namespace, v.Group, v.Rule, v.Message)
+}
+
+// LoadPolicyFile reads and validates a policy file.
+func LoadPolicyFile(filename string) (*PolicyFile, error) {
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	decoder.KnownFields(true)
+
+	pf := &PolicyFile{}
+	if err := decoder.Decode(pf); err != nil {
+		return nil, fmt.Errorf("unable to parse policy file %s: %w", filename, err)
+	}
+
+	for i := range pf.Policies {
+		if err := pf.Policies[i].compile(); err != nil {
+			return nil, fmt.Errorf("invalid policy #%d in %s: %w", i, filename, err)
+		}
+	}
+
+	return pf, nil
+}
+
+func (p *Policy) compile() error {
+	if len(p.Namespaces) == 0 {
+		return fmt.Errorf("at least one namespace glob is required")
+	}
+	for _, glob := range p.Namespaces {
+		if _, err := path.Match(glob, ""); err != nil {
+			return fmt.Errorf("invalid namespace glob %q: %w", glob, err)
+		}
+	}
+
+	for _, fn := range p.ForbiddenFunctions {
+		if _, ok := parser.Functions[fn]; !ok {
+			return fmt.Errorf("unknown function %q", fn)
+		}
+	}
+
+	p.annotationRegexps = make(map[string]*regexp.Regexp, len(p.RequiredAnnotations))
+	for name, expr := range p.RequiredAnnotations {
+		re, err := regexp.Compile("^(?:" + expr + ")$")
+		if err != nil {
+			return fmt.Errorf("invalid regex for annotation %q: %w", name, err)
+		}
+		p.annotationRegexps[name] = re
+	}
+
+	return nil
+}
+
+// matches returns whether the policy applies to a namespace.
+func (p *Policy) matches(namespace string) bool {
+	for _, glob := range p.Namespaces {
+		if ok, _ := path.Match(glob, namespace); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Evaluate returns the violations of the policies by the rules in a namespace.
+func (pf *PolicyFile) Evaluate(ns RuleNamespace) ([]PolicyViolation, error) {
+	var violations []PolicyViolation
+	for i := range pf.Policies {
+		p := &pf.Policies[i]
+		if !p.matches(ns.Namespace) {
+			continue
+		}
+
+		for _, g := range ns.Groups {
+			if p.MaxGroupSize > 0 && len(g.Rules) > p.MaxGroupSize {
+				violations = append(violations, PolicyViolation{
+					Namespace: ns.Namespace,
+					Group:     g.Name,
+					Message:   fmt.Sprintf("group has %d rules, more than the maximum of %d", len(g.Rules), p.MaxGroupSize),
+				})
+			}
+
+			for _, rule := range g.Rules {
+				messages, err := p.evaluateRule(rule.Alert.Value != "", rule.Expr.Value, time.Duration(rule.For), rule.Labels, rule.Annotations)
+				if err != nil {
+					return nil, err
+				}
+				for _, msg := range messages {
+					violations = append(violations, PolicyViolation{
+						Namespace: ns.Namespace,
+						Group:     g.Name,
+						Rule:      getRuleName(rule),
+						Message:   msg,
+					})
+				}
+			}
+		}
+	}
+
+	return violations, nil
+}
+
+func (p *Policy) evaluateRule(alerting bool, expr string, holdDuration time.Duration, lbls, annotations map[string]string) ([]string, error) {
+	var messages []string
+
+	if len(p.ForbiddenFunctions) > 0 {
+		e, err := parser.ParseExpr(expr)
+		if err != nil {
+			return nil, err
+		}
+
+		used := map[string]struct{}{}
+		parser.Inspect(e, func(node parser.Node, _ []parser.Node) error {
+			if call, ok := node.(*parser.Call); ok {
+				used[call.Func.Name] = struct{}{}
+			}
+			return nil
+		})
+		for _, fn := range p.ForbiddenFunctions {
+			if _, ok := used[fn]; ok {
+				messages = append(messages, fmt.Sprintf("function %s() is forbidden", fn))
+			}
+		}
+	}
+
+	// The remaining conventions only apply to alerting rules.
+	if !alerting {
+		return messages, nil
+	}
+
+	if p.MaxFor > 0 && holdDuration > time.Duration(p.MaxFor) {
+		messages = append(messages, fmt.Sprintf("for duration %s exceeds the maximum of %s", model.Duration(holdDuration), p.MaxFor))
+	}
+
+	for _, name := range util.SortedKeys(p.RequiredLabels) {
+		value, ok := lbls[name]
+		if !ok {
+			messages = append(messages, fmt.Sprintf("required label %q is missing", name))
+			continue
+		}
+
+		allowed := p.RequiredLabels[name]
+		if len(allowed) == 0 {
+			continue
+		}
+		var found bool
+		for _, a := range allowed {
+			if a == value {
+				found = true
+				break
+			}
+		}
+		if !found {
+			messages = append(messages, fmt.Sprintf("label %q has value %q, allowed values are %v", name, value, allowed))
+		}
+	}
+
+	for _, name := range util.SortedKeys(p.annotationRegexps) {
+		value, ok := annotations[name]
+		if !ok {
+			messages = append(messages, fmt.Sprintf("required annotation %q is missing", name))
+			continue
+		}
+		if !p.annotationRegexps[name].MatchString(value) {
+			messages = append(messages, fmt.Sprintf("annotation %q does not match %q", name, p.RequiredAnnotations[name]))
+		}
+	}
+
+	return messages, nil
+}
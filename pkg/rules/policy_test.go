@@ -0,0 +1,55 @@
+package rules
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPolicyFileEvaluate(t *testing.T) {
+	pf, err := LoadPolicyFile("testdata/policy.yaml")
+	require.NoError(t, err)
+
+	nss, errs := Parse("testdata/policy_namespace.yaml")
+	require.Empty(t, errs)
+	require.Len(t, nss, 1)
+
+	violations, err := pf.Evaluate(nss[0])
+	require.NoError(t, err)
+
+	var messages []string
+	for _, v := range violations {
+		messages = append(messages, v.String())
+	}
+	require.Equal(t, []string{
+		"namespace: team-a-api, group: api: group has 3 rules, more than the maximum of 2",
+		"namespace: team-a-api, group: api, rule: APIFlapping: for duration 2h exceeds the maximum of 1h",
+		"namespace: team-a-api, group: api, rule: APIFlapping: required label \"severity\" is missing",
+		"namespace: team-a-api, group: api, rule: APIFlapping: label \"team\" has value \"b\", allowed values are [a]",
+		"namespace: team-a-api, group: api, rule: APIFlapping: annotation \"runbook_url\" does not match \"https://runbooks.example.com/.+\"",
+		"namespace: team-a-api, group: api, rule: job:up:holt_winters: function holt_winters() is forbidden",
+		"namespace: team-a-api, group: api, rule: APIFlapping: function absent_over_time() is forbidden",
+	}, messages)
+
+	// Policies are scoped to the namespaces matching their globs.
+	nss[0].Namespace = "team-b-api"
+	violations, err = pf.Evaluate(nss[0])
+	require.NoError(t, err)
+	require.Len(t, violations, 1)
+}
+
+func TestLoadPolicyFileErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		policy Policy
+	}{
+		{name: "no namespaces", policy: Policy{}},
+		{name: "bad glob", policy: Policy{Namespaces: []string{"["}}},
+		{name: "unknown function", policy: Policy{Namespaces: []string{"*"}, ForbiddenFunctions: []string{"nope"}}},
+		{name: "bad regex", policy: Policy{Namespaces: []string{"*"}, RequiredAnnotations: map[string]string{"summary": "("}}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Error(t, tc.policy.compile())
+		})
+	}
+}
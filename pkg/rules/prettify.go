@@ -0,0 +1,194 @@
+package rules
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/prometheus/prometheus/promql/parser"
+	log "github.com/sirupsen/logrus"
+	yaml "gopkg.in/yaml.v3"
+)
+
+// DefaultPrettyWidth is the line width of the Prometheus pretty-printer.
+const DefaultPrettyWidth = 100
+
+// PrettifyExpr formats a PromQL expression like the Prometheus pretty-printer.
+// Expressions, and sub-expressions, longer than width are split over several
+// lines.
+func PrettifyExpr(expr string, width int) (string, error) {
+	e, err := parser.ParseExpr(expr)
+	if err != nil {
+		return "", err
+	}
+
+	return prettifier{width: width}.pretty(e, 0), nil
+}
+
+// Taken from https://github.com/prometheus/prometheus/blob/d81e41d58ec8/promql/parser/prettier.go
+// The pretty-printer of Prometheus has a fixed line width.
+
+type prettifier struct {
+	width int
+}
+
+func (p prettifier) pretty(n parser.Node, level int) string {
+	s := indent(level)
+	switch e := n.(type) {
+	case *parser.AggregateExpr:
+		if !p.needsSplit(e) {
+			return s + e.String()
+		}
+		s += aggregationOp(e) + "(\n"
+		if e.Op.IsAggregatorWithParam() {
+			s += fmt.Sprintf("%s,\n", p.pretty(e.Param, level+1))
+		}
+		return s + fmt.Sprintf("%s\n%s)", p.pretty(e.Expr, level+1), indent(level))
+
+	case *parser.BinaryExpr:
+		if !p.needsSplit(e) {
+			return s + e.String()
+		}
+		returnBool := ""
+		if e.ReturnBool {
+			returnBool = " bool"
+		}
+		return fmt.Sprintf("%s\n%s%s%s%s\n%s", p.pretty(e.LHS, level+1), indent(level), e.Op, returnBool, vectorMatching(e), p.pretty(e.RHS, level+1))
+
+	case *parser.Call:
+		if !p.needsSplit(e) {
+			return s + e.String()
+		}
+		args := make([]string, 0, len(e.Args))
+		for _, arg := range e.Args {
+			args = append(args, p.pretty(arg, level+1))
+		}
+		return s + fmt.Sprintf("%s(\n%s\n%s)", e.Func.Name, strings.Join(args, ",\n"), indent(level))
+
+	case *parser.ParenExpr:
+		if !p.needsSplit(e) {
+			return s + e.String()
+		}
+		return fmt.Sprintf("%s(\n%s\n%s)", s, p.pretty(e.Expr, level+1), indent(level))
+
+	case *parser.StepInvariantExpr:
+		return p.pretty(e.Expr, level)
+
+	case *parser.SubqueryExpr:
+		if !p.needsSplit(e) {
+			return e.String()
+		}
+		return p.pretty(e.Expr, level) + strings.TrimPrefix(e.String(), e.Expr.String())
+
+	case *parser.UnaryExpr:
+		// The indentation is written before the operator.
+		return fmt.Sprintf("%s%s%s", s, e.Op, strings.TrimSpace(p.pretty(e.Expr, level)))
+
+	default:
+		return s + n.String()
+	}
+}
+
+func (p prettifier) needsSplit(n parser.Node) bool {
+	return len(n.String()) > p.width
+}
+
+func indent(n int) string {
+	return strings.Repeat("  ", n)
+}
+
+func aggregationOp(e *parser.AggregateExpr) string {
+	op := e.Op.String()
+	switch {
+	case e.Without:
+		op += fmt.Sprintf(" without (%s) ", strings.Join(e.Grouping, ", "))
+	case len(e.Grouping) > 0:
+		op += fmt.Sprintf(" by (%s) ", strings.Join(e.Grouping, ", "))
+	}
+	return op
+}
+
+func vectorMatching(e *parser.BinaryExpr) string {
+	vm := e.VectorMatching
+	if vm == nil || (len(vm.MatchingLabels) == 0 && !vm.On) {
+		return ""
+	}
+	tag := "ignoring"
+	if vm.On {
+		tag = "on"
+	}
+	matching := fmt.Sprintf(" %s (%s)", tag, strings.Join(vm.MatchingLabels, ", "))
+	if vm.Card == parser.CardManyToOne || vm.Card == parser.CardOneToMany {
+		card := "right"
+		if vm.Card == parser.CardManyToOne {
+			card = "left"
+		}
+		matching += fmt.Sprintf(" group_%s (%s)", card, strings.Join(vm.Include, ", "))
+	}
+	return matching
+}
+
+// End taken from https://github.com/prometheus/prometheus/blob/d81e41d58ec8/promql/parser/prettier.go
+
+// dedent removes the indentation of the first line from every line, keeping
+// the lines less indented than the first one at the start of the line.
+func dedent(s string) string {
+	lines := strings.Split(s, "\n")
+	indent := len(lines[0]) - len(strings.TrimLeft(lines[0], " "))
+	for i, l := range lines {
+		n := len(l) - len(strings.TrimLeft(l, " "))
+		if n > indent {
+			n = indent
+		}
+		lines[i] = l[n:]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// PrettifyExpressions formats the `expr` of every rule with the PromQL
+// pretty-printer. Expressions split over several lines are written as literal
+// block scalars, the others are kept on a single line. Formatting is
+// idempotent, so already formatted rules are not modified.
+func (r RuleNamespace) PrettifyExpressions(width int) (int, int, error) {
+	// `count` represents the number of rules we evalated.
+	// `mod` represents the number of rules formatted.
+	var count, mod int
+	for i, group := range r.Groups {
+		for j, rule := range group.Rules {
+			log.WithFields(log.Fields{"rule": getRuleName(rule)}).Debugf("prettifying %s", "PromQL")
+			pretty, err := PrettifyExpr(rule.Expr.Value, width)
+			if err != nil {
+				return count, mod, err
+			}
+
+			count++
+
+			multiline := strings.Contains(pretty, "\n")
+			if multiline {
+				// The YAML encoder writes an invalid indentation indicator
+				// for block scalars starting with whitespace, as the left
+				// hand side of binary expressions does. The whole expression
+				// is de-indented instead, to keep the lines aligned.
+				pretty = dedent(pretty)
+			}
+			expr := &r.Groups[i].Rules[j].Expr
+			if expr.Value == pretty && multiline == (expr.Style&(yaml.LiteralStyle|yaml.FoldedStyle) != 0) {
+				continue
+			}
+
+			log.WithFields(log.Fields{
+				"rule":        getRuleName(rule),
+				"currentExpr": rule.Expr.Value,
+				"afterExpr":   pretty,
+			}).Debugf("expression differs")
+
+			mod++
+			expr.Value = pretty
+			expr.Style = 0
+			if multiline {
+				expr.Style = yaml.LiteralStyle
+			}
+		}
+	}
+
+	return count, mod, nil
+}
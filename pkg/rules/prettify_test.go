@@ -0,0 +1,137 @@
+package rules
+
+import (
+	"testing"
+
+	"github.com/prometheus/prometheus/model/rulefmt"
+	"github.com/prometheus/prometheus/promql/parser"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	yaml "gopkg.in/yaml.v3"
+
+	"github.com/cortexproject/cortex-tools/pkg/rules/rwrulefmt"
+)
+
+func TestPrettifyExpressions(t *testing.T) {
+	nested := `sum by (job, instance) (rate(http_requests_total{job="api-server",code=~"5..",handler!~"/debug.*"}[5m])) / sum by (job, instance) (rate(http_requests_total{job="api-server",handler!~"/debug.*"}[5m]))`
+	long := `sum by (job) (rate(http_requests_total{job="api",code=~"5.."}[5m])) / sum by (job) (rate(http_requests_total{job="api"}[5m]))`
+
+	tt := []struct {
+		name     string
+		expr     string
+		width    int
+		expected string
+		style    yaml.Style
+		modified int
+	}{
+		{
+			name:     "short expressions are kept on a single line",
+			expr:     "up   ==   0",
+			expected: "up == 0",
+			modified: 1,
+		},
+		{
+			name: "long expressions are split into a block scalar",
+			expr: long,
+			expected: `sum by (job) (rate(http_requests_total{code=~"5..",job="api"}[5m]))
+/
+sum by (job) (rate(http_requests_total{job="api"}[5m]))`,
+			style:    yaml.LiteralStyle,
+			modified: 1,
+		},
+		{
+			name: "every line is de-indented",
+			expr: nested,
+			expected: `sum by (job, instance) (
+  rate(http_requests_total{code=~"5..",handler!~"/debug.*",job="api-server"}[5m])
+)
+/
+sum by (job, instance) (rate(http_requests_total{handler!~"/debug.*",job="api-server"}[5m]))`,
+			style:    yaml.LiteralStyle,
+			modified: 1,
+		},
+		{
+			name:  "the width is configurable",
+			expr:  long,
+			width: 40,
+			expected: `sum by (job) (
+  rate(
+    http_requests_total{code=~"5..",job="api"}[5m]
+  )
+)
+/
+sum by (job) (
+  rate(http_requests_total{job="api"}[5m])
+)`,
+			style:    yaml.LiteralStyle,
+			modified: 1,
+		},
+		{
+			name:     "block scalars are joined when the expression fits on a line",
+			expr:     "up\n==\n0\n",
+			expected: "up == 0",
+			modified: 1,
+		},
+		{
+			name:     "formatted expressions are not modified",
+			expr:     "up == 0",
+			expected: "up == 0",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.width == 0 {
+				tc.width = DefaultPrettyWidth
+			}
+			r := RuleNamespace{Namespace: "example", Groups: []rwrulefmt.RuleGroup{{
+				RuleGroup: rulefmt.RuleGroup{
+					Name: "group",
+					Rules: []rulefmt.RuleNode{{
+						Alert: yaml.Node{Kind: yaml.ScalarNode, Value: "AName"},
+						Expr:  yaml.Node{Kind: yaml.ScalarNode, Value: tc.expr},
+					}},
+				},
+			}}}
+
+			c, m, err := r.PrettifyExpressions(tc.width)
+			require.NoError(t, err)
+			assert.Equal(t, 1, c)
+			assert.Equal(t, tc.modified, m)
+			assert.Equal(t, tc.expected, r.Groups[0].Rules[0].Expr.Value)
+			assert.Equal(t, tc.style, r.Groups[0].Rules[0].Expr.Style)
+
+			// Formatting the saved file again changes nothing.
+			out, err := yaml.Marshal(r)
+			require.NoError(t, err)
+			nss, errs := ParseBytes(out)
+			require.Empty(t, errs)
+			require.Len(t, nss, 1)
+
+			_, m, err = nss[0].PrettifyExpressions(tc.width)
+			require.NoError(t, err)
+			assert.Equal(t, 0, m)
+
+			again, err := yaml.Marshal(nss[0])
+			require.NoError(t, err)
+			assert.Equal(t, string(out), string(again))
+		})
+	}
+}
+
+func TestPrettifyExprMatchesPrometheus(t *testing.T) {
+	for _, expr := range []string{
+		`up == 0`,
+		`sum by (job, instance) (rate(http_requests_total{job="api-server",code=~"5..",handler!~"/debug.*"}[5m])) / on (job) group_left (team) sum by (job, instance) (rate(http_requests_total{job="api-server",handler!~"/debug.*"}[5m]))`,
+		`topk(5, sum without (instance) (rate(http_requests_total{job="api-server",code=~"5..",handler!~"/debug.*"}[5m]))) > bool 10`,
+		`-max_over_time(rate(http_requests_total{job="api-server",code=~"5..",handler!~"/debug.*",instance="localhost:9090"}[5m])[1h:5m])`,
+		`histogram_quantile(0.99, sum by (le) (rate(http_request_duration_seconds_bucket{job="api-server",handler!~"/debug.*"}[5m])))`,
+	} {
+		e, err := parser.ParseExpr(expr)
+		require.NoError(t, err)
+
+		pretty, err := PrettifyExpr(expr, DefaultPrettyWidth)
+		require.NoError(t, err)
+		assert.Equal(t, parser.Prettify(e), pretty)
+	}
+}
@@ -0,0 +1,156 @@
+package rules
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/rulefmt"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/prometheus/prometheus/promql/parser"
+	"github.com/prometheus/prometheus/template"
+
+	"github.com/cortexproject/cortex-tools/pkg/util"
+)
+
+// templateDefs are the variables defined for alert templates by the Prometheus
+// ruler.
+const templateDefs = "{{$labels := .Labels}}{{$externalLabels := .ExternalLabels}}{{$externalURL := .ExternalURL}}{{$value := .Value}}"
+
+// labelReferenceRe matches the labels referenced by templates, as in
+// $labels.instance or index $labels "instance".
+var labelReferenceRe = regexp.MustCompile(`\$labels\.([a-zA-Z_][a-zA-Z0-9_]*)|index\s+\$labels\s+"([^"]*)"`)
+
+// RenderSample is a series an alert template is rendered for.
+type RenderSample struct {
+	Labels map[string]string `json:"labels"`
+	Value  float64           `json:"value"`
+}
+
+// ParseRenderSample parses a sample given as a label set optionally followed
+// by a value, as in `{job="api", instance="a"} 42`.
+func ParseRenderSample(s string) (RenderSample, error) {
+	s = strings.TrimSpace(s)
+	var value float64
+	if i := strings.LastIndexAny(s, " \t"); i >= 0 && !strings.HasSuffix(s, "}") {
+		v, err := strconv.ParseFloat(s[i+1:], 64)
+		if err != nil {
+			return RenderSample{}, fmt.Errorf("invalid sample value %q: %w", s[i+1:], err)
+		}
+		s, value = strings.TrimSpace(s[:i]), v
+	}
+
+	lbls, err := parser.ParseMetric(s)
+	if err != nil {
+		return RenderSample{}, fmt.Errorf("invalid sample labels %q: %w", s, err)
+	}
+	return RenderSample{Labels: lbls.Map(), Value: value}, nil
+}
+
+// TemplateError is an error parsing or executing the template of an alert
+// label or annotation.
+type TemplateError struct {
+	// Field is the label or annotation the template is in, as in
+	// labels.severity or annotations.summary.
+	Field string `json:"field"`
+	Err   string `json:"error"`
+}
+
+// RenderedAlert is an alerting rule with its labels and annotations templates
+// expanded for a sample.
+type RenderedAlert struct {
+	Namespace   string            `json:"namespace,omitempty"`
+	Group       string            `json:"group,omitempty"`
+	Alert       string            `json:"alert"`
+	Sample      RenderSample      `json:"sample"`
+	Labels      map[string]string `json:"labels"`
+	Annotations map[string]string `json:"annotations"`
+	Errors      []TemplateError   `json:"errors,omitempty"`
+	// MissingLabels are the labels referenced by the templates which are not
+	// in the sample, and are rendered as empty strings.
+	MissingLabels []string `json:"missingLabels,omitempty"`
+}
+
+// RenderOptions are the values available to alert templates, besides the
+// sample.
+type RenderOptions struct {
+	ExternalLabels map[string]string
+	ExternalURL    string
+	Time           time.Time
+	// QueryFunc runs the queries of the query template function. It may be
+	// nil, in which case templates using it fail.
+	QueryFunc template.QueryFunc
+}
+
+// RenderAlert expands the labels and annotations templates of an alerting rule
+// for a sample, as the Prometheus ruler does when the alert fires.
+func RenderAlert(ctx context.Context, rule rulefmt.RuleNode, sample RenderSample, opts RenderOptions) RenderedAlert {
+	externalURL, err := url.Parse(opts.ExternalURL)
+	if err != nil {
+		externalURL = &url.URL{}
+	}
+	queryFunc := opts.QueryFunc
+	if queryFunc == nil {
+		queryFunc = func(context.Context, string, time.Time) (promql.Vector, error) {
+			return nil, fmt.Errorf("queries are not supported without a cluster")
+		}
+	}
+
+	// The sample labels don't include the metric name once evaluated.
+	sampleLabels := make(map[string]string, len(sample.Labels))
+	for name, value := range sample.Labels {
+		if name != labels.MetricName {
+			sampleLabels[name] = value
+		}
+	}
+	data := template.AlertTemplateData(sampleLabels, opts.ExternalLabels, opts.ExternalURL, sample.Value)
+
+	rendered := RenderedAlert{
+		Alert:       rule.Alert.Value,
+		Sample:      sample,
+		Labels:      map[string]string{},
+		Annotations: map[string]string{},
+	}
+	missing := map[string]struct{}{}
+	expand := func(field, text string) string {
+		for _, m := range labelReferenceRe.FindAllStringSubmatch(text, -1) {
+			name := m[1] + m[2]
+			if _, ok := sampleLabels[name]; !ok {
+				missing[name] = struct{}{}
+			}
+		}
+
+		tmpl := template.NewTemplateExpander(ctx, templateDefs+text, "__alert_"+rule.Alert.Value, data, model.TimeFromUnixNano(opts.Time.UnixNano()), queryFunc, externalURL, nil)
+		result, err := tmpl.Expand()
+		if err != nil {
+			rendered.Errors = append(rendered.Errors, TemplateError{Field: field, Err: err.Error()})
+			return fmt.Sprintf("<error expanding template: %s>", err)
+		}
+		return result
+	}
+
+	for name, value := range sampleLabels {
+		rendered.Labels[name] = value
+	}
+	for _, name := range util.SortedKeys(rule.Labels) {
+		rendered.Labels[name] = expand("labels."+name, rule.Labels[name])
+	}
+	rendered.Labels[labels.AlertName] = rule.Alert.Value
+
+	for _, name := range util.SortedKeys(rule.Annotations) {
+		rendered.Annotations[name] = expand("annotations."+name, rule.Annotations[name])
+	}
+
+	for name := range missing {
+		rendered.MissingLabels = append(rendered.MissingLabels, name)
+	}
+	sort.Strings(rendered.MissingLabels)
+	return rendered
+}
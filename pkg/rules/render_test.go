@@ -0,0 +1,86 @@
+package rules
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/prometheus/model/rulefmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	yaml "gopkg.in/yaml.v3"
+)
+
+func alertingRule(name string, lbls, annotations map[string]string) rulefmt.RuleNode {
+	return rulefmt.RuleNode{
+		Alert:       yaml.Node{Kind: yaml.ScalarNode, Value: name},
+		Expr:        yaml.Node{Kind: yaml.ScalarNode, Value: "up == 0"},
+		Labels:      lbls,
+		Annotations: annotations,
+	}
+}
+
+func TestRenderAlert(t *testing.T) {
+	rule := alertingRule("InstanceDown",
+		map[string]string{"severity": "{{ if eq $labels.job \"api\" }}critical{{ else }}warning{{ end }}"},
+		map[string]string{
+			"summary":     "{{ $labels.instance }} of {{ $labels.job }} is down",
+			"description": "value {{ $value }} in {{ $externalLabels.cluster }}, see {{ $externalURL }}",
+		},
+	)
+	sample := RenderSample{Labels: map[string]string{"__name__": "up", "job": "api", "instance": "a:80"}, Value: 0}
+
+	rendered := RenderAlert(context.Background(), rule, sample, RenderOptions{
+		ExternalLabels: map[string]string{"cluster": "eu"},
+		ExternalURL:    "http://ruler",
+		Time:           time.Unix(0, 0),
+	})
+	assert.Empty(t, rendered.Errors)
+	assert.Empty(t, rendered.MissingLabels)
+	assert.Equal(t, map[string]string{
+		"alertname": "InstanceDown",
+		"severity":  "critical",
+		"job":       "api",
+		"instance":  "a:80",
+	}, rendered.Labels)
+	assert.Equal(t, map[string]string{
+		"summary":     "a:80 of api is down",
+		"description": "value 0 in eu, see http://ruler",
+	}, rendered.Annotations)
+}
+
+func TestRenderAlertErrors(t *testing.T) {
+	rule := alertingRule("Broken", nil, map[string]string{
+		"parse":   "{{ $labels.job ",
+		"exec":    "{{ humanize \"abc\" }}",
+		"query":   "{{ query \"up\" }}",
+		"missing": "{{ $labels.pod }} {{ index $labels \"namespace\" }}",
+	})
+
+	rendered := RenderAlert(context.Background(), rule, RenderSample{Labels: map[string]string{"job": "api"}}, RenderOptions{})
+
+	var fields []string
+	for _, e := range rendered.Errors {
+		fields = append(fields, e.Field)
+	}
+	assert.Equal(t, []string{"annotations.exec", "annotations.parse", "annotations.query"}, fields)
+	assert.Contains(t, rendered.Annotations["parse"], "<error expanding template")
+	assert.Equal(t, " ", rendered.Annotations["missing"])
+	assert.Equal(t, []string{"namespace", "pod"}, rendered.MissingLabels)
+}
+
+func TestParseRenderSample(t *testing.T) {
+	sample, err := ParseRenderSample(`{job="api", instance="a:80"} 42.5`)
+	require.NoError(t, err)
+	assert.Equal(t, RenderSample{Labels: map[string]string{"job": "api", "instance": "a:80"}, Value: 42.5}, sample)
+
+	sample, err = ParseRenderSample(`up{job="api"}`)
+	require.NoError(t, err)
+	assert.Equal(t, RenderSample{Labels: map[string]string{"__name__": "up", "job": "api"}}, sample)
+
+	_, err = ParseRenderSample(`{job="api"} abc`)
+	assert.Error(t, err)
+
+	_, err = ParseRenderSample(`{job=}`)
+	assert.Error(t, err)
+}
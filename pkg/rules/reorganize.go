@@ -0,0 +1,411 @@
+package rules
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/prometheus/prometheus/model/rulefmt"
+	yaml "gopkg.in/yaml.v3"
+
+	"github.com/cortexproject/cortex-tools/pkg/rules/rwrulefmt"
+)
+
+// NamespaceMappingFile holds the mappings moving rule groups into namespaces.
+type NamespaceMappingFile struct {
+	Mappings []NamespaceMapping `yaml:"mappings"`
+}
+
+// NamespaceMapping moves the rule groups matching a regex into a namespace.
+type NamespaceMapping struct {
+	// Match is a regex fully matching `<namespace>/<group>`.
+	Match string `yaml:"match"`
+	// Namespace is the namespace the groups are moved to. It may reference the
+	// capture groups of Match, as in $1.
+	Namespace string `yaml:"namespace"`
+
+	re *regexp.Regexp
+}
+
+// LoadNamespaceMappingFile reads and validates a namespace mapping file.
+func LoadNamespaceMappingFile(filename string) ([]NamespaceMapping, error) {
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	decoder.KnownFields(true)
+
+	mf := &NamespaceMappingFile{}
+	if err := decoder.Decode(mf); err != nil {
+		return nil, fmt.Errorf("unable to parse namespace mapping file %s: %w", filename, err)
+	}
+
+	for i := range mf.Mappings {
+		if err := mf.Mappings[i].compile(); err != nil {
+			return nil, fmt.Errorf("invalid mapping #%d in %s: %w", i, filename, err)
+		}
+	}
+	return mf.Mappings, nil
+}
+
+func (m *NamespaceMapping) compile() error {
+	if m.Namespace == "" {
+		return fmt.Errorf("namespace is required")
+	}
+	re, err := regexp.Compile("^(?:" + m.Match + ")$")
+	if err != nil {
+		return err
+	}
+	m.re = re
+	return nil
+}
+
+// ReorganizeConfig configures how rule groups are rebalanced.
+type ReorganizeConfig struct {
+	// MaxGroupSize is the number of rules above which groups are split. Groups
+	// are not split if it is 0.
+	MaxGroupSize int
+	// MinGroupSize is the number of rules under which groups of a namespace
+	// are merged with the other small groups evaluated at the same interval.
+	// Groups are not merged if it is 0.
+	MinGroupSize int
+	// NamespaceLabel moves every group whose rules all have this label to
+	// the namespace named after its value.
+	NamespaceLabel string
+	// Mappings move the groups matching them into namespaces, when they
+	// aren't moved by NamespaceLabel. The first matching mapping applies.
+	Mappings []NamespaceMapping
+}
+
+// Kinds of reorganization changes.
+const (
+	GroupMoved   = "moved"
+	GroupRenamed = "renamed"
+	GroupSplit   = "split"
+	GroupMerged  = "merged"
+)
+
+// ReorganizeChange is a change made to a rule group by Reorganize.
+type ReorganizeChange struct {
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace"`
+	Group     string `json:"group"`
+	Message   string `json:"message"`
+}
+
+func (c ReorganizeChange) String() string {
+	return fmt.Sprintf("%s: namespace: %s, group: %s: %s", c.Kind, c.Namespace, c.Group, c.Message)
+}
+
+// Reorganize rebalances rule groups. Groups are first moved into namespaces
+// by label or mapping, then groups larger than the maximum size are split and
+// the groups smaller than the minimum size are merged. Rules which depend on
+// each other through the metrics they record are always kept in the same
+// group, in their original order. The owner marker groups are left as they
+// are. The namespaces are returned sorted by name.
+func Reorganize(nss []RuleNamespace, cfg ReorganizeConfig) ([]RuleNamespace, []ReorganizeChange, error) {
+	for i := range cfg.Mappings {
+		if cfg.Mappings[i].re == nil {
+			if err := cfg.Mappings[i].compile(); err != nil {
+				return nil, nil, fmt.Errorf("invalid mapping #%d: %w", i, err)
+			}
+		}
+	}
+
+	var changes []ReorganizeChange
+	byName := map[string]*RuleNamespace{}
+	var names []string
+	namespace := func(name string) *RuleNamespace {
+		ns, ok := byName[name]
+		if !ok {
+			ns = &RuleNamespace{Namespace: name}
+			byName[name] = ns
+			names = append(names, name)
+		}
+		return ns
+	}
+
+	// Groups which aren't moved are placed first, so that only the groups moved
+	// next to a group of the same name are renamed.
+	for _, moved := range []bool{false, true} {
+		for _, src := range nss {
+			namespace(src.Namespace)
+			for _, group := range src.Groups {
+				target := src.Namespace
+				if group.Name != OwnerGroupName {
+					target = cfg.targetNamespace(src.Namespace, group)
+				}
+				if (target != src.Namespace) != moved {
+					continue
+				}
+				dst := namespace(target)
+				name := uniqueGroupName(dst.Groups, group.Name)
+
+				if moved {
+					changes = append(changes, ReorganizeChange{
+						Kind:      GroupMoved,
+						Namespace: target,
+						Group:     name,
+						Message:   fmt.Sprintf("moved from namespace %s", src.Namespace),
+					})
+				}
+				if name != group.Name {
+					changes = append(changes, ReorganizeChange{
+						Kind:      GroupRenamed,
+						Namespace: target,
+						Group:     name,
+						Message:   fmt.Sprintf("renamed from %s, as the namespace already has a group with this name", group.Name),
+					})
+				}
+				group.Name = name
+				dst.Groups = append(dst.Groups, group)
+			}
+		}
+	}
+
+	sort.Strings(names)
+	result := make([]RuleNamespace, 0, len(names))
+	for _, name := range names {
+		ns := byName[name]
+		if len(ns.Groups) == 0 {
+			continue
+		}
+
+		var err error
+		var nsChanges []ReorganizeChange
+		if cfg.MaxGroupSize > 0 {
+			ns.Groups, nsChanges, err = splitGroups(ns.Groups, cfg.MaxGroupSize)
+			if err != nil {
+				return nil, nil, fmt.Errorf("namespace %s: %w", name, err)
+			}
+			for i := range nsChanges {
+				nsChanges[i].Namespace = name
+			}
+			changes = append(changes, nsChanges...)
+		}
+		if cfg.MinGroupSize > 0 {
+			ns.Groups, nsChanges = mergeGroups(ns.Groups, cfg.MinGroupSize, cfg.MaxGroupSize)
+			for i := range nsChanges {
+				nsChanges[i].Namespace = name
+			}
+			changes = append(changes, nsChanges...)
+		}
+		result = append(result, *ns)
+	}
+	return result, changes, nil
+}
+
+// targetNamespace returns the namespace a group is moved to.
+func (cfg ReorganizeConfig) targetNamespace(namespace string, group rwrulefmt.RuleGroup) string {
+	if cfg.NamespaceLabel != "" && len(group.Rules) > 0 {
+		value := group.Rules[0].Labels[cfg.NamespaceLabel]
+		for _, rule := range group.Rules[1:] {
+			if rule.Labels[cfg.NamespaceLabel] != value {
+				value = ""
+				break
+			}
+		}
+		if value != "" {
+			return value
+		}
+	}
+
+	key := namespace + "/" + group.Name
+	for _, m := range cfg.Mappings {
+		if match := m.re.FindStringSubmatchIndex(key); match != nil {
+			return string(m.re.ExpandString(nil, m.Namespace, key, match))
+		}
+	}
+	return namespace
+}
+
+// splitGroups splits the groups with more than max rules in as few groups as
+// possible. The first part keeps the name of the group, the others are
+// suffixed by their position.
+func splitGroups(groups []rwrulefmt.RuleGroup, max int) ([]rwrulefmt.RuleGroup, []ReorganizeChange, error) {
+	var changes []ReorganizeChange
+	result := make([]rwrulefmt.RuleGroup, 0, len(groups))
+	for _, group := range groups {
+		if len(group.Rules) <= max || group.Name == OwnerGroupName {
+			result = append(result, group)
+			continue
+		}
+
+		units, err := dependentRules(group.Rules)
+		if err != nil {
+			return nil, nil, fmt.Errorf("group %s: %w", group.Name, err)
+		}
+
+		var parts [][]rulefmt.RuleNode
+		var current []rulefmt.RuleNode
+		for _, unit := range units {
+			if len(current) > 0 && len(current)+len(unit) > max {
+				parts = append(parts, current)
+				current = nil
+			}
+			current = append(current, unit...)
+		}
+		parts = append(parts, current)
+		if len(parts) == 1 {
+			changes = append(changes, ReorganizeChange{
+				Kind:    GroupSplit,
+				Group:   group.Name,
+				Message: fmt.Sprintf("not split, its %d rules depend on each other", len(group.Rules)),
+			})
+			result = append(result, group)
+			continue
+		}
+
+		var names []string
+		for i, rules := range parts {
+			part := group
+			part.Rules = rules
+			if i > 0 {
+				taken := append(append([]rwrulefmt.RuleGroup{}, result...), groups...)
+				part.Name = uniqueGroupName(taken, fmt.Sprintf("%s-%d", group.Name, i+1))
+			}
+			names = append(names, fmt.Sprintf("%s (%d rules)", part.Name, len(rules)))
+			result = append(result, part)
+			if len(rules) > max {
+				changes = append(changes, ReorganizeChange{
+					Kind:    GroupSplit,
+					Group:   part.Name,
+					Message: fmt.Sprintf("has %d rules, more than %d, as they depend on each other", len(rules), max),
+				})
+			}
+		}
+		changes = append(changes, ReorganizeChange{
+			Kind:    GroupSplit,
+			Group:   group.Name,
+			Message: fmt.Sprintf("split %d rules into %s", len(group.Rules), strings.Join(names, ", ")),
+		})
+	}
+	return result, changes, nil
+}
+
+// dependentRules partitions rules into the sets of rules which depend on each
+// other through the metrics recorded by the recording rules of the set. The
+// sets, and the rules in each set, are in the original order.
+func dependentRules(rules []rulefmt.RuleNode) ([][]rulefmt.RuleNode, error) {
+	parent := make([]int, len(rules))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	union := func(a, b int) {
+		a, b = find(a), find(b)
+		if a < b {
+			parent[b] = a
+		} else {
+			parent[a] = b
+		}
+	}
+
+	producers := map[string][]int{}
+	for i, rule := range rules {
+		if rule.Record.Value != "" {
+			producers[rule.Record.Value] = append(producers[rule.Record.Value], i)
+		}
+	}
+	for i, rule := range rules {
+		metrics, err := selectedMetrics(rule.Expr.Value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", getRuleName(rule), err)
+		}
+		for _, metric := range metrics {
+			for _, p := range producers[metric] {
+				union(i, p)
+			}
+		}
+	}
+
+	var units [][]rulefmt.RuleNode
+	index := map[int]int{}
+	for i, rule := range rules {
+		root := find(i)
+		u, ok := index[root]
+		if !ok {
+			u = len(units)
+			index[root] = u
+			units = append(units, nil)
+		}
+		units[u] = append(units[u], rule)
+	}
+	return units, nil
+}
+
+// mergeGroups merges each group with less than min rules into the first
+// previous group with less than min rules and the same evaluation settings,
+// as long as the merged group has no more than max rules, if set.
+func mergeGroups(groups []rwrulefmt.RuleGroup, min, max int) ([]rwrulefmt.RuleGroup, []ReorganizeChange) {
+	var changes []ReorganizeChange
+	result := make([]rwrulefmt.RuleGroup, 0, len(groups))
+	merged := map[int][]string{}
+	for _, group := range groups {
+		target := -1
+		if len(group.Rules) < min && group.Name != OwnerGroupName {
+			for i := range result {
+				candidate := result[i]
+				if candidate.Name == OwnerGroupName || len(candidate.Rules) >= min {
+					continue
+				}
+				if max > 0 && len(candidate.Rules)+len(group.Rules) > max {
+					continue
+				}
+				if candidate.Interval != group.Interval || candidate.Limit != group.Limit || !reflect.DeepEqual(candidate.RWConfigs, group.RWConfigs) {
+					continue
+				}
+				target = i
+				break
+			}
+		}
+
+		if target < 0 {
+			result = append(result, group)
+			continue
+		}
+		rules := result[target].Rules
+		result[target].Rules = append(rules[:len(rules):len(rules)], group.Rules...)
+		merged[target] = append(merged[target], group.Name)
+	}
+
+	for i, group := range result {
+		if len(merged[i]) == 0 {
+			continue
+		}
+		changes = append(changes, ReorganizeChange{
+			Kind:    GroupMerged,
+			Group:   group.Name,
+			Message: fmt.Sprintf("merged with %s", strings.Join(merged[i], ", ")),
+		})
+	}
+	return result, changes
+}
+
+// uniqueGroupName returns the name, suffixed with a number if needed to be
+// unique among the groups.
+func uniqueGroupName(groups []rwrulefmt.RuleGroup, name string) string {
+	taken := make(map[string]struct{}, len(groups))
+	for _, g := range groups {
+		taken[g.Name] = struct{}{}
+	}
+	unique := name
+	for i := 2; ; i++ {
+		if _, ok := taken[unique]; !ok {
+			return unique
+		}
+		unique = fmt.Sprintf("%s-%d", name, i)
+	}
+}
@@ -0,0 +1,144 @@
+package rules
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/rulefmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	yaml "gopkg.in/yaml.v3"
+
+	"github.com/cortexproject/cortex-tools/pkg/rules/rwrulefmt"
+)
+
+func reorganizeGroup(name string, interval time.Duration, rules ...[2]string) rwrulefmt.RuleGroup {
+	group := rwrulefmt.RuleGroup{RuleGroup: rulefmt.RuleGroup{Name: name, Interval: model.Duration(interval)}}
+	for _, r := range rules {
+		group.Rules = append(group.Rules, rulefmt.RuleNode{
+			Record: yaml.Node{Kind: yaml.ScalarNode, Value: r[0]},
+			Expr:   yaml.Node{Kind: yaml.ScalarNode, Value: r[1]},
+		})
+	}
+	return group
+}
+
+func groupRules(group rwrulefmt.RuleGroup) []string {
+	var names []string
+	for _, rule := range group.Rules {
+		names = append(names, rule.Record.Value)
+	}
+	return names
+}
+
+func TestReorganizeSplit(t *testing.T) {
+	nss := []RuleNamespace{{
+		Namespace: "ns",
+		Groups: []rwrulefmt.RuleGroup{reorganizeGroup("big", 0,
+			[2]string{"a:rate", "sum(rate(a[5m]))"},
+			[2]string{"b:rate", "sum(rate(b[5m]))"},
+			[2]string{"a:ratio", "a:rate / sum(rate(c[5m]))"},
+			[2]string{"d:rate", "sum(rate(d[5m]))"},
+			[2]string{"e:rate", "sum(rate(e[5m]))"},
+		)},
+	}}
+
+	result, changes, err := Reorganize(nss, ReorganizeConfig{MaxGroupSize: 2})
+	require.NoError(t, err)
+	require.Len(t, result, 1)
+	require.Len(t, result[0].Groups, 3)
+
+	// a:ratio depends on a:rate, so they stay together and in order.
+	assert.Equal(t, "big", result[0].Groups[0].Name)
+	assert.Equal(t, []string{"a:rate", "a:ratio"}, groupRules(result[0].Groups[0]))
+	assert.Equal(t, "big-2", result[0].Groups[1].Name)
+	assert.Equal(t, []string{"b:rate", "d:rate"}, groupRules(result[0].Groups[1]))
+	assert.Equal(t, "big-3", result[0].Groups[2].Name)
+	assert.Equal(t, []string{"e:rate"}, groupRules(result[0].Groups[2]))
+
+	require.Len(t, changes, 1)
+	assert.Equal(t, GroupSplit, changes[0].Kind)
+	assert.Equal(t, "ns", changes[0].Namespace)
+
+	// The input is left untouched.
+	assert.Len(t, nss[0].Groups[0].Rules, 5)
+}
+
+func TestReorganizeMerge(t *testing.T) {
+	nss := []RuleNamespace{{
+		Namespace: "ns",
+		Groups: []rwrulefmt.RuleGroup{
+			reorganizeGroup("a", time.Minute, [2]string{"a", "vector(1)"}),
+			reorganizeGroup("b", 2*time.Minute, [2]string{"b", "vector(1)"}),
+			reorganizeGroup("c", time.Minute, [2]string{"c", "vector(1)"}),
+			reorganizeGroup("d", time.Minute, [2]string{"d", "vector(1)"}, [2]string{"d2", "vector(1)"}, [2]string{"d3", "vector(1)"}),
+			reorganizeGroup("e", time.Minute, [2]string{"e", "vector(1)"}),
+			reorganizeGroup("f", time.Minute, [2]string{"f", "vector(1)"}),
+		},
+	}}
+
+	result, changes, err := Reorganize(nss, ReorganizeConfig{MinGroupSize: 3})
+	require.NoError(t, err)
+	require.Len(t, result, 1)
+
+	var names [][]string
+	for _, g := range result[0].Groups {
+		names = append(names, append([]string{g.Name}, groupRules(g)...))
+	}
+	assert.Equal(t, [][]string{
+		{"a", "a", "c", "e"},
+		{"b", "b"},
+		{"d", "d", "d2", "d3"},
+		{"f", "f"},
+	}, names)
+
+	require.Len(t, changes, 1)
+	assert.Equal(t, ReorganizeChange{Kind: GroupMerged, Namespace: "ns", Group: "a", Message: "merged with c, e"}, changes[0])
+}
+
+func TestReorganizeMove(t *testing.T) {
+	labelled := reorganizeGroup("team-a", 0, [2]string{"a", "vector(1)"}, [2]string{"b", "vector(1)"})
+	for i := range labelled.Rules {
+		labelled.Rules[i].Labels = map[string]string{"team": "alpha"}
+	}
+	nss := []RuleNamespace{
+		{Namespace: "old", Groups: []rwrulefmt.RuleGroup{
+			labelled,
+			reorganizeGroup("infra_nodes", 0, [2]string{"c", "vector(1)"}),
+			reorganizeGroup("other", 0, [2]string{"d", "vector(1)"}),
+			OwnerGroup("ci"),
+		}},
+		{Namespace: "infra", Groups: []rwrulefmt.RuleGroup{
+			reorganizeGroup("infra_nodes", 0, [2]string{"e", "vector(1)"}),
+		}},
+	}
+
+	result, changes, err := Reorganize(nss, ReorganizeConfig{
+		NamespaceLabel: "team",
+		Mappings:       []NamespaceMapping{{Match: "old/(infra)_.*", Namespace: "$1"}},
+	})
+	require.NoError(t, err)
+
+	groups := map[string][]string{}
+	for _, ns := range result {
+		for _, g := range ns.Groups {
+			groups[ns.Namespace] = append(groups[ns.Namespace], g.Name)
+		}
+	}
+	assert.Equal(t, map[string][]string{
+		"alpha": {"team-a"},
+		"infra": {"infra_nodes", "infra_nodes-2"},
+		"old":   {"other", OwnerGroupName},
+	}, groups)
+
+	var kinds []string
+	for _, c := range changes {
+		kinds = append(kinds, c.Kind+" "+c.Namespace+"/"+c.Group)
+	}
+	assert.Equal(t, []string{
+		"moved alpha/team-a",
+		"moved infra/infra_nodes-2",
+		"renamed infra/infra_nodes-2",
+	}, kinds)
+}
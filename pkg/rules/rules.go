@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/prometheus/prometheus/model/labels"
 	"github.com/prometheus/prometheus/model/rulefmt"
 	"github.com/prometheus/prometheus/promql/parser"
 	log "github.com/sirupsen/logrus"
@@ -159,10 +160,10 @@ func exprNodeInspectorFunc(rule rulefmt.RuleNode, label string) func(node parser
 }
 
 func prepareAggregationExpr(e *parser.AggregateExpr, label string, ruleName string) error {
-	// If the aggregation is about dropping labels (e.g. without), we don't want to modify
-	// this expression. Omission as long as it is not the cluster label will include it.
-	// TODO: We probably want to check whenever the label we're trying to include is included in the omission.
+	// Aggregations dropping labels (e.g. without) keep the label, as long as
+	// it is not one of the dropped labels.
 	if e.Without {
+		e.Grouping = removeLabel(e.Grouping, label)
 		return nil
 	}
 
@@ -205,6 +206,121 @@ func prepareBinaryExpr(e *parser.BinaryExpr, label string, rule string) error {
 	return nil
 }
 
+// ExpressionRewrite is a rule expression modified by InjectLabel.
+type ExpressionRewrite struct {
+	Namespace string
+	Group     string
+	Rule      string
+	Before    string
+	After     string
+}
+
+// InjectLabel scopes every rule expression to the series having label set to
+// value. A matcher is added to every vector and matrix selector, and the label
+// is kept in the results: it is added to aggregations and on() matchings as
+// AggregateBy does, and removed from without(), ignoring() and group_left() or
+// group_right() clauses. If the applyTo function is provided, only the rules
+// for which it returns true are modified. Returns the number of rules
+// evaluated and the rewritten expressions.
+func (r RuleNamespace) InjectLabel(label, value string, applyTo func(group rwrulefmt.RuleGroup, rule rulefmt.RuleNode) bool) (int, []ExpressionRewrite, error) {
+	matcher, err := labels.NewMatcher(labels.MatchEqual, label, value)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	var count int
+	var rewrites []ExpressionRewrite
+
+	for i, group := range r.Groups {
+		for j, rule := range group.Rules {
+			count++
+			if applyTo != nil && !applyTo(group, rule) {
+				log.WithFields(log.Fields{
+					"group": group.Name,
+					"rule":  getRuleName(rule),
+				}).Debugf("skipped")
+				continue
+			}
+
+			exp, err := parser.ParseExpr(rule.Expr.Value)
+			if err != nil {
+				return count, rewrites, err
+			}
+
+			parser.Inspect(exp, func(node parser.Node, _ []parser.Node) error {
+				switch n := node.(type) {
+				case *parser.VectorSelector:
+					injectMatcher(n, matcher, getRuleName(rule))
+				case *parser.AggregateExpr:
+					return prepareAggregationExpr(n, label, getRuleName(rule))
+				case *parser.BinaryExpr:
+					if n.VectorMatching == nil {
+						return nil
+					}
+					// Both sides have the label once injected, so it does not
+					// need to be copied from the "one" side.
+					n.VectorMatching.Include = removeLabel(n.VectorMatching.Include, label)
+					if !n.VectorMatching.On {
+						n.VectorMatching.MatchingLabels = removeLabel(n.VectorMatching.MatchingLabels, label)
+						return nil
+					}
+					return prepareBinaryExpr(n, label, getRuleName(rule))
+				}
+				return nil
+			})
+
+			after := exp.String()
+			if rule.Expr.Value == after {
+				continue
+			}
+
+			// Make sure the rewritten expression is still valid.
+			if _, err := parser.ParseExpr(after); err != nil {
+				return count, rewrites, fmt.Errorf("rule %s: rewritten expression is invalid: %w", getRuleName(rule), err)
+			}
+
+			rewrites = append(rewrites, ExpressionRewrite{
+				Namespace: r.Namespace,
+				Group:     group.Name,
+				Rule:      getRuleName(rule),
+				Before:    rule.Expr.Value,
+				After:     after,
+			})
+			r.Groups[i].Rules[j].Expr.Value = after
+		}
+	}
+
+	return count, rewrites, nil
+}
+
+// injectMatcher adds the matcher to a selector, unless the selector already
+// has a matcher on the same label.
+func injectMatcher(vs *parser.VectorSelector, matcher *labels.Matcher, rule string) {
+	for _, m := range vs.LabelMatchers {
+		if m.Name != matcher.Name {
+			continue
+		}
+		if m.String() != matcher.String() {
+			log.WithFields(log.Fields{
+				"rule":     rule,
+				"selector": vs.String(),
+			}).Warnf("selector already has a %s matcher, leaving it unchanged", matcher.Name)
+		}
+		return
+	}
+	vs.LabelMatchers = append(vs.LabelMatchers, matcher)
+}
+
+func removeLabel(lbls []string, label string) []string {
+	var out []string
+	for _, l := range lbls {
+		if l != label {
+			out = append(out, l)
+		}
+	}
+	return out
+}
+
 // Validate each rule in the rule namespace is valid
 func (r RuleNamespace) Validate() []error {
 	set := map[string]struct{}{}
@@ -70,6 +70,23 @@ func TestAggregateBy(t *testing.T) {
 			expectedExpr: []string{`min without (alertmanager) (rate(prometheus_notifications_errors_total{job="default/prometheus"}[5m]) / rate(prometheus_notifications_sent_total{job="default/prometheus"}[5m])) * 100 > 3`},
 			count:        1, modified: 1, expect: nil,
 		},
+		{
+			name: "with the label dropped by 'without' in the aggregation",
+			rn: RuleNamespace{
+				Groups: []rwrulefmt.RuleGroup{
+					{
+						RuleGroup: rulefmt.RuleGroup{
+							Name: "WithoutCluster",
+							Rules: []rulefmt.RuleNode{
+								{Alert: yaml.Node{Value: "WithoutCluster"}, Expr: yaml.Node{Value: `sum without (cluster, instance) (rate(http_requests_total[5m])) > 0`}},
+							},
+						},
+					},
+				},
+			},
+			expectedExpr: []string{`sum without (instance) (rate(http_requests_total[5m])) > 0`},
+			count:        1, modified: 1, expect: nil,
+		},
 		{
 			name: "with an aggregation modification",
 			rn: RuleNamespace{
@@ -339,3 +356,93 @@ func TestCheckRecordingRules(t *testing.T) {
 		})
 	}
 }
+
+func TestInjectLabel(t *testing.T) {
+	tt := []struct {
+		name     string
+		expr     string
+		expected string
+	}{
+		{
+			name:     "vector and matrix selectors",
+			expr:     `up{job="api"} == 0 or rate(http_requests_total[5m]) > 1`,
+			expected: `up{cluster="us-east",job="api"} == 0 or rate(http_requests_total{cluster="us-east"}[5m]) > 1`,
+		},
+		{
+			name:     "selectors already scoped to a cluster are left unchanged",
+			expr:     `up{cluster="eu-west"} or up{cluster="us-east"}`,
+			expected: `up{cluster="eu-west"} or up{cluster="us-east"}`,
+		},
+		{
+			name:     "aggregations keep the label",
+			expr:     `sum by (job) (rate(http_requests_total[5m])) / sum without (cluster, instance) (rate(http_requests_total[5m]))`,
+			expected: `sum by (job, cluster) (rate(http_requests_total{cluster="us-east"}[5m])) / sum without (instance) (rate(http_requests_total{cluster="us-east"}[5m]))`,
+		},
+		{
+			name:     "ignoring does not drop the label",
+			expr:     `errors_total / ignoring (cluster, code) requests_total`,
+			expected: `errors_total{cluster="us-east"} / ignoring (code) requests_total{cluster="us-east"}`,
+		},
+		{
+			name:     "group_left does not copy the label",
+			expr:     `rate(http_requests_total[5m]) * on (instance) group_left (cluster, version) build_info`,
+			expected: `rate(http_requests_total{cluster="us-east"}[5m]) * on (instance, cluster) group_left (version) build_info{cluster="us-east"}`,
+		},
+		{
+			name:     "subqueries",
+			expr:     `max_over_time(up[1h:5m])`,
+			expected: `max_over_time(up{cluster="us-east"}[1h:5m])`,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			r := RuleNamespace{Namespace: "example", Groups: []rwrulefmt.RuleGroup{
+				{
+					RuleGroup: rulefmt.RuleGroup{
+						Name: "group",
+						Rules: []rulefmt.RuleNode{
+							{
+								Alert: yaml.Node{Value: "AName"},
+								Expr:  yaml.Node{Value: tc.expr},
+							},
+						},
+					},
+				},
+			}}
+
+			c, rewrites, err := r.InjectLabel("cluster", "us-east", nil)
+			require.NoError(t, err)
+			require.Equal(t, 1, c)
+			require.Equal(t, tc.expected, r.Groups[0].Rules[0].Expr.Value)
+
+			if tc.expr == tc.expected {
+				require.Empty(t, rewrites)
+				return
+			}
+			require.Equal(t, []ExpressionRewrite{{
+				Namespace: "example",
+				Group:     "group",
+				Rule:      "AName",
+				Before:    tc.expr,
+				After:     tc.expected,
+			}}, rewrites)
+		})
+	}
+}
+
+func TestInjectLabelApplyTo(t *testing.T) {
+	r := RuleNamespace{Groups: []rwrulefmt.RuleGroup{
+		{RuleGroup: rulefmt.RuleGroup{Name: "included", Rules: []rulefmt.RuleNode{{Record: yaml.Node{Value: "a:up"}, Expr: yaml.Node{Value: "up"}}}}},
+		{RuleGroup: rulefmt.RuleGroup{Name: "skipped", Rules: []rulefmt.RuleNode{{Record: yaml.Node{Value: "b:up"}, Expr: yaml.Node{Value: "up"}}}}},
+	}}
+
+	c, rewrites, err := r.InjectLabel("cluster", "us-east", func(group rwrulefmt.RuleGroup, _ rulefmt.RuleNode) bool {
+		return group.Name == "included"
+	})
+	require.NoError(t, err)
+	require.Equal(t, 2, c)
+	require.Len(t, rewrites, 1)
+	require.Equal(t, `up{cluster="us-east"}`, r.Groups[0].Rules[0].Expr.Value)
+	require.Equal(t, "up", r.Groups[1].Rules[0].Expr.Value)
+}